@@ -5,14 +5,17 @@ import (
 	"github.com/rverst/go-miab/miab"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 func init() {
 	rootCmd.AddCommand(aliasGetCmd)
-	aliasGetCmd.AddCommand(aliasAddCmd, aliasDeleteCmd)
+	aliasGetCmd.AddCommand(aliasAddCmd, aliasDeleteCmd, aliasImportCmd)
 
 	aliasGetCmd.Flags().String("domain", "", "domain to filter the list of aliases")
-	aliasGetCmd.Flags().String("format", "plain", "the output format (plain, csv, json, yaml)")
+	addFormatFlags(aliasGetCmd)
+	addFleetFlags(aliasGetCmd)
+	addUnicodeFlag(aliasGetCmd)
 	aliasAddCmd.PersistentFlags().String("address", "", "alias address [mandatory]")
 	aliasAddCmd.Flags().String("forward", "", "e-mail address(es) to forward to (comma separated) [mandatory]")
 	aliasDeleteCmd.PersistentFlags().String("address", "", "alias address [mandatory]")
@@ -28,7 +31,7 @@ var aliasGetCmd = &cobra.Command{
 	Short:            "Get existing a-mail aliases for users",
 	Long:             `Get all a-mail aliases for the users of the server, use the domain-flag to filter the output.`,
 	Args:             cobra.NoArgs,
-	Run:              getAlias,
+	RunE:             getAlias,
 	PersistentPreRun: initConfig,
 }
 
@@ -48,27 +51,47 @@ var aliasDeleteCmd = &cobra.Command{
 	Run:   delAlias,
 }
 
-func getAlias(cmd *cobra.Command, args []string) {
-	format := miab.PLAIN
-	if f, err := cmd.Flags().GetString("format"); err == nil {
-		format = miab.Format(f)
-	}
+var aliasImportCmd = &cobra.Command{
+	Use:   "import <file.csv>",
+	Short: "Add the e-mail aliases of a csv file",
+	Long: `Add the e-mail aliases of a csv file with the columns address and forwards-to, required aliases
+(managed by the server) are skipped. The result is reported for every alias.`,
+	Args: cobra.ExactArgs(1),
+	Run:  importAliases,
+}
+
+func getAlias(cmd *cobra.Command, args []string) error {
+	format := outputFormat(cmd)
 	domain, _ := cmd.Flags().GetString("domain")
 
-	aliasDomains, err := miab.GetAliases(&config)
-	if err != nil {
-		fmt.Printf("Error fetching e-mail aliasDomains: %v\n", err)
-		os.Exit(1)
-	}
+	fetch := func(c *miab.Config) (printable, error) {
+		aliasDomains, err := miab.GetAliases(c)
+		if err != nil {
+			return nil, err
+		}
 
-	if domain != "" {
-		for _, aliasDomain := range aliasDomains {
-			if aliasDomain.Domain == domain {
-				fmt.Println(aliasDomain.ToString(format))
+		if domain != "" {
+			for _, aliasDomain := range aliasDomains {
+				if aliasDomain.Domain == domain || miab.ToUnicode(aliasDomain.Domain) == domain {
+					return aliasDomain, nil
+				}
 			}
 		}
+		return aliasDomains, nil
 	}
-	fmt.Println(aliasDomains.ToString(format))
+
+	fetch = unicodeFetch(cmd, fetch)
+	if isFleet(cmd) {
+		return runFleet(cmd, format, fetch)
+	}
+
+	aliasDomains, err := fetch(&config)
+	if err != nil {
+		fmt.Printf("Error fetching e-mail aliasDomains: %v\n", err)
+		os.Exit(1)
+	}
+	printResult(aliasDomains, format)
+	return nil
 }
 
 func addAlias(cmd *cobra.Command, args []string) {
@@ -81,6 +104,40 @@ func addAlias(cmd *cobra.Command, args []string) {
 	}
 }
 
+func importAliases(cmd *cobra.Command, args []string) {
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	aliasDomains, err := miab.ParseAliasDomainsCsv(f)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, d := range aliasDomains {
+		for _, a := range d.Aliases {
+			if a.Required {
+				fmt.Printf("%s: skipped (required)\n", a.Address)
+				continue
+			}
+			if err := miab.AddAlias(&config, a.Address, strings.Join(a.ForwardsTo, ",")); err != nil {
+				fmt.Printf("%s: failed, %v\n", a.Address, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: added\n", a.Address)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
 func delAlias(cmd *cobra.Command, args []string) {
 	email, _ := cmd.Flags().GetString("address")
 
@@ -1,34 +1,44 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 )
 
 func init() {
 	rootCmd.AddCommand(dnsGetCmd)
 	dnsGetCmd.AddCommand(dnsSetCmd, dnsAddCmd, dnsDeleteCmd)
 
-	dnsGetCmd.Flags().String("format", "plain", "the output format (plain, csv, json, yaml)")
+	addFormatFlags(dnsGetCmd)
 	dnsGetCmd.Flags().String("domain", "", "Domain to filter the list of dns records, can be part of a domain (e.g. '.org'). Not considered if the qname-flag is set.")
 	dnsGetCmd.Flags().String("rtype", "", "The resource type to filter the output. (A, AAAA, TXT, CNAME, MX, SRV, SSHFP, CAA, NS)")
 	dnsGetCmd.Flags().String("qname", "", "The fully qualified domain to filter the output. NOTE: the rtype-flag defaults to 'A' if you use this flag, the domain-flag will be ignored.")
+	addFleetFlags(dnsGetCmd)
+	addUnicodeFlag(dnsGetCmd)
 	dnsGetCmd.Flags().BoolP("short", "s", false, "If the qname-flag is set, the output given is just the records value.")
 
 	dnsSetCmd.Flags().String("qname", "", "The fully qualified domain name for the record you are trying to set. It must be one of the domain names or a subdomain of one of the domain names hosted on the box. (Add mail users or aliases to add new domains.)")
 	dnsSetCmd.Flags().String("rtype", "A", "The resource type. Defaults to A if omitted. Possible values: A (an IPv4 address), AAAA (an IPv6 address), TXT (a text string), CNAME (an alias, which is a fully qualified domain name — don’t forget the final period), MX, SRV, SSHFP, CAA or NS.")
-	dnsSetCmd.Flags().String("value", "", "The record’s value. If the 'rtype' is A or AAAA and 'value' is empty or omitted, the IPv4 or IPv6 address of the remote host is used.")
+	dnsSetCmd.Flags().StringArray("value", nil, "The record’s value, repeat the flag to set multiple values (e.g. round-robin A records). If the 'rtype' is A or AAAA and 'value' is empty or omitted, the IPv4 or IPv6 address of the remote host is used.")
+	addWaitFlags(dnsSetCmd)
 
 	dnsAddCmd.Flags().String("qname", "", "The fully qualified domain name for the record you are trying to add. It must be one of the domain names or a subdomain of one of the domain names hosted on the box. (Add mail users or aliases to add new domains.)")
 	dnsAddCmd.Flags().String("rtype", "A", "The resource type. Defaults to A if omitted. Possible values: A (an IPv4 address), AAAA (an IPv6 address), TXT (a text string), CNAME (an alias, which is a fully qualified domain name — don’t forget the final period), MX, SRV, SSHFP, CAA or NS.")
 	dnsAddCmd.Flags().String("value", "", "The record’s value. If the 'rtype' is A or AAAA and 'value' is empty or omitted, the IPv4 or IPv6 address of the remote host is used.")
+	addWaitFlags(dnsAddCmd)
 
 	dnsDeleteCmd.Flags().String("qname", "", "The fully qualified domain name for the record you are trying to delete.")
 	dnsDeleteCmd.Flags().String("rtype", "A", "The resource type. Defaults to A if omitted. (A, AAAA, TXT, CNAME, MX, SRV, SSHFP, CAA, NS)")
 	dnsDeleteCmd.Flags().String("value", "", "The record’s value. If 'value' is empty or omitted, all records matching the qname-flag and rtype-flag will be deleted.")
+	addWaitFlags(dnsDeleteCmd)
 }
 
 var dnsGetCmd = &cobra.Command{
@@ -39,7 +49,7 @@ var dnsGetCmd = &cobra.Command{
 			to filter exactly one record, in this case the the domain-flag will be ignored. 
 			NOTE: If you use the qname-flag, the rtype-flag defaults to 'A'.`,
 	Args:             cobra.NoArgs,
-	Run:              getDns,
+	RunE:             getDns,
 	PersistentPreRun: initConfig,
 }
 
@@ -48,7 +58,9 @@ var dnsSetCmd = &cobra.Command{
 	Short: "Sets a custom DNS record replacing any existing records with the same `qname` and `rtype`",
 	Long: `Sets a custom DNS record replacing any existing records with the same 'qname' and 'rtype'. 
 Use 'set' (instead of 'add') when you only have one value for a 'qname' and 'rtype',
-such as typical A records (without round-robin).`,
+such as typical A records (without round-robin).
+Repeat the value-flag to replace the records with multiple values at once, only the missing
+records are added and the obsolete records deleted, on failure the changes are rolled back.`,
 	Args: cobra.NoArgs,
 	Run:  setDns,
 }
@@ -71,31 +83,27 @@ qname-flag and rtype-flag.`,
 	Run:  delDns,
 }
 
-func getDns(cmd *cobra.Command, args []string) {
+func getDns(cmd *cobra.Command, args []string) error {
 
-	format := miab.PLAIN
-	if f, err := cmd.Flags().GetString("format"); err == nil {
-		format = miab.Format(f)
-	}
+	format := outputFormat(cmd)
 
 	rtype := miab.NONE
 	if r, err := cmd.Flags().GetString("rtype"); err == nil {
 		rtype = miab.ResourceType(r)
 	}
 	qname, _ := cmd.Flags().GetString("qname")
+	d, _ := cmd.Flags().GetString("domain")
 
-	records, err := miab.GetDns(&config, qname, rtype)
-	if err != nil {
-		fmt.Printf("Error fetching dns records: %v\n", err)
-		os.Exit(1)
-	}
+	fetch := func(c *miab.Config) (printable, error) {
+		records, err := miab.GetDns(c, qname, rtype)
+		if err != nil {
+			return nil, err
+		}
 
-	if qname == "" {
-		d, err := cmd.Flags().GetString("domain")
-		if err == nil && d != "" {
+		if qname == "" && d != "" {
 			filtered := miab.Records{}
 			for _, record := range records {
-				if strings.Contains(record.QName, d) {
+				if strings.Contains(record.QName, d) || strings.Contains(miab.ToUnicode(record.QName), d) {
 
 					if rtype != miab.NONE {
 						if rtype == record.RType {
@@ -106,17 +114,29 @@ func getDns(cmd *cobra.Command, args []string) {
 					}
 				}
 			}
-			fmt.Println(filtered.ToString(format))
-			return
+			return filtered, nil
 		}
+		return records, nil
 	}
 
-	if qname != "" && len(records) == 1 {
-		fmt.Print(records[0].Value)
-		return
+	fetch = unicodeFetch(cmd, fetch)
+	if isFleet(cmd) {
+		return runFleet(cmd, format, fetch)
+	}
+
+	records, err := fetch(&config)
+	if err != nil {
+		fmt.Printf("Error fetching dns records: %v\n", err)
+		os.Exit(1)
+	}
+
+	if r := records.(miab.Records); qname != "" && len(r) == 1 {
+		fmt.Print(r[0].Value)
+		return nil
 	}
 
-	fmt.Println(records.ToString(format))
+	printResult(records, format)
+	return nil
 }
 
 func setDns(cmd *cobra.Command, args []string) {
@@ -125,18 +145,39 @@ func setDns(cmd *cobra.Command, args []string) {
 		rtype = miab.ResourceType(r)
 	}
 	qname, _ := cmd.Flags().GetString("qname")
-	value, _ := cmd.Flags().GetString("value")
+	values, _ := cmd.Flags().GetStringArray("value")
+
+	if len(values) > 1 {
+		for _, v := range values {
+			checkValue(rtype, v)
+		}
+		previous := waitValues(cmd, qname, rtype)
+		if _, err := miab.ReplaceRecordSet(&config, qname, rtype, values); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		waitForDns(cmd, qname, rtype, previous)
+		return
+	}
 
+	value := ""
+	if len(values) == 1 {
+		value = values[0]
+	}
+	checkValue(rtype, value)
 	if value == "" {
 		dynDnsUpdate(rtype, qname, value, false)
 	}
 
+	previous := waitValues(cmd, qname, rtype)
 	if s, err := miab.SetDns(&config, qname, rtype, value); !s || err != nil {
 		if err != nil {
 			fmt.Println(err)
 		}
 		os.Exit(1)
 	}
+
+	waitForDns(cmd, qname, rtype, previous)
 }
 
 func addDns(cmd *cobra.Command, args []string) {
@@ -147,15 +188,19 @@ func addDns(cmd *cobra.Command, args []string) {
 	qname, _ := cmd.Flags().GetString("qname")
 	value, _ := cmd.Flags().GetString("value")
 
+	checkValue(rtype, value)
 	if value == "" {
 		dynDnsUpdate(rtype, qname, value, true)
 	}
+	previous := waitValues(cmd, qname, rtype)
 	if s, err := miab.AddDns(&config, qname, rtype, value); !s || err != nil {
 		if err != nil {
 			fmt.Println(err)
 		}
 		os.Exit(1)
 	}
+
+	waitForDns(cmd, qname, rtype, previous)
 }
 
 func delDns(cmd *cobra.Command, args []string) {
@@ -166,12 +211,98 @@ func delDns(cmd *cobra.Command, args []string) {
 	qname, _ := cmd.Flags().GetString("qname")
 	value, _ := cmd.Flags().GetString("value")
 
+	checkValue(rtype, value)
+	previous := waitValues(cmd, qname, rtype)
 	if s, err := miab.DeleteDns(&config, qname, rtype, value); !s || err != nil {
 		if err != nil {
 			fmt.Println(err)
 		}
 		os.Exit(1)
 	}
+
+	waitForDns(cmd, qname, rtype, previous)
+}
+
+// registerResourceTypes registers the additional resource types of the config file ('resource_types', a map
+// of the resource type to the minimal Mail-in-a-Box version supporting it, e.g. 'TLSA: v60').
+func registerResourceTypes() {
+	for rtype, version := range viper.GetStringMapString("resource_types") {
+		if err := miab.RegisterResourceType(miab.ResourceType(rtype), version, nil); err != nil {
+			fmt.Printf("Invalid resource type '%s' in config: %v\n", rtype, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// addWaitFlags adds the flags to wait until a change is served by the nameserver of the box.
+func addWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("wait", 0, "wait up to the duration (e.g. 2m) until the change is served by the nameserver of the box")
+	cmd.Flags().String("nameserver", "", "the nameserver (host[:port]) to query if the wait-flag is set, defaults to the host of the endpoint")
+}
+
+// waitValues returns the values of the records of the qname and rtype, as returned by the API, if the wait-flag
+// is set.
+func waitValues(cmd *cobra.Command, qname string, rtype miab.ResourceType) []string {
+	if wait, _ := cmd.Flags().GetDuration("wait"); wait <= 0 {
+		return nil
+	}
+	records, err := miab.GetDns(&config, qname, rtype)
+	if err != nil {
+		fmt.Printf("Error fetching dns records: %v\n", err)
+		os.Exit(1)
+	}
+	var values []string
+	for _, r := range records {
+		if r.RType == rtype {
+			values = append(values, r.Value)
+		}
+	}
+	return values
+}
+
+// waitForDns waits until the records of the qname and rtype, as returned by the API, are served by the nameserver
+// of the box and the records removed by the change (previous values) are not, if the wait-flag is set. Other
+// records served for the qname (e.g. the automatic records of the box) are ignored.
+func waitForDns(cmd *cobra.Command, qname string, rtype miab.ResourceType, previous []string) {
+	wait, _ := cmd.Flags().GetDuration("wait")
+	if wait <= 0 {
+		return
+	}
+	nameserver, _ := cmd.Flags().GetString("nameserver")
+
+	values := waitValues(cmd, qname, rtype)
+	var absent []string
+	for _, p := range previous {
+		found := false
+		for _, v := range values {
+			found = found || v == p
+		}
+		if !found {
+			absent = append(absent, p)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	options := miab.WaitOptions{Nameserver: nameserver, Timeout: wait, Contains: true, Absent: absent}
+	d, err := miab.WaitForDns(ctx, &config, qname, rtype, values, options)
+	if err != nil {
+		fmt.Printf("The change is not served by the nameserver: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("The change is served by the nameserver after %s\n", d.Round(time.Millisecond))
+}
+
+// checkValue validates the value of a record before it is sent, the expected syntax is printed if it is invalid.
+func checkValue(rtype miab.ResourceType, value string) {
+	if value == "" || !rtype.IsValid() || cast.ToBool(setting(currentProfile(), "skip_validation", true)) {
+		return
+	}
+	if _, err := miab.ParseRecordValue(rtype, value); err != nil {
+		fmt.Println(err)
+		fmt.Printf("The value of a %s record has the format: %s\n", rtype, miab.ValueSyntax(rtype))
+		os.Exit(1)
+	}
 }
 
 func dynDnsUpdate(rtype miab.ResourceType, qname, value string, add bool) {
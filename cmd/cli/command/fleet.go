@@ -0,0 +1,243 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// printable is implemented by the results of the read commands.
+type printable interface {
+	Encode(w io.Writer, format miab.Format) error
+}
+
+// fetchFunc fetches the result of a read command from a Mail-in-a-Box instance.
+type fetchFunc func(c *miab.Config) (printable, error)
+
+// exit terminates the process with the exit code of a fleet, replaced by the tests.
+var exit = os.Exit
+
+type fleetResult struct {
+	profile string
+	result  printable
+	err     error
+}
+
+// addFleetFlags adds the flags to run a read command against several profiles.
+func addFleetFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-profiles", false, "run the command against all profiles of the config file in parallel")
+	cmd.Flags().StringSlice("profiles", nil, "run the command against the profiles (comma separated) in parallel")
+}
+
+// fleetProfiles returns the profiles selected via the all-profiles-flag or the profiles-flag.
+func fleetProfiles(cmd *cobra.Command) []string {
+	if all, _ := cmd.Flags().GetBool("all-profiles"); all {
+		return profileNames()
+	}
+	profiles, _ := cmd.Flags().GetStringSlice("profiles")
+	for i := range profiles {
+		profiles[i] = strings.ToLower(strings.TrimSpace(profiles[i]))
+	}
+	return profiles
+}
+
+// isFleet reports if a read command should run against several profiles.
+func isFleet(cmd *cobra.Command) bool {
+	all, _ := cmd.Flags().GetBool("all-profiles")
+	profiles, _ := cmd.Flags().GetStringSlice("profiles")
+	return all || len(profiles) > 0
+}
+
+// runFleet runs fetch against the selected profiles in parallel and prints the results. The configs (and
+// credentials) of the profiles are resolved one after another before, so prompts don't interfere. Structured output
+// (json, yaml, toml) is merged into a single document keyed by the profile name, tabular output (csv, table,
+// markdown, html) gets a profile column and ndjson a profile field. Any other output is prefixed by the profile name.
+// Failures are summarized at the end, the exit code is 1 if all profiles failed and 2 if some failed. An error is
+// returned if the output can't be encoded at all.
+func runFleet(cmd *cobra.Command, format miab.Format, fetch fetchFunc) error {
+	profiles := fleetProfiles(cmd)
+	if len(profiles) == 0 {
+		fmt.Println("No profiles found")
+		exit(1)
+		return nil
+	}
+
+	results := make([]fleetResult, len(profiles))
+	configs := make([]*miab.Config, len(profiles))
+	for i, p := range profiles {
+		results[i] = fleetResult{profile: p}
+		c, err := newConfig(p, false)
+		if err != nil {
+			results[i].err = fmt.Errorf("config is invalid: %v", err)
+			continue
+		}
+		configs[i] = c
+	}
+
+	var wg sync.WaitGroup
+	for i := range profiles {
+		if configs[i] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].result, results[i].err = fetch(configs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var failed []fleetResult
+	var ok []fleetResult
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		} else {
+			ok = append(ok, r)
+		}
+	}
+
+	out, errs, err := fleetOutput(ok, format)
+	if err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("unable to encode the output: %v", err)
+	}
+	for _, r := range ok {
+		if err, found := errs[r.profile]; found {
+			r.err = err
+			failed = append(failed, r)
+		}
+	}
+	fmt.Print(out)
+
+	if len(failed) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%d of %d profiles failed:\n", len(failed), len(profiles))
+	for _, r := range failed {
+		_, _ = fmt.Fprintf(os.Stderr, "  %s: %v\n", r.profile, r.err)
+	}
+	code := 2
+	if len(failed) == len(profiles) {
+		code = 1
+	}
+	exit(code)
+	return nil
+}
+
+// fleetOutput returns the merged output of the results in the format and the errors by profile, an error if the
+// merged output can't be encoded.
+func fleetOutput(results []fleetResult, format miab.Format) (string, map[string]error, error) {
+	errs := map[string]error{}
+	var buf bytes.Buffer
+
+	switch format.Name() {
+	case miab.JSON, miab.YAML, miab.TOML:
+		merged := map[string]interface{}{}
+		for _, r := range results {
+			if format.Name() != miab.TOML {
+				merged[r.profile] = r.result
+				continue
+			}
+			// the toml output of a result is decoded, as a toml document can't be an array
+			var b bytes.Buffer
+			if err := r.result.Encode(&b, format); err != nil {
+				errs[r.profile] = err
+				continue
+			}
+			t, err := toml.LoadBytes(b.Bytes())
+			if err != nil {
+				errs[r.profile] = err
+				continue
+			}
+			merged[r.profile] = t.ToMap()
+		}
+
+		var err error
+		var b []byte
+		switch format.Name() {
+		case miab.JSON:
+			if b, err = json.Marshal(merged); err == nil {
+				b = append(b, '\n')
+			}
+		case miab.YAML:
+			b, err = yaml.Marshal(merged)
+		case miab.TOML:
+			var t *toml.Tree
+			if t, err = toml.TreeFromMap(merged); err == nil {
+				var s string
+				s, err = t.ToTomlString()
+				b = []byte(s)
+			}
+		}
+		if err != nil {
+			return "", errs, err
+		}
+		return string(b), errs, nil
+
+	case miab.CSV, miab.TABLE, miab.MARKDOWN, miab.HTML:
+		var merged miab.Table
+		for _, r := range results {
+			t, err := miab.NewTable(r.result)
+			if err != nil {
+				errs[r.profile] = err
+				continue
+			}
+			merged.Title, merged.Columns = t.Title, append([]string{"profile"}, t.Columns...)
+			for _, row := range t.Rows {
+				merged.Rows = append(merged.Rows, append([]string{r.profile}, row...))
+			}
+		}
+		if merged.Columns == nil {
+			return "", errs, nil
+		}
+		if err := merged.Encode(&buf, format); err != nil {
+			return "", errs, err
+		}
+		if format.Name() == miab.TABLE {
+			buf.WriteByte('\n')
+		}
+		return buf.String(), errs, nil
+
+	case miab.NDJSON:
+		for _, r := range results {
+			var b bytes.Buffer
+			if err := r.result.Encode(&b, format); err != nil {
+				errs[r.profile] = err
+				continue
+			}
+			profile, _ := json.Marshal(r.profile)
+			for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+				if line = strings.TrimSpace(line); !strings.HasPrefix(line, "{") {
+					continue
+				}
+				sep := ","
+				if strings.TrimSpace(line[1:]) == "}" {
+					sep = ""
+				}
+				buf.WriteString(`{"profile":` + string(profile) + sep + line[1:] + "\n")
+			}
+		}
+		return buf.String(), errs, nil
+	}
+
+	for _, r := range results {
+		var b bytes.Buffer
+		if err := r.result.Encode(&b, format); err != nil {
+			errs[r.profile] = err
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+			buf.WriteString(fmt.Sprintf("[%s] %s\n", r.profile, line))
+		}
+	}
+	return buf.String(), errs, nil
+}
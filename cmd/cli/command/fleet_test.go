@@ -0,0 +1,215 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testFleetResults = []fleetResult{
+	{profile: "home", result: miab.Records{{QName: "home.example.org", RType: miab.A, Value: "192.0.2.1"}}},
+	{profile: "work", result: miab.Records{{QName: "work.example.org", RType: miab.A, Value: "192.0.2.2"}}},
+}
+
+// unencodable is a result that can't be encoded in any format.
+type unencodable struct {
+	C chan int
+}
+
+func (unencodable) Encode(io.Writer, miab.Format) error {
+	return errors.New("not encodable")
+}
+
+func TestFleetOutput(t *testing.T) {
+
+	broken := append(testFleetResults[:1:1], fleetResult{profile: "broken", result: unencodable{}})
+
+	testCases := []struct {
+		name    string
+		results []fleetResult
+		format  miab.Format
+		want    string
+		errs    []string
+		wantErr bool
+	}{
+		{"json", testFleetResults, miab.JSON,
+			`{"home":[{"qname":"home.example.org","rtype":"A","value":"192.0.2.1"}],` +
+				`"work":[{"qname":"work.example.org","rtype":"A","value":"192.0.2.2"}]}` + "\n", nil, false},
+		{"yaml", testFleetResults, miab.YAML,
+			"home:\n    - qname: home.example.org\n      rtype: A\n      value: 192.0.2.1\n" +
+				"work:\n    - qname: work.example.org\n      rtype: A\n      value: 192.0.2.2\n", nil, false},
+		{"toml", testFleetResults, miab.TOML,
+			"\n[home]\n\n  [[home.records]]\n    qname = \"home.example.org\"\n    rtype = \"A\"\n    value = \"192.0.2.1\"\n" +
+				"\n[work]\n\n  [[work.records]]\n    qname = \"work.example.org\"\n    rtype = \"A\"\n    value = \"192.0.2.2\"\n", nil, false},
+		{"csv", testFleetResults, miab.CSV,
+			"profile,qname,rtype,value\nhome,home.example.org,A,192.0.2.1\nwork,work.example.org,A,192.0.2.2\n", nil, false},
+		{"markdown", testFleetResults, miab.MARKDOWN,
+			"| profile | qname | rtype | value |\n| --- | --- | --- | --- |\n" +
+				"| home | home.example.org | A | 192.0.2.1 |\n| work | work.example.org | A | 192.0.2.2 |\n", nil, false},
+		{"ndjson", testFleetResults, miab.NDJSON,
+			`{"profile":"home","qname":"home.example.org","rtype":"A","value":"192.0.2.1"}` + "\n" +
+				`{"profile":"work","qname":"work.example.org","rtype":"A","value":"192.0.2.2"}` + "\n", nil, false},
+		{"plain", testFleetResults, miab.PLAIN,
+			"[home] home.example.org\tA\t192.0.2.1\n[work] work.example.org\tA\t192.0.2.2\n", nil, false},
+		{"no results", nil, miab.CSV, "", nil, false},
+		{"json unencodable", broken, miab.JSON, "", nil, true},
+		{"toml unencodable", broken, miab.TOML, "\n[home]\n\n  [[home.records]]\n    qname = \"home.example.org\"\n" +
+			"    rtype = \"A\"\n    value = \"192.0.2.1\"\n", []string{"broken"}, false},
+		{"csv unencodable", broken, miab.CSV, "profile,qname,rtype,value\nhome,home.example.org,A,192.0.2.1\n",
+			[]string{"broken"}, false},
+		{"ndjson unencodable", broken, miab.NDJSON,
+			`{"profile":"home","qname":"home.example.org","rtype":"A","value":"192.0.2.1"}` + "\n", []string{"broken"}, false},
+		{"plain unencodable", broken, miab.PLAIN, "[home] home.example.org\tA\t192.0.2.1\n", []string{"broken"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, errs, err := fleetOutput(tc.results, tc.format)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			var failed []string
+			for p := range errs {
+				failed = append(failed, p)
+			}
+			if !reflect.DeepEqual(failed, tc.errs) {
+				t.Errorf("want errors of: %v, got: %v", tc.errs, errs)
+			}
+		})
+	}
+}
+
+func TestFleetProfiles(t *testing.T) {
+
+	testConfigFile(t, testConfig)
+
+	testCases := []struct {
+		name  string
+		flags map[string]string
+		fleet bool
+		want  []string
+	}{
+		{"none", nil, false, []string{}},
+		{"profiles", map[string]string{"profiles": " Work ,home"}, true, []string{"work", "home"}},
+		{"all profiles", map[string]string{"all-profiles": "true"}, true, []string{"home", "work"}},
+		{"all profiles preferred", map[string]string{"all-profiles": "true", "profiles": "work"}, true, []string{"home", "work"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addFleetFlags(cmd)
+			for name, value := range tc.flags {
+				if err := cmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := isFleet(cmd); got != tc.fleet {
+				t.Errorf("isFleet, want: %v, got: %v", tc.fleet, got)
+			}
+			if got := fleetProfiles(cmd); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// startFleetBox starts a box answering the custom DNS records with a record of the name, if the password matches.
+func startFleetBox(t *testing.T, name, password string) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, p, _ := r.BasicAuth(); p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `[{"qname":"%s","rtype":"A","value":"192.0.2.1"}]`, name)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func TestRunFleet(t *testing.T) {
+
+	home := startFleetBox(t, "home.example.org", "home-secret")
+	testConfigFile(t, fmt.Sprintf(`
+user: admin@example.org
+profiles:
+  home:
+    endpoint: %s
+    password: home-secret
+  work:
+    endpoint: %s
+    password: work-secret
+  down:
+    endpoint: %s
+    password: down-secret
+  invalid:
+    password: home-secret
+`, home, startFleetBox(t, "work.example.org", "work-secret"), startFleetBox(t, "down.example.org", "other")))
+	// the profiles are resolved from the config file only, the profile without endpoint is invalid
+	t.Setenv("MIAB_PASSWORD", "env-secret")
+	setFlag(t, "password", "flag-secret")
+	setFlag(t, "endpoint", home)
+
+	code := 0
+	exit = func(c int) { code = c }
+	t.Cleanup(func() { exit = os.Exit })
+
+	testCases := []struct {
+		name     string
+		profiles string
+		want     string
+		code     int
+	}{
+		{"all succeeded", "home,work", "[home] home.example.org\tA\t192.0.2.1\n[work] work.example.org\tA\t192.0.2.1\n", 0},
+		{"some failed", "home,down,invalid", "[home] home.example.org\tA\t192.0.2.1\n", 2},
+		{"all failed", "down,unknown", "", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code = 0
+			cmd := &cobra.Command{}
+			addFleetFlags(cmd)
+			_ = cmd.Flags().Set("profiles", tc.profiles)
+
+			var err error
+			got := captureStdout(t, func() {
+				err = runFleet(cmd, miab.PLAIN, func(c *miab.Config) (printable, error) {
+					return miab.GetDns(c, "", miab.NONE)
+				})
+			})
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if code != tc.code {
+				t.Errorf("exit code, want: %d, got: %d", tc.code, code)
+			}
+		})
+	}
+
+	t.Run("unencodable", func(t *testing.T) {
+		cmd := &cobra.Command{}
+		addFleetFlags(cmd)
+		_ = cmd.Flags().Set("profiles", "home")
+		err := runFleet(cmd, miab.JSON, func(c *miab.Config) (printable, error) {
+			return unencodable{}, nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "unable to encode") {
+			t.Errorf("want encoding error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"os"
+)
+
+// addFormatFlags adds the flags to select the output format of a read command.
+func addFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", "plain", "the output format (plain, table, csv, json, ndjson, yaml, toml, markdown, html, template=<go template>, jsonpath=<jsonpath template>)")
+	cmd.Flags().StringSlice("columns", nil, "the columns to output in table format (comma separated)")
+	cmd.Flags().String("sort-by", "", "the column to sort the rows by in table format")
+	cmd.Flags().Bool("no-headers", false, "omit the header row in table format")
+	cmd.Flags().Bool("wide", false, "don't truncate long values to the terminal width in table format")
+}
+
+// outputFormat returns the output format selected via the flags.
+func outputFormat(cmd *cobra.Command) miab.Format {
+	format := miab.PLAIN
+	if f, err := cmd.Flags().GetString("format"); err == nil {
+		format = miab.Format(f)
+	}
+	if format != miab.TABLE {
+		return format
+	}
+
+	var options miab.TableOptions
+	options.Columns, _ = cmd.Flags().GetStringSlice("columns")
+	options.SortBy, _ = cmd.Flags().GetString("sort-by")
+	options.NoHeaders, _ = cmd.Flags().GetBool("no-headers")
+	if wide, _ := cmd.Flags().GetBool("wide"); !wide {
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			options.Width = w
+		}
+	}
+	return miab.TableFormat(options)
+}
+
+// printResult prints the result of a read command in the provided format, followed by a newline.
+func printResult(p printable, format miab.Format) {
+	var buf bytes.Buffer
+	if err := p.Encode(&buf, format); err != nil {
+		fmt.Println("Unable to format the output:", err)
+		exit(1)
+		return
+	}
+	if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	_, _ = buf.WriteTo(os.Stdout)
+}
+
+// addUnicodeFlag adds the flag to display internationalized domain names in Unicode instead of punycode.
+func addUnicodeFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("unicode", false, "display internationalized domain names in Unicode instead of punycode (e.g. müller.de)")
+}
+
+// unicodeFetch wraps fetch to convert the domains of the result to Unicode, if the unicode-flag is set.
+func unicodeFetch(cmd *cobra.Command, fetch fetchFunc) fetchFunc {
+	if u, _ := cmd.Flags().GetBool("unicode"); !u {
+		return fetch
+	}
+	return func(c *miab.Config) (printable, error) {
+		p, err := fetch(c)
+		if err != nil {
+			return nil, err
+		}
+		switch x := p.(type) {
+		case miab.Records:
+			return x.ToUnicode(), nil
+		case miab.MailDomains:
+			return x.ToUnicode(), nil
+		case miab.MailDomain:
+			return x.ToUnicode(), nil
+		case miab.AliasDomains:
+			return x.ToUnicode(), nil
+		case miab.AliasDomain:
+			return x.ToUnicode(), nil
+		}
+		return p, nil
+	}
+}
@@ -0,0 +1,139 @@
+package command
+
+import (
+	"errors"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOutputFormat(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		flags map[string]string
+		want  miab.Format
+	}{
+		{"default", nil, miab.PLAIN},
+		{"json", map[string]string{"format": "json"}, miab.JSON},
+		{"table options ignored", map[string]string{"format": "csv", "columns": "qname", "sort-by": "qname"}, miab.CSV},
+		{"table", map[string]string{"format": "table"}, miab.TableFormat(miab.TableOptions{})},
+		{"table options", map[string]string{"format": "table", "columns": "qname,value", "sort-by": "value",
+			"no-headers": "true", "wide": "true"},
+			miab.TableFormat(miab.TableOptions{Columns: []string{"qname", "value"}, SortBy: "value", NoHeaders: true})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addFormatFlags(cmd)
+			for name, value := range tc.flags {
+				if err := cmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := outputFormat(cmd); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+
+	records := miab.Records{
+		{QName: "b.example.org", RType: miab.A, Value: "192.0.2.2"},
+		{QName: "a.example.org", RType: miab.A, Value: "192.0.2.1"},
+	}
+
+	testCases := []struct {
+		name   string
+		result printable
+		format miab.Format
+		want   string
+		code   int
+	}{
+		{"newline added", records, miab.CSV, "qname,rtype,value\nb.example.org,A,192.0.2.2\na.example.org,A,192.0.2.1\n", 0},
+		{"single newline", records, miab.JSON, `[{"qname":"b.example.org","rtype":"A","value":"192.0.2.2"},` +
+			`{"qname":"a.example.org","rtype":"A","value":"192.0.2.1"}]` + "\n", 0},
+		{"table", records, miab.TableFormat(miab.TableOptions{Columns: []string{"qname", "value"}, SortBy: "qname"}),
+			"QNAME          VALUE\na.example.org  192.0.2.1\nb.example.org  192.0.2.2\n", 0},
+		{"table without headers", records, miab.TableFormat(miab.TableOptions{Columns: []string{"value"}, NoHeaders: true}),
+			"192.0.2.2\n192.0.2.1\n", 0},
+		{"empty", miab.Records{}, miab.CSV, "qname,rtype,value\n", 0},
+		{"unknown column", records, miab.TableFormat(miab.TableOptions{Columns: []string{"ttl"}}),
+			"Unable to format the output: ", 1},
+		{"unencodable", unencodable{}, miab.JSON, "Unable to format the output: not encodable\n", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code := 0
+			exit = func(c int) { code = c }
+			t.Cleanup(func() { exit = os.Exit })
+
+			got := captureStdout(t, func() { printResult(tc.result, tc.format) })
+			if tc.code != 0 && len(got) > len(tc.want) {
+				got = got[:len(tc.want)]
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if code != tc.code {
+				t.Errorf("exit code, want: %d, got: %d", tc.code, code)
+			}
+		})
+	}
+}
+
+func TestUnicodeFetch(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		unicode bool
+		result  printable
+		want    printable
+		wantErr bool
+	}{
+		{"records", true, miab.Records{{QName: "xn--mller-kva.de", RType: miab.A, Value: "192.0.2.1"}},
+			miab.Records{{QName: "müller.de", RType: miab.A, Value: "192.0.2.1"}}, false},
+		{"punycode", false, miab.Records{{QName: "xn--mller-kva.de", RType: miab.A, Value: "192.0.2.1"}},
+			miab.Records{{QName: "xn--mller-kva.de", RType: miab.A, Value: "192.0.2.1"}}, false},
+		{"mail domains", true, miab.MailDomains{{Domain: "xn--mller-kva.de", Users: miab.Users{{Email: "info@xn--mller-kva.de"}}}},
+			miab.MailDomains{{Domain: "müller.de", Users: miab.Users{{Email: "info@müller.de"}}}}, false},
+		{"mail domain", true, miab.MailDomain{Domain: "xn--mller-kva.de"}, miab.MailDomain{Domain: "müller.de"}, false},
+		{"alias domains", true, miab.AliasDomains{{Domain: "xn--mller-kva.de", Aliases: miab.Aliases{{
+			Address: "info@xn--mller-kva.de", ForwardsTo: []string{"admin@xn--mller-kva.de"}}}}},
+			miab.AliasDomains{{Domain: "müller.de", Aliases: miab.Aliases{{
+				Address: "info@müller.de", ForwardsTo: []string{"admin@müller.de"}}}}}, false},
+		{"alias domain", true, miab.AliasDomain{Domain: "xn--mller-kva.de"}, miab.AliasDomain{Domain: "müller.de"}, false},
+		{"other", true, unencodable{}, unencodable{}, false},
+		{"error", true, nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addUnicodeFlag(cmd)
+			if tc.unicode {
+				_ = cmd.Flags().Set("unicode", "true")
+			}
+			fetch := unicodeFetch(cmd, func(c *miab.Config) (printable, error) {
+				if tc.result == nil {
+					return nil, errors.New("failed")
+				}
+				return tc.result, nil
+			})
+
+			got, err := fetch(nil)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
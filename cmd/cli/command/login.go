@@ -0,0 +1,190 @@
+package command
+
+import (
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"github.com/rverst/go-miab/cmd/cli/credential"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"os"
+	"path"
+)
+
+func init() {
+	rootCmd.AddCommand(loginCmd, logoutCmd)
+
+	loginCmd.Flags().String("store", "", "the credential store to use (keyring, file), defaults to 'credential_store' of the config file or keyring")
+	loginCmd.Flags().Bool("no-verify", false, "save the credentials without verifying them against the Mail-in-a-Box API")
+	logoutCmd.Flags().String("store", "", "the credential store to use (keyring, file), defaults to 'credential_store' of the config file or keyring")
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Save the password in the credential store",
+	Long: `Save the password of the user in the credential store, so it hasn't to be kept in the config file.
+The user and the endpoint are taken from the flags, environment variables or the profile in use,
+the password is prompted for. Supported stores are the keyring of the OS and a passphrase-encrypted
+file ('credential_file', default is $HOME/.config/go-miab/credentials.age), the passphrase can be
+provided via the environment variable MIAB_PASSPHRASE.`,
+	Args:             cobra.NoArgs,
+	Run:              login,
+	PersistentPreRun: initConfigFileOptional,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:              "logout",
+	Short:            "Remove the password from the credential store",
+	Long:             `Remove the password of the user from the credential store.`,
+	Args:             cobra.NoArgs,
+	Run:              logout,
+	PersistentPreRun: initConfigFileOptional,
+}
+
+func initConfigFileOptional(cmd *cobra.Command, args []string) {
+	viper.AutomaticEnv()
+	if err := readConfigFile(); err != nil && viper.GetString("profile") != "" {
+		fmt.Println("Can't read config:", err)
+		os.Exit(1)
+	}
+}
+
+// fileStores holds the file stores in use by the path of the file.
+var fileStores = map[string]credential.Store{}
+
+// credentialStore returns the credential store configured for the profile.
+func credentialStore(profile string, override bool) (credential.Store, error) {
+	store := cast.ToString(setting(profile, "credential_store", override))
+	return newCredentialStore(store, profile, override)
+}
+
+func newCredentialStore(store, profile string, override bool) (credential.Store, error) {
+	switch store {
+	case "", credential.Keyring:
+		return credential.NewKeyringStore(), nil
+	case credential.File:
+		file := cast.ToString(setting(profile, "credential_file", override))
+		if file == "" {
+			home, err := homedir.Dir()
+			if err != nil {
+				return nil, err
+			}
+			file = path.Join(home, ".config", "go-miab", "credentials.age")
+		}
+		// the store of a file is shared, so the passphrase is only prompted for once (e.g. by several profiles)
+		if s, ok := fileStores[file]; ok {
+			return s, nil
+		}
+		fileStores[file] = credential.NewFileStore(file, passphrase(file))
+		return fileStores[file], nil
+	default:
+		return nil, fmt.Errorf("'%s' is not a valid credential store (keyring, file)", store)
+	}
+}
+
+// passphrase returns a function providing the passphrase for the credential file, either from the environment
+// variable MIAB_PASSPHRASE or prompted for. The passphrase of a new file has to be confirmed.
+func passphrase(file string) func() (string, error) {
+	var pass string
+	return func() (string, error) {
+		if p, ok := os.LookupEnv("MIAB_PASSPHRASE"); ok {
+			return p, nil
+		}
+		if pass != "" {
+			return pass, nil
+		}
+
+		p, err := prompt(fmt.Sprintf("Passphrase for %s: ", file))
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			c, err := prompt("Confirm passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if c != p {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+		}
+		pass = p
+		return pass, nil
+	}
+}
+
+// prompt reads a secret from the terminal without echoing it.
+func prompt(msg string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("unable to prompt for input, stdin is not a terminal")
+	}
+	_, _ = fmt.Fprint(os.Stderr, msg)
+	b, err := term.ReadPassword(fd)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// loginStore returns the store selected via the store-flag or configured for the profile in use.
+func loginStore(cmd *cobra.Command) (credential.Store, string, string) {
+	profile := currentProfile()
+	user := cast.ToString(setting(profile, "user", true))
+	endpoint := cast.ToString(setting(profile, "endpoint", true))
+	if user == "" || endpoint == "" {
+		fmt.Println("The user and the endpoint have to be provided via flags, environment variables or the config file")
+		os.Exit(1)
+	}
+
+	s, _ := cmd.Flags().GetString("store")
+	if s == "" {
+		s = cast.ToString(setting(profile, "credential_store", true))
+	}
+	store, err := newCredentialStore(s, profile, true)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return store, user, endpoint
+}
+
+func login(cmd *cobra.Command, args []string) {
+	store, user, endpoint := loginStore(cmd)
+
+	password, err := prompt(fmt.Sprintf("Password for %s at %s: ", user, endpoint))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if noVerify, _ := cmd.Flags().GetBool("no-verify"); !noVerify {
+		cfg, err := miab.NewConfig(user, password, endpoint, tlsOptions(currentProfile(), true)...)
+		if err != nil {
+			fmt.Println("Config is invalid:", err)
+			os.Exit(1)
+		}
+		if _, err := miab.GetDns(cfg, "", miab.NONE); err != nil {
+			fmt.Println("Unable to verify the credentials:", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := store.Set(credential.Key(user, endpoint), password); err != nil {
+		fmt.Println("Unable to save the credentials:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Credentials for %s at %s saved\n", user, endpoint)
+}
+
+func logout(cmd *cobra.Command, args []string) {
+	store, user, endpoint := loginStore(cmd)
+
+	if err := store.Delete(credential.Key(user, endpoint)); err != nil {
+		fmt.Println("Unable to remove the credentials:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Credentials for %s at %s removed\n", user, endpoint)
+}
@@ -0,0 +1,183 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"filippo.io/age"
+	"fmt"
+	"github.com/rverst/go-miab/cmd/cli/credential"
+	"golang.org/x/term"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCredentialFile writes the secrets to a credential file encrypted with the passphrase, returns the path of the file.
+func writeCredentialFile(t *testing.T, pass string, secrets map[string]string) string {
+	recipient, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the default work factor of age takes about a second per encryption
+	recipient.SetWorkFactor(10)
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewEncoder(w).Encode(secrets); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "credentials.age")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// resetFileStores drops the shared file stores now and at the end of the test.
+func resetFileStores(t *testing.T) {
+	fileStores = map[string]credential.Store{}
+	t.Cleanup(func() { fileStores = map[string]credential.Store{} })
+}
+
+func TestNewCredentialStore(t *testing.T) {
+
+	testConfigFile(t, `
+credential_file: /tmp/credentials.age
+profiles:
+  other:
+    credential_file: /tmp/other.age
+`)
+	resetFileStores(t)
+
+	testCases := []struct {
+		name    string
+		store   string
+		profile string
+		keyring bool
+		wantErr bool
+	}{
+		{"default", "", "", true, false},
+		{"keyring", "keyring", "", true, false},
+		{"file", "file", "", false, false},
+		{"file of profile", "file", "other", false, false},
+		{"unknown", "vault", "", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := newCredentialStore(tc.store, tc.profile, false)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if keyring := s == credential.NewKeyringStore(); keyring != tc.keyring {
+				t.Errorf("keyring, want: %v, got: %v", tc.keyring, keyring)
+			}
+		})
+	}
+
+	// the store of a file is shared by the profiles
+	a, _ := newCredentialStore("file", "", false)
+	b, _ := newCredentialStore("file", "", false)
+	c, _ := newCredentialStore("file", "other", false)
+	if a != b || a == c {
+		t.Error("want the same store for the same file only")
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		t.Skip("stdin is a terminal")
+	}
+
+	testCases := []struct {
+		name       string
+		passphrase string
+		set        bool
+		want       string
+		wantErr    bool
+	}{
+		{"env", "env-passphrase", true, "env-passphrase", false},
+		{"empty env", "", true, "", false},
+		{"prompt", "", false, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MIAB_PASSPHRASE", tc.passphrase)
+			if !tc.set {
+				_ = os.Unsetenv("MIAB_PASSPHRASE")
+			}
+
+			got, err := passphrase("credentials.age")()
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLookupPassword(t *testing.T) {
+
+	const user, endpoint = "admin@example.org", "https://box.example.org"
+	file := writeCredentialFile(t, "passphrase", map[string]string{credential.Key(user, endpoint): "file-secret"})
+	testConfigFile(t, fmt.Sprintf(`
+credential_store: file
+credential_file: %s
+profiles:
+  file: {}
+  command:
+    password_command: echo command-secret
+  vault:
+    credential_store: vault
+  keyring:
+    credential_store: ""
+`, file))
+
+	testCases := []struct {
+		name       string
+		profile    string
+		endpoint   string
+		passphrase string
+		want       string
+		wantErr    bool
+	}{
+		{"file", "file", endpoint, "passphrase", "file-secret", false},
+		{"not found", "file", "https://other.example.org", "passphrase", "", false},
+		{"wrong passphrase", "file", endpoint, "wrong", "", true},
+		{"command", "command", endpoint, "", "command-secret", false},
+		{"unknown store", "vault", endpoint, "", "", true},
+		{"default keyring", "keyring", endpoint, "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetFileStores(t)
+			t.Setenv("MIAB_PASSPHRASE", tc.passphrase)
+
+			got, err := lookupPassword(tc.profile, user, tc.endpoint, false)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
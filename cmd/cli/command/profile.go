@@ -0,0 +1,197 @@
+package command
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd, profileUseCmd, profileShowCmd)
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage the profiles of the config file",
+	Long: `Manage the profiles of the config file. Profiles hold the settings of several Mail-in-a-Box instances:
+
+  current_profile: prod
+  profiles:
+    prod:
+      user: admin@example.org
+      password: secret
+      endpoint: https://box.example.org
+    staging:
+      user: admin@example.net
+      password: secret
+      endpoint: https://box.example.net
+
+The profile is selected via the profile-flag, the environment variable MIAB_PROFILE or 'current_profile'.
+Settings missing in a profile are taken from the top-level of the config file.`,
+	PersistentPreRun: initConfigFile,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles of the config file",
+	Long:  `List the profiles of the config file, the profile in use is marked with an asterisk.`,
+	Args:  cobra.NoArgs,
+	Run:   listProfiles,
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Set the current profile in the config file",
+	Long:  `Set the current profile in the config file, it is used if neither the profile-flag nor MIAB_PROFILE is set.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   useProfile,
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "Show the settings of a profile",
+	Long: `Show the settings of a profile in the config file (passwords are masked), defaults to the profile in use.
+Flags and environment variables (e.g. MIAB_PASSWORD) are not shown.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  showProfile,
+}
+
+func initConfigFile(cmd *cobra.Command, args []string) {
+	viper.AutomaticEnv()
+	if err := readConfigFile(); err != nil {
+		fmt.Println("Can't read config:", err)
+		os.Exit(1)
+	}
+}
+
+// currentProfile returns the name of the profile in use.
+func currentProfile() string {
+	if p := viper.GetString("profile"); p != "" {
+		return p
+	}
+	return viper.GetString("current_profile")
+}
+
+// profileNames returns the sorted names of all profiles of the config file.
+func profileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func listProfiles(cmd *cobra.Command, args []string) {
+	current := strings.ToLower(currentProfile())
+	for _, name := range profileNames() {
+		if name == current {
+			fmt.Printf("* %s\n", name)
+		} else {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+func useProfile(cmd *cobra.Command, args []string) {
+	profile := strings.ToLower(args[0])
+	if !viper.IsSet("profiles." + profile) {
+		fmt.Printf("Profile '%s' not found\n", args[0])
+		os.Exit(1)
+	}
+
+	if err := setConfigFileValue("current_profile", profile); err != nil {
+		fmt.Println("Can't write config:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Switched to profile '%s'\n", profile)
+}
+
+func showProfile(cmd *cobra.Command, args []string) {
+	profile := currentProfile()
+	if len(args) == 1 {
+		profile = args[0]
+	}
+	profile = strings.ToLower(profile)
+	if profile != "" && !viper.IsSet("profiles."+profile) {
+		fmt.Printf("Profile '%s' not found\n", profile)
+		os.Exit(1)
+	}
+
+	if profile == "" {
+		fmt.Println("profile: (none)")
+	} else {
+		fmt.Printf("profile: %s\n", profile)
+	}
+	for _, key := range []string{"user", "password", "endpoint", "ca_file", "client_cert", "client_key", "pin_sha256", "insecure"} {
+		v := setting(profile, key, false)
+		if v == nil {
+			continue
+		}
+		s := cast.ToString(v)
+		if key == "pin_sha256" {
+			s = strings.Join(cast.ToStringSlice(v), ", ")
+		}
+		if key == "password" && s != "" {
+			s = "********"
+		}
+		fmt.Printf("%s: %s\n", key, s)
+	}
+}
+
+// setConfigFileValue sets a top-level key of the yaml config file. The file is written anew, the comments are kept
+// but the formatting (e.g. indentation and quotes) may change. Config files in other formats are not changed.
+func setConfigFileValue(key, value string) error {
+	file := viper.ConfigFileUsed()
+	if ext := strings.ToLower(filepath.Ext(file)); ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("'%s' is not a yaml file, set '%s' in the file by hand", file, key)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("'%s' is not a yaml mapping", file)
+	}
+
+	root := doc.Content[0]
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1].SetString(value)
+			found = true
+			break
+		}
+	}
+	if !found {
+		k := &yaml.Node{}
+		k.SetString(key)
+		v := &yaml.Node{}
+		v.SetString(value)
+		root.Content = append(root.Content, k, v)
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, out, info.Mode())
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestListProfiles(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		profile string
+		want    string
+	}{
+		{"current profile", "", "  home\n* work\n"},
+		{"profile flag", "Home", "* home\n  work\n"},
+		{"unknown profile", "other", "  home\n  work\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testConfigFile(t, testConfig)
+			if tc.profile != "" {
+				setFlag(t, "profile", tc.profile)
+			}
+			if got := captureStdout(t, func() { listProfiles(nil, nil) }); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestShowProfile(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"current profile", nil, "profile: work\nuser: admin@example.org\npassword: ********\n" +
+			"endpoint: https://work.example.org\ninsecure: true\n"},
+		{"profile", []string{"Home"}, "profile: home\nuser: home@example.org\nendpoint: https://box.example.org\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testConfigFile(t, testConfig)
+			// the settings are taken from the config file only
+			t.Setenv("MIAB_USER", "env@example.org")
+			t.Setenv("MIAB_PASSWORD", "env-secret")
+			t.Setenv("MIAB_INSECURE", "false")
+			setFlag(t, "endpoint", "https://flag.example.org")
+
+			if got := captureStdout(t, func() { showProfile(nil, tc.args) }); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSetConfigFileValue(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"replace", "miab.yaml", "# the profile\ncurrent_profile: work # in use\nuser: admin@example.org\n",
+			"current_profile", "# the profile\ncurrent_profile: home # in use\nuser: admin@example.org\n", false},
+		{"add", "miab.yml", "user: admin@example.org\n",
+			"current_profile", "user: admin@example.org\ncurrent_profile: home\n", false},
+		{"json", "miab.json", `{"current_profile": "work"}`, "current_profile", `{"current_profile": "work"}`, true},
+		{"toml", "miab.toml", "current_profile = \"work\"\n", "current_profile", "current_profile = \"work\"\n", true},
+		{"no mapping", "miab.yaml", "- work\n", "current_profile", "- work\n", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tc.file)
+			if err := ioutil.WriteFile(file, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.SetConfigFile(file)
+
+			err := setConfigFileValue(tc.key, "home")
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, b)
+			}
+		})
+	}
+}
@@ -3,11 +3,14 @@ package command
 import (
 	"fmt"
 	"github.com/mitchellh/go-homedir"
+	"github.com/rverst/go-miab/cmd/cli/credential"
 	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
 	"path"
+	"strings"
 )
 
 //these variables are set in the build process via `ldflags`
@@ -20,6 +23,9 @@ var (
 var cfgFile string
 var config miab.Config
 
+// fileConfig holds the settings of the config file only, without flags and environment variables.
+var fileConfig = viper.New()
+
 var rootCmd = &cobra.Command{
 	Use:   "miab",
 	Short: "Miab is a cli tool for the Mail-in-a-Box API",
@@ -34,50 +40,196 @@ func init() {
 	rootCmd.PersistentFlags().StringP("user", "u", "", "user to authenticate, can be set via environment variable (MIAB_USER) or config file")
 	rootCmd.PersistentFlags().StringP("password", "p", "", "password to authenticate, can be set via environment variable (MIAB_PASSWORD) or config file")
 	rootCmd.PersistentFlags().StringP("endpoint", "e", "", "api endpoint, can be set via environment variable (MIAB_ENDPOINT) or config file")
+	rootCmd.PersistentFlags().String("profile", "", "profile of the config file to use, can be set via environment variable (MIAB_PROFILE)")
+	rootCmd.PersistentFlags().Bool("skip-validation", false, "skip the client-side validation of dns records, can be set via environment variable (MIAB_SKIP_VALIDATION) or config file")
+
+	bindFlags()
+}
 
+// bindFlags binds the persistent flags and the environment variables (MIAB_*) to the settings.
+func bindFlags() {
 	viper.SetEnvPrefix("miab")
 
 	_ = viper.BindPFlag("user", rootCmd.PersistentFlags().Lookup("user"))
 	_ = viper.BindPFlag("password", rootCmd.PersistentFlags().Lookup("password"))
 	_ = viper.BindPFlag("endpoint", rootCmd.PersistentFlags().Lookup("endpoint"))
+	_ = viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	_ = viper.BindPFlag("skip_validation", rootCmd.PersistentFlags().Lookup("skip-validation"))
 }
 
 func initConfig(cmd *cobra.Command, args []string) {
 
 	viper.AutomaticEnv()
-	cfg, err := miab.NewConfig(viper.GetString("user"), viper.GetString("password"), viper.GetString("endpoint"))
-	if err != nil && (viper.GetString("user") == "" || viper.GetString("password") == "" || viper.GetString("endpoint") == "") {
-		// not all parameters might have been provided, let's try the config file
-		if cfgFile != "" {
-			viper.SetConfigFile(cfgFile)
-		} else {
-
-			home, err := homedir.Dir()
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-
-			viper.AddConfigPath(path.Join(home, ".config", "go-miab"))
-			viper.SetConfigName("miab")
-		}
+	if isFleet(cmd) {
+		initConfigFile(cmd, args)
+		registerResourceTypes()
+		return
+	}
 
-		if err := viper.ReadInConfig(); err != nil {
+	profile := viper.GetString("profile")
+	if err := readConfigFile(); err != nil {
+		// the config file is optional, as long as all parameters are provided via flags or environment variables
+		if profile != "" || viper.GetString("user") == "" || viper.GetString("endpoint") == "" {
 			fmt.Println("Can't read config:", err)
 			os.Exit(1)
 		}
-		cfg, err = miab.NewConfig(viper.GetString("user"), viper.GetString("password"), viper.GetString("endpoint"))
-		if err != nil {
-			fmt.Println("Config is invalid:", err)
-			os.Exit(1)
-		}
-	} else if err != nil {
+	}
+
+	registerResourceTypes()
+	cfg, err := newConfig(currentProfile(), true)
+	if err != nil {
 		fmt.Println("Config is invalid:", err)
 		os.Exit(1)
 	}
 	config = *cfg
 }
 
+// readConfigFile reads the config file provided via the config-flag or from the default location.
+func readConfigFile() error {
+	if viper.ConfigFileUsed() != "" {
+		return nil
+	}
+
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+	} else {
+		home, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+
+		viper.AddConfigPath(path.Join(home, ".config", "go-miab"))
+		viper.SetConfigName("miab")
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	fileConfig = viper.New()
+	fileConfig.SetConfigFile(viper.ConfigFileUsed())
+	return fileConfig.ReadInConfig()
+}
+
+// setting returns the value of the key for the profile. If override is set, values provided via flags or
+// environment variables take precedence, otherwise only the config file is read (e.g. for the profiles of a fleet).
+// Settings that are missing in the profile fall back to the top-level settings of the config file.
+func setting(profile, key string, override bool) interface{} {
+	settings := fileConfig
+	if override {
+		if f := rootCmd.PersistentFlags().Lookup(strings.Replace(key, "_", "-", -1)); f != nil && f.Changed {
+			return viper.Get(key)
+		}
+		if v, ok := os.LookupEnv("MIAB_" + strings.ToUpper(key)); ok {
+			return v
+		}
+		settings = viper.GetViper()
+	}
+	if profile != "" {
+		if v := settings.Get(fmt.Sprintf("profiles.%s.%s", profile, key)); v != nil {
+			return v
+		}
+	}
+	return settings.Get(key)
+}
+
+// newConfig creates the miab.Config for the profile, an empty profile uses the top-level settings.
+func newConfig(profile string, override bool) (*miab.Config, error) {
+	if profile != "" && !viper.IsSet("profiles."+profile) {
+		return nil, fmt.Errorf("profile '%s' not found", profile)
+	}
+
+	get := func(key string) string {
+		return cast.ToString(setting(profile, key, override))
+	}
+
+	password := get("password")
+	if password == "" && get("user") != "" {
+		var err error
+		if password, err = lookupPassword(profile, get("user"), get("endpoint"), override); err != nil {
+			return nil, err
+		}
+	}
+
+	options := tlsOptions(profile, override)
+	if cast.ToBool(setting(profile, "skip_validation", override)) {
+		options = append(options, miab.WithoutValidation())
+	}
+
+	cfg, err := miab.NewConfig(get("user"), password, get("endpoint"), options...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Insecure() {
+		_, _ = fmt.Fprintln(os.Stderr, "WARNING: TLS certificate verification is disabled (insecure), "+
+			"the connection and your credentials are NOT protected! Consider using 'pin_sha256' instead.")
+	}
+	return cfg, nil
+}
+
+// lookupPassword returns the password from the 'password_command' or the credential store of the profile.
+// If no credential store is configured, an unavailable keyring (e.g. on a headless host) is treated like a missing
+// password.
+func lookupPassword(profile, user, endpoint string, override bool) (string, error) {
+	if command := cast.ToString(setting(profile, "password_command", override)); command != "" {
+		return credential.Command(command)
+	}
+
+	store, err := credentialStore(profile, override)
+	if err != nil {
+		return "", err
+	}
+	password, err := store.Get(credential.Key(user, endpoint))
+	if err == credential.ErrNotFound {
+		return "", nil
+	} else if err != nil {
+		if cast.ToString(setting(profile, "credential_store", override)) == "" {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to read credential store: %v", err)
+	}
+	return password, nil
+}
+
+// tlsOptions returns the TLS options (ca_file, client_cert, client_key, pin_sha256, insecure)
+// of the profile, provided via environment variables or the config file.
+func tlsOptions(profile string, override bool) []miab.Option {
+	var options []miab.Option
+	if f := cast.ToString(setting(profile, "ca_file", override)); f != "" {
+		options = append(options, miab.WithCAFile(f))
+	}
+	cert := cast.ToString(setting(profile, "client_cert", override))
+	key := cast.ToString(setting(profile, "client_key", override))
+	if cert != "" || key != "" {
+		options = append(options, miab.WithClientCertificate(cert, key))
+	}
+	if pins := stringList(setting(profile, "pin_sha256", override)); len(pins) > 0 {
+		options = append(options, miab.WithPinnedCertificates(pins...))
+	}
+	if cast.ToBool(setting(profile, "insecure", override)) {
+		options = append(options, miab.WithInsecureSkipVerify())
+	}
+	return options
+}
+
+// stringList returns the values of a list from the config file or of a comma separated string (e.g. from an
+// environment variable), like the DNS_* variables of dnsupdate.
+func stringList(v interface{}) []string {
+	values := cast.ToStringSlice(v)
+	if s, ok := v.(string); ok {
+		values = []string{s}
+	}
+
+	var res []string
+	for _, value := range values {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
 // Execute is the main entrance point for the cli parser an should be called from `func main()`
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,145 @@
+package command
+
+import (
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testConfigFile resets the settings and reads the content as config file, returns the path of the file.
+func testConfigFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "miab.yaml")
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reset := func() {
+		viper.Reset()
+		bindFlags()
+		fileConfig = viper.New()
+		cfgFile = ""
+	}
+	reset()
+	t.Cleanup(reset)
+
+	cfgFile = file
+	viper.AutomaticEnv()
+	if err := readConfigFile(); err != nil {
+		t.Fatalf("unable to read the config: %v", err)
+	}
+	return file
+}
+
+// setFlag sets a persistent flag of the root command until the end of the test.
+func setFlag(t *testing.T, name, value string) {
+	f := rootCmd.PersistentFlags().Lookup(name)
+	previous := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	f.Changed = true
+	t.Cleanup(func() {
+		_ = f.Value.Set(previous)
+		f.Changed = false
+	})
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- string(b)
+	}()
+	f()
+	_ = w.Close()
+	return <-out
+}
+
+const testConfig = `
+endpoint: https://box.example.org
+user: admin@example.org
+current_profile: work
+profiles:
+  work:
+    endpoint: https://work.example.org
+    password: work-secret
+    insecure: true
+  home:
+    user: home@example.org
+`
+
+func TestSetting(t *testing.T) {
+
+	testConfigFile(t, testConfig)
+	t.Setenv("MIAB_PASSWORD", "env-secret")
+	t.Setenv("MIAB_INSECURE", "false")
+	setFlag(t, "endpoint", "https://flag.example.org")
+
+	testCases := []struct {
+		name     string
+		profile  string
+		key      string
+		override bool
+		want     interface{}
+	}{
+		{"profile", "work", "password", false, "work-secret"},
+		{"env overrides profile", "work", "password", true, "env-secret"},
+		{"env ignored", "", "password", false, nil},
+		{"env", "", "password", true, "env-secret"},
+		{"flag ignored", "work", "endpoint", false, "https://work.example.org"},
+		{"flag overrides profile", "work", "endpoint", true, "https://flag.example.org"},
+		{"flag ignored at top-level", "home", "endpoint", false, "https://box.example.org"},
+		{"top-level", "work", "user", false, "admin@example.org"},
+		{"profile over top-level", "home", "user", false, "home@example.org"},
+		{"env of other key ignored", "work", "insecure", false, true},
+		{"missing", "home", "ca_file", false, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := setting(tc.profile, tc.key, tc.override); got != tc.want {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInitConfig_Fleet(t *testing.T) {
+
+	file := testConfigFile(t, testConfig+`
+resource_types:
+  tlsa: v0.40
+`)
+	viper.Reset()
+	bindFlags()
+	cfgFile = file
+
+	rtype := miab.TLSA
+	if rtype.IsValid() {
+		t.Skip("TLSA already registered")
+	}
+	cmd := &cobra.Command{}
+	addFleetFlags(cmd)
+	_ = cmd.Flags().Set("all-profiles", "true")
+	initConfig(cmd, nil)
+
+	if !rtype.IsValid() {
+		t.Error("resource types of the config not registered")
+	}
+	if got := profileNames(); len(got) != 2 {
+		t.Errorf("want the profiles of the config, got: %v", got)
+	}
+}
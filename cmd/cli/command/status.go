@@ -0,0 +1,60 @@
+package command
+
+import (
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	addFormatFlags(statusCmd)
+	statusCmd.Flags().Bool("problems", false, "only print the checks with warnings or errors")
+	addFleetFlags(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:              "status",
+	Short:            "Run the system status checks",
+	Long:             `Run the system status checks of the server, use the problems-flag to filter the output.`,
+	Args:             cobra.NoArgs,
+	RunE:             getStatus,
+	PersistentPreRun: initConfig,
+}
+
+func getStatus(cmd *cobra.Command, args []string) error {
+	format := outputFormat(cmd)
+	problems, _ := cmd.Flags().GetBool("problems")
+
+	fetch := func(c *miab.Config) (printable, error) {
+		checks, err := miab.GetStatus(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if problems {
+			filtered := miab.StatusChecks{}
+			for _, check := range checks {
+				if check.Type == miab.Warning || check.Type == miab.Error {
+					filtered = append(filtered, check)
+				}
+			}
+			return filtered, nil
+		}
+		return checks, nil
+	}
+
+	if isFleet(cmd) {
+		return runFleet(cmd, format, fetch)
+	}
+
+	checks, err := fetch(&config)
+	if err != nil {
+		fmt.Printf("Error running status checks: %v\n", err)
+		os.Exit(1)
+	}
+	printResult(checks, format)
+	return nil
+}
@@ -9,12 +9,14 @@ import (
 
 func init() {
 	rootCmd.AddCommand(userGetCmd)
-	userGetCmd.AddCommand(userAddCmd, userRemoveCmd)
+	userGetCmd.AddCommand(userAddCmd, userRemoveCmd, userImportCmd)
 	userAddCmd.AddCommand(userAddPrivilege)
 	userRemoveCmd.AddCommand(userDeletePrivilege)
 
 	userGetCmd.Flags().String("domain", "", "domain to filter the list of email users")
-	userGetCmd.Flags().String("format", "plain", "the output format (plain, csv, json, yaml)")
+	addFormatFlags(userGetCmd)
+	addFleetFlags(userGetCmd)
+	addUnicodeFlag(userGetCmd)
 	userAddCmd.PersistentFlags().String("email", "", "email address of the user [mandatory]")
 	userRemoveCmd.PersistentFlags().String("email", "", "email address of the user [mandatory]")
 	userAddCmd.Flags().String("pass", "", "password for the new user [mandatory]")
@@ -30,7 +32,7 @@ var userGetCmd = &cobra.Command{
 	Short:            "Get existing mail users",
 	Long:             `Get all mail user of the server, use the domain-flag to filter the output.`,
 	Args:             cobra.NoArgs,
-	Run:              getUser,
+	RunE:             getUser,
 	PersistentPreRun: initConfig,
 }
 
@@ -50,6 +52,15 @@ var userRemoveCmd = &cobra.Command{
 	Run:   delUser,
 }
 
+var userImportCmd = &cobra.Command{
+	Use:   "import <file.csv>",
+	Short: "Add the mail users of a csv file",
+	Long: `Add the mail users of a csv file with the columns email and password and the optional column privileges,
+users that are not active (archived) are skipped. The result is reported for every user.`,
+	Args: cobra.ExactArgs(1),
+	Run:  importUsers,
+}
+
 var userAddPrivilege = &cobra.Command{
 	Use:   "privilege",
 	Short: "Add the admin privilege to an mail user",
@@ -66,29 +77,38 @@ var userDeletePrivilege = &cobra.Command{
 	Run:   delPrivilege,
 }
 
-func getUser(cmd *cobra.Command, args []string) {
-	format := miab.PLAIN
-	if f, err := cmd.Flags().GetString("format"); err == nil {
-		format = miab.Format(f)
-	}
+func getUser(cmd *cobra.Command, args []string) error {
+	format := outputFormat(cmd)
+	d, _ := cmd.Flags().GetString("domain")
 
-	users, err := miab.GetUsers(&config)
-	if err != nil {
-		fmt.Printf("Error fetching e-mail users: %v\n", err)
-		os.Exit(1)
-	}
+	fetch := func(c *miab.Config) (printable, error) {
+		users, err := miab.GetUsers(c)
+		if err != nil {
+			return nil, err
+		}
 
-	d, err := cmd.Flags().GetString("domain")
-	if err == nil && d != "" {
-		for _, u := range users {
-			if u.Domain == d {
-				fmt.Println(u.ToString(format))
-				return
+		if d != "" {
+			for _, u := range users {
+				if u.Domain == d || miab.ToUnicode(u.Domain) == d {
+					return u, nil
+				}
 			}
 		}
+		return users, nil
+	}
+
+	fetch = unicodeFetch(cmd, fetch)
+	if isFleet(cmd) {
+		return runFleet(cmd, format, fetch)
 	}
 
-	fmt.Println(users.ToString(format))
+	users, err := fetch(&config)
+	if err != nil {
+		fmt.Printf("Error fetching e-mail users: %v\n", err)
+		os.Exit(1)
+	}
+	printResult(users, format)
+	return nil
 }
 
 func addUser(cmd *cobra.Command, args []string) {
@@ -101,6 +121,67 @@ func addUser(cmd *cobra.Command, args []string) {
 	}
 }
 
+func importUsers(cmd *cobra.Command, args []string) {
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	mailDomains, err := miab.ParseMailDomainsCsv(f)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, m := range mailDomains {
+		for _, u := range m.Users {
+			if u.Status != miab.Active {
+				fmt.Printf("%s: skipped (%s)\n", u.Email, u.Status)
+				continue
+			}
+			if u.Password == "" {
+				fmt.Printf("%s: failed, no password\n", u.Email)
+				failed++
+				continue
+			}
+			if err := miab.AddUser(&config, u.Email, u.Password); err != nil {
+				fmt.Printf("%s: failed, %v\n", u.Email, err)
+				failed++
+				continue
+			}
+			if isAdmin(u) {
+				if err := miab.AddPrivileges(&config, u.Email); err != nil {
+					fmt.Printf("%s: added, adding the admin privilege failed, %v\n", u.Email, err)
+					failed++
+					continue
+				}
+			}
+			fmt.Printf("%s: added\n", u.Email)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// isAdmin reports if the user has the admin privilege.
+func isAdmin(u miab.User) bool {
+	switch p := u.Privileges.(type) {
+	case string:
+		return p == "admin"
+	case []interface{}:
+		for _, i := range p {
+			if i == "admin" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func delUser(cmd *cobra.Command, args []string) {
 	email, _ := cmd.Flags().GetString("email")
 
@@ -2,13 +2,16 @@ package command
 
 import (
 	"fmt"
+	"github.com/rverst/go-miab/miab"
 	"github.com/spf13/cobra"
+	"os"
 )
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
 
 	versionCmd.Flags().BoolP("extended", "x", false, "")
+	versionCmd.Flags().Bool("server", false, "print the version of the Mail-in-a-Box server instead")
 }
 
 var versionCmd = &cobra.Command{
@@ -21,6 +24,17 @@ var versionCmd = &cobra.Command{
 
 func printVersion(cmd *cobra.Command, args []string) {
 
+	if b, _ := cmd.Flags().GetBool("server"); b {
+		initConfig(cmd, args)
+		v, err := miab.GetVersion(&config)
+		if err != nil {
+			fmt.Printf("Error fetching the version: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(v)
+		return
+	}
+
 	if b, _ := cmd.Flags().GetBool("extended"); !b {
 		fmt.Println(Version)
 		return
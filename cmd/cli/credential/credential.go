@@ -0,0 +1,66 @@
+// Package credential provides stores for the credentials of Mail-in-a-Box instances, so they don't have to be
+// kept in plain text in the config file or passed on the command-line.
+package credential
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNotFound is returned if the store holds no secret for the key.
+var ErrNotFound = errors.New("credentials not found")
+
+// Store persists secrets (e.g. passwords) by a key.
+type Store interface {
+	// Get returns the secret for the key, ErrNotFound if there is none.
+	Get(key string) (string, error)
+	// Set saves the secret for the key, an existing secret is replaced.
+	Set(key, secret string) error
+	// Delete removes the secret for the key.
+	Delete(key string) error
+}
+
+// Keyring - store the credentials in the keyring of the OS (e.g. Secret Service, macOS Keychain)
+const Keyring = `keyring`
+
+// File - store the credentials in a passphrase-encrypted file
+const File = `file`
+
+// Key returns the key for the credentials of a user at a Mail-in-a-Box instance.
+func Key(user, endpoint string) string {
+	return fmt.Sprintf("%s@%s", user, strings.TrimRight(strings.ToLower(endpoint), "/"))
+}
+
+// Command runs the command (e.g. `pass show miab`) in a shell and returns the first line of the output
+// as the secret.
+func Command(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			return "", fmt.Errorf("password command failed: %v: %s", err, s)
+		}
+		return "", fmt.Errorf("password command failed: %v", err)
+	}
+
+	secret := strings.SplitN(string(out), "\n", 2)[0]
+	secret = strings.TrimRight(secret, "\r")
+	if secret == "" {
+		return "", errors.New("password command returned an empty password")
+	}
+	return secret, nil
+}
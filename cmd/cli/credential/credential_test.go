@@ -0,0 +1,62 @@
+package credential
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+
+	testCases := []struct {
+		user     string
+		endpoint string
+		want     string
+	}{
+		{"admin@example.org", "https://box.example.org", "admin@example.org@https://box.example.org"},
+		{"admin@example.org", "https://Box.Example.org/", "admin@example.org@https://box.example.org"},
+	}
+	for _, tc := range testCases {
+		if got := Key(tc.user, tc.endpoint); got != tc.want {
+			t.Errorf("Key(%s, %s): want %s, got: %s", tc.user, tc.endpoint, tc.want, got)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands require a POSIX shell")
+	}
+
+	testCases := []struct {
+		name      string
+		command   string
+		want      string
+		wantError bool
+	}{
+		{"single line", "echo secret", "secret", false},
+		{"first line", "printf 'secret\\nuser: admin\\n'", "secret", false},
+		{"crlf", "printf 'secret\\r\\n'", "secret", false},
+		{"no newline", "printf secret", "secret", false},
+		{"spaces kept", "echo ' sec ret'", " sec ret", false},
+		{"empty output", "true", "", true},
+		{"empty first line", "printf '\\nsecret\\n'", "", true},
+		{"failure", "echo oops >&2; exit 3", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Command(tc.command)
+			if tc.wantError {
+				if err == nil {
+					t.Errorf("want error, got: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
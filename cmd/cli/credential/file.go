@@ -0,0 +1,118 @@
+package credential
+
+import (
+	"bytes"
+	"encoding/json"
+	"filippo.io/age"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// workFactor is the scrypt work factor (log2) used to encrypt the file, 0 uses the default of age. Only lowered by
+// the tests, a file is always decrypted with the work factor it was written with.
+var workFactor int
+
+type fileStore struct {
+	path       string
+	passphrase func() (string, error)
+}
+
+// NewFileStore returns a Store that keeps the secrets in a file, encrypted with a passphrase (age/scrypt,
+// see https://age-encryption.org). The passphrase function is called whenever the file is read or written.
+func NewFileStore(path string, passphrase func() (string, error)) Store {
+	return &fileStore{path: path, passphrase: passphrase}
+}
+
+func (f *fileStore) Get(key string) (string, error) {
+	secrets, _, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	s, ok := secrets[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fileStore) Set(key, secret string) error {
+	secrets, pass, err := f.read()
+	if err != nil {
+		return err
+	}
+	secrets[key] = secret
+	return f.write(secrets, pass)
+}
+
+func (f *fileStore) Delete(key string) error {
+	secrets, pass, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, key)
+	return f.write(secrets, pass)
+}
+
+// read decrypts the file and returns the secrets and the passphrase used. A missing file holds no secrets.
+func (f *fileStore) read() (map[string]string, string, error) {
+	secrets := map[string]string{}
+	pass, err := f.passphrase()
+	if err != nil {
+		return nil, "", err
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return secrets, pass, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	identity, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return nil, "", err
+	}
+	r, err := age.Decrypt(bytes.NewReader(b), identity)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := json.NewDecoder(r).Decode(&secrets); err != nil {
+		return nil, "", err
+	}
+	return secrets, pass, nil
+}
+
+func (f *fileStore) write(secrets map[string]string, pass string) error {
+	recipient, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return err
+	}
+	if workFactor > 0 {
+		recipient.SetWorkFactor(workFactor)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(secrets); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,99 @@
+package credential
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the default work factor of age takes about a second per encryption
+	workFactor = 10
+	os.Exit(m.Run())
+}
+
+func testFileStore(t *testing.T, pass string) (Store, string) {
+	dir, err := ioutil.TempDir("", "credential")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	file := filepath.Join(dir, "sub", "credentials.age")
+	return NewFileStore(file, func() (string, error) { return pass, nil }), file
+}
+
+func TestFileStore(t *testing.T) {
+
+	store, file := testFileStore(t, "passphrase")
+
+	if _, err := store.Get("key"); err != ErrNotFound {
+		t.Errorf("Get on missing file: want %v, got: %v", ErrNotFound, err)
+	}
+	if err := store.Set("key", "secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("other", "secret2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("unable to read the file: %v", err)
+	}
+	if fi, _ := os.Stat(file); fi.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got: %v", fi.Mode().Perm())
+	}
+	if len(b) == 0 || string(b[:21]) != "age-encryption.org/v1" {
+		t.Errorf("file is not age encrypted: %q", b)
+	}
+
+	reopened := NewFileStore(file, func() (string, error) { return "passphrase", nil })
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{"key", "secret"},
+		{"other", "secret2"},
+	}
+	for _, tc := range testCases {
+		got, err := reopened.Get(tc.key)
+		if err != nil {
+			t.Errorf("Get(%s): %v", tc.key, err)
+		} else if got != tc.want {
+			t.Errorf("Get(%s): want %s, got: %s", tc.key, tc.want, got)
+		}
+	}
+
+	if err := reopened.Delete("key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := reopened.Get("key"); err != ErrNotFound {
+		t.Errorf("Get after Delete: want %v, got: %v", ErrNotFound, err)
+	}
+	if err := reopened.Delete("key"); err != ErrNotFound {
+		t.Errorf("Delete of a missing key: want %v, got: %v", ErrNotFound, err)
+	}
+	if got, _ := reopened.Get("other"); got != "secret2" {
+		t.Errorf("Get(other) after Delete: want secret2, got: %s", got)
+	}
+}
+
+func TestFileStoreWrongPassphrase(t *testing.T) {
+
+	store, file := testFileStore(t, "passphrase")
+	if err := store.Set("key", "secret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	wrong := NewFileStore(file, func() (string, error) { return "wrong", nil })
+	if _, err := wrong.Get("key"); err == nil || err == ErrNotFound {
+		t.Errorf("Get with wrong passphrase: want decryption error, got: %v", err)
+	}
+	if err := wrong.Set("key", "overwritten"); err == nil {
+		t.Error("Set with wrong passphrase: want error, got: nil")
+	}
+	if got, _ := store.Get("key"); got != "secret" {
+		t.Errorf("secret changed by the wrong passphrase: %s", got)
+	}
+}
@@ -0,0 +1,35 @@
+package credential
+
+import (
+	"github.com/zalando/go-keyring"
+)
+
+const keyringService = `go-miab`
+
+type keyringStore struct{}
+
+// NewKeyringStore returns a Store using the keyring of the OS (Secret Service on Linux, Keychain on macOS
+// and the Credential Manager on Windows).
+func NewKeyringStore() Store {
+	return keyringStore{}
+}
+
+func (keyringStore) Get(key string) (string, error) {
+	s, err := keyring.Get(keyringService, key)
+	if err == keyring.ErrNotFound {
+		return "", ErrNotFound
+	}
+	return s, err
+}
+
+func (keyringStore) Set(key, secret string) error {
+	return keyring.Set(keyringService, key, secret)
+}
+
+func (keyringStore) Delete(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if err == keyring.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cache holds the values last set per record (box/domain/rtype), so records are only updated if the value
+// changes. It is persisted to a JSON file, if a file is configured.
+type cache struct {
+	mu     sync.Mutex
+	file   string
+	values map[string]string
+}
+
+// newCache creates a cache and loads the values of the file, if it exists.
+func newCache(file string) (*cache, error) {
+	c := &cache{file: file, values: map[string]string{}}
+	if file == "" {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.values); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// set stores the value of the record, an empty value removes it.
+func (c *cache) set(key, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.values[key]; ok && old == value || !ok && value == "" {
+		return nil
+	}
+	if value == "" {
+		delete(c.values, key)
+	} else {
+		c.values[key] = value
+	}
+	return c.save()
+}
+
+// save writes the values to the file, the file is replaced atomically.
+func (c *cache) save() error {
+	if c.file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.file), filepath.Base(c.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
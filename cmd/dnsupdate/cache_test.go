@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCache_Set(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		key   string
+		value string
+		want  map[string]string
+		saved bool
+	}{
+		{"new value", "box/c.example.org/A", "192.0.2.3", map[string]string{
+			"box/a.example.org/A": "192.0.2.1", "box/b.example.org/A": "192.0.2.2", "box/c.example.org/A": "192.0.2.3",
+		}, true},
+		{"changed value", "box/a.example.org/A", "192.0.2.3", map[string]string{
+			"box/a.example.org/A": "192.0.2.3", "box/b.example.org/A": "192.0.2.2",
+		}, true},
+		{"same value", "box/a.example.org/A", "192.0.2.1", map[string]string{
+			"box/a.example.org/A": "192.0.2.1", "box/b.example.org/A": "192.0.2.2",
+		}, false},
+		{"removed", "box/a.example.org/A", "", map[string]string{
+			"box/b.example.org/A": "192.0.2.2",
+		}, true},
+		{"removed unknown", "box/c.example.org/A", "", map[string]string{
+			"box/a.example.org/A": "192.0.2.1", "box/b.example.org/A": "192.0.2.2",
+		}, false},
+	}
+
+	initial := `{"box/a.example.org/A": "192.0.2.1", "box/b.example.org/A": "192.0.2.2"}`
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "cache.json")
+			if err := ioutil.WriteFile(file, []byte(initial), 0600); err != nil {
+				t.Fatal(err)
+			}
+			c, err := newCache(file)
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+
+			if err := c.set(tc.key, tc.value); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if !reflect.DeepEqual(c.values, tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, c.values)
+			}
+			if v, ok := c.get(tc.key); v != tc.value || ok != (tc.value != "") {
+				t.Errorf("get, want: %s, got: %s (%v)", tc.value, v, ok)
+			}
+
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := string(b) != initial; saved != tc.saved {
+				t.Errorf("saved, want: %v, got: %v", tc.saved, saved)
+			}
+			loaded, err := newCache(file)
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if !reflect.DeepEqual(loaded.values, tc.want) {
+				t.Errorf("loaded, want: %v, got: %v", tc.want, loaded.values)
+			}
+			if files, _ := filepath.Glob(file + ".*"); len(files) > 0 {
+				t.Errorf("temporary files left: %v", files)
+			}
+		})
+	}
+}
+
+func TestNewCache(t *testing.T) {
+
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"no file", "", false},
+		{"missing file", filepath.Join(dir, "missing.json"), false},
+		{"invalid file", invalid, true},
+		{"directory", dir, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := newCache(tc.file)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if err := c.set("box/a.example.org/A", "192.0.2.1"); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if tc.file == "" {
+				return
+			}
+			if loaded, err := newCache(tc.file); err != nil || loaded.values["box/a.example.org/A"] != "192.0.2.1" {
+				t.Errorf("value not saved: %v, %v", loaded, err)
+			}
+		})
+	}
+}
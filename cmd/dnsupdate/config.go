@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"github.com/rverst/go-miab/miab"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval = 30 * time.Minute
+	minInterval     = 30 * time.Second
+)
+
+// config describes the boxes and the domains to update, read from a YAML or TOML file. The environment variables
+// (DNS_*) override the settings of the file.
+type config struct {
+	Interval duration                `json:"interval"`
+	Cache    string                  `json:"cache"`
+	Listen   string                  `json:"listen"`
+	Boxes    []boxConfig             `json:"boxes"`
+	Sources  map[string]sourceConfig `json:"sources"`
+	Domains  []domainConfig          `json:"domains"`
+	Records  []recordConfig          `json:"records"`
+	Failover []failoverConfig        `json:"failover"`
+
+	DynDns  *dyndnsConfig  `json:"dyndns"`
+	RFC2136 *gatewayConfig `json:"rfc2136"`
+
+	Notifiers        []notifierConfig `json:"notifiers"`
+	FailureThreshold int              `json:"failure_threshold"`
+
+	notifications []*notification
+}
+
+// boxConfig holds the endpoint and credentials of a Mail-in-a-Box instance.
+type boxConfig struct {
+	Name       string   `json:"name"`
+	Endpoint   string   `json:"endpoint"`
+	User       string   `json:"user"`
+	Password   string   `json:"password"`
+	CAFile     string   `json:"ca_file"`
+	ClientCert string   `json:"client_cert"`
+	ClientKey  string   `json:"client_key"`
+	PinSHA256  []string `json:"pin_sha256"`
+	Insecure   bool     `json:"insecure"`
+
+	client *miab.Config
+}
+
+// domainConfig describes the records of a domain to update.
+type domainConfig struct {
+	Name     string   `json:"name"`
+	Box      string   `json:"box"`
+	RTypes   []string `json:"rtypes"`
+	Interval duration `json:"interval"`
+	Source   string   `json:"source"`
+
+	// the AAAA record is built from the prefix of the source and an interface identifier (e.g. for LAN hosts)
+	InterfaceID  string `json:"interface_id"`
+	MAC          string `json:"mac"`
+	PrefixLength int    `json:"prefix_length"`
+
+	client *miab.Config
+	source source
+	iid    net.IP
+}
+
+// duration is a time.Duration given as duration string (e.g. "30m") or number of seconds.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case float64:
+		*d = duration(x * float64(time.Second))
+		return nil
+	case string:
+		p, err := parseDuration(x)
+		if err != nil {
+			return err
+		}
+		*d = duration(p)
+		return nil
+	}
+	return fmt.Errorf("invalid duration %s", b)
+}
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+// parseDuration parses a duration string or a number of seconds.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s', use a number of seconds or a duration like '30m'", s)
+	}
+	return d, nil
+}
+
+// configError lists all errors of the configuration.
+type configError []error
+
+func (e configError) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = "  - " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(s, "\n")
+}
+
+// loadConfig reads the config file (if any), applies the environment and validates the result.
+func loadConfig(file string) (*config, error) {
+
+	cfg := &config{}
+	if file != "" {
+		if err := readConfigFile(file, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs configError
+	errs = append(errs, cfg.applyEnv()...)
+	cfg.setDefaults()
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// readConfigFile decodes a YAML, TOML or JSON file (by extension) into the config, unknown keys are rejected.
+func readConfigFile(file string, cfg *config) error {
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	case ".toml":
+		var t *toml.Tree
+		if t, err = toml.LoadBytes(b); err == nil {
+			m = t.ToMap()
+		}
+	case ".json":
+		err = json.Unmarshal(b, &m)
+	default:
+		return fmt.Errorf("config file %s: unsupported file type, use .yaml, .toml or .json", file)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", file, err)
+	}
+
+	// the file is decoded via json to share the tags and the parsing of durations among the formats
+	j, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", file, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("config file %s: %v", file, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the config with the environment variables. The endpoint, credentials and TLS settings apply to
+// the first box, DNS_DOMAINS replaces the domains and DNS_A and DNS_AAAA the record types of all domains.
+func (c *config) applyEnv() []error {
+	var errs []error
+
+	if s, ok := os.LookupEnv("DNS_INTERVAL"); ok && s != "" {
+		if d, err := parseDuration(s); err != nil {
+			errs = append(errs, fmt.Errorf("DNS_INTERVAL: %v", err))
+		} else {
+			c.Interval = duration(d)
+		}
+	}
+
+	box := boxConfig{}
+	if len(c.Boxes) > 0 {
+		box = c.Boxes[0]
+	}
+	envBox := false
+	for env, field := range map[string]*string{
+		"DNS_ENDPOINT":    &box.Endpoint,
+		"DNS_USER":        &box.User,
+		"DNS_PASSWORD":    &box.Password,
+		"DNS_CA_FILE":     &box.CAFile,
+		"DNS_CLIENT_CERT": &box.ClientCert,
+		"DNS_CLIENT_KEY":  &box.ClientKey,
+	} {
+		if s := os.Getenv(env); s != "" {
+			*field = s
+			envBox = true
+		}
+	}
+	if p := os.Getenv("DNS_PIN_SHA256"); p != "" {
+		box.PinSHA256 = strings.Split(p, ",")
+		envBox = true
+	}
+	if s := os.Getenv("DNS_INSECURE"); s != "" {
+		if b, err := strconv.ParseBool(s); err != nil {
+			errs = append(errs, fmt.Errorf("DNS_INSECURE: '%s' is not a boolean", s))
+		} else {
+			box.Insecure = b
+			envBox = true
+		}
+	}
+	if envBox {
+		if len(c.Boxes) > 0 {
+			c.Boxes[0] = box
+		} else {
+			c.Boxes = []boxConfig{box}
+		}
+	}
+
+	if f := os.Getenv("DNS_CACHE"); f != "" {
+		c.Cache = f
+	}
+	if l := os.Getenv("DNS_LISTEN"); l != "" {
+		c.Listen = l
+	}
+
+	if d := os.Getenv("DNS_DOMAINS"); d != "" {
+		sep := ","
+		if strings.Contains(d, ";") {
+			sep = ";"
+		}
+		c.Domains = nil
+		for _, name := range strings.Split(d, sep) {
+			if name = strings.TrimSpace(name); name != "" {
+				c.Domains = append(c.Domains, domainConfig{Name: name})
+			}
+		}
+	}
+
+	rtypes := []string{}
+	envRTypes := false
+	for _, rtype := range []miab.ResourceType{miab.A, miab.AAAA} {
+		s := os.Getenv("DNS_" + string(rtype))
+		if s == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("DNS_%s: '%s' is not a boolean", rtype, s))
+			continue
+		}
+		envRTypes = true
+		if b {
+			rtypes = append(rtypes, string(rtype))
+		}
+	}
+	if envRTypes {
+		for i := range c.Domains {
+			c.Domains[i].RTypes = rtypes
+		}
+	}
+
+	if s := os.Getenv("DNS_SOURCE"); s != "" {
+		for i := range c.Domains {
+			c.Domains[i].Source = s
+		}
+	}
+	return errs
+}
+
+// setDefaults sets the defaults of the settings not given, intervals are at least 30 seconds.
+func (c *config) setDefaults() {
+	if c.Interval == 0 {
+		c.Interval = duration(defaultInterval)
+	} else if c.Interval < duration(minInterval) {
+		c.Interval = duration(minInterval)
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	for i := range c.Boxes {
+		if c.Boxes[i].Name == "" && len(c.Boxes) == 1 {
+			c.Boxes[i].Name = "default"
+		}
+	}
+	if c.DynDns != nil {
+		for i := range c.DynDns.Hosts {
+			if h := &c.DynDns.Hosts[i]; h.Box == "" && len(c.Boxes) == 1 {
+				h.Box = c.Boxes[0].Name
+			}
+		}
+	}
+	if c.RFC2136 != nil {
+		for i := range c.RFC2136.Keys {
+			if k := &c.RFC2136.Keys[i]; k.Box == "" && len(c.Boxes) == 1 {
+				k.Box = c.Boxes[0].Name
+			}
+		}
+	}
+	for i := range c.Domains {
+		d := &c.Domains[i]
+		if d.Box == "" && len(c.Boxes) == 1 {
+			d.Box = c.Boxes[0].Name
+		}
+		if d.RTypes == nil {
+			if d.InterfaceID != "" || d.MAC != "" {
+				d.RTypes = []string{string(miab.AAAA)}
+			} else {
+				d.RTypes = []string{string(miab.A)}
+			}
+		}
+		if d.PrefixLength == 0 {
+			d.PrefixLength = defaultPrefixLength
+		}
+		for j, rtype := range d.RTypes {
+			d.RTypes[j] = strings.ToUpper(strings.TrimSpace(rtype))
+		}
+		if d.Interval == 0 {
+			d.Interval = c.Interval
+		} else if d.Interval < duration(minInterval) {
+			d.Interval = duration(minInterval)
+		}
+		if d.Source == "" {
+			d.Source = "box"
+		}
+	}
+	for i := range c.Records {
+		r := &c.Records[i]
+		if r.Box == "" && len(c.Boxes) == 1 {
+			r.Box = c.Boxes[0].Name
+		}
+		r.RType = strings.ToUpper(strings.TrimSpace(r.RType))
+		if r.Interval == 0 {
+			r.Interval = c.Interval
+		} else if r.Interval < duration(minInterval) {
+			r.Interval = duration(minInterval)
+		}
+		if r.Source == "" && r.Template != "" {
+			r.Source = "http"
+		}
+	}
+	for i := range c.Failover {
+		f := &c.Failover[i]
+		if f.Box == "" && len(c.Boxes) == 1 {
+			f.Box = c.Boxes[0].Name
+		}
+		f.RType = strings.ToUpper(strings.TrimSpace(f.RType))
+		if f.RType == "" {
+			f.RType = string(miab.A)
+		}
+		if f.Interval == 0 {
+			f.Interval = duration(defaultCheckInterval)
+		} else if f.Interval < duration(minInterval) {
+			f.Interval = duration(minInterval)
+		}
+		if f.Rise == 0 {
+			f.Rise = defaultRise
+		}
+		if f.Fall == 0 {
+			f.Fall = defaultFall
+		}
+		if f.Check.Timeout == 0 {
+			f.Check.Timeout = duration(defaultCheckTimeout)
+		}
+		if f.Check.Host == "" {
+			f.Check.Host = f.Name
+		}
+		if f.Check.Path == "" {
+			f.Check.Path = "/"
+		}
+	}
+}
+
+// validate checks the config and creates the clients of the boxes, all errors found are returned.
+func (c *config) validate() []error {
+	var errs []error
+
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("listen: has to be [host]:port: %v", err))
+		}
+	}
+
+	if len(c.Boxes) == 0 {
+		errs = append(errs, fmt.Errorf("boxes: no box configured (config file or DNS_ENDPOINT, DNS_USER and DNS_PASSWORD)"))
+	}
+	boxes := map[string]*miab.Config{}
+	for i := range c.Boxes {
+		b := &c.Boxes[i]
+		name := fmt.Sprintf("boxes[%d]", i)
+		if b.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: 'name' not specified", name))
+			continue
+		}
+		name = fmt.Sprintf("box '%s'", b.Name)
+		if _, ok := boxes[b.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: configured more than once", name))
+			continue
+		}
+		client, err := miab.NewConfig(b.User, b.Password, b.Endpoint, b.options()...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+		b.client = client
+		boxes[b.Name] = client
+	}
+
+	sources := map[string]source{
+		"http": newHttpSource(defaultEchoUrls),
+		"stun": stunSource{server: defaultStunServer},
+	}
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "box" {
+			errs = append(errs, fmt.Errorf("source 'box': the name is reserved"))
+			continue
+		}
+		s, err := newSource(c.Sources[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source '%s': %v", name, err))
+			continue
+		}
+		sources[name] = s
+	}
+
+	for i, nc := range c.Notifiers {
+		n, err := newNotification(nc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: %v", i, err))
+			continue
+		}
+		c.notifications = append(c.notifications, n)
+	}
+	if c.FailureThreshold < 1 {
+		errs = append(errs, fmt.Errorf("failure_threshold: has to be at least 1"))
+	}
+
+	if c.DynDns != nil {
+		errs = append(errs, c.DynDns.validate(boxes)...)
+	}
+
+	if c.RFC2136 != nil {
+		errs = append(errs, c.RFC2136.validate(boxes)...)
+	}
+
+	if len(c.Domains) == 0 && len(c.Records) == 0 && len(c.Failover) == 0 && c.DynDns == nil && c.RFC2136 == nil {
+		errs = append(errs, fmt.Errorf("domains: no domain configured (config file or DNS_DOMAINS)"))
+	}
+	records := map[string]bool{}
+	for i := range c.Domains {
+		d := &c.Domains[i]
+		name := fmt.Sprintf("domains[%d]", i)
+		if d.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: 'name' not specified", name))
+			continue
+		}
+		name = fmt.Sprintf("domain '%s'", d.Name)
+
+		if d.Box == "" {
+			errs = append(errs, fmt.Errorf("%s: 'box' not specified, there is more than one box", name))
+		} else if client, ok := boxes[d.Box]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown box '%s'", name, d.Box))
+		} else {
+			d.client = client
+		}
+
+		if len(d.RTypes) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no record type configured", name))
+		}
+		for _, rtype := range d.RTypes {
+			rt := miab.ResourceType(rtype)
+			if rt != miab.A && rt != miab.AAAA {
+				errs = append(errs, fmt.Errorf("%s: unsupported record type '%s', use A or AAAA", name, rtype))
+				continue
+			}
+			if qname, err := miab.ToASCII(d.Name); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+				break
+			} else if err := miab.ValidateQName(qname, rt); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+				break
+			}
+			key := d.Box + "/" + strings.ToLower(d.Name) + "/" + rtype
+			if records[key] {
+				errs = append(errs, fmt.Errorf("%s: %s record configured more than once", name, rtype))
+			}
+			records[key] = true
+		}
+
+		if d.Source != "box" {
+			if s, ok := sources[d.Source]; ok {
+				d.source = s
+			} else if _, ok := c.Sources[d.Source]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown source '%s'", name, d.Source))
+			}
+		}
+
+		iid, err := d.interfaceID()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		} else if iid != nil {
+			d.iid = iid
+			if len(d.RTypes) != 1 || d.RTypes[0] != string(miab.AAAA) {
+				errs = append(errs, fmt.Errorf("%s: an interface identifier requires the record type AAAA only", name))
+			}
+			if d.Source == "box" {
+				errs = append(errs, fmt.Errorf("%s: an interface identifier requires a source of the prefix", name))
+			}
+			if d.PrefixLength < 1 || d.PrefixLength > 127 {
+				errs = append(errs, fmt.Errorf("%s: 'prefix_length' has to be between 1 and 127", name))
+			}
+		}
+	}
+
+	for i := range c.Records {
+		r := &c.Records[i]
+		if r.Name == "" {
+			errs = append(errs, fmt.Errorf("records[%d]: 'name' not specified", i))
+			continue
+		}
+		errs = append(errs, r.validate(boxes, sources, c.Sources)...)
+		key := r.Box + "/" + strings.ToLower(r.Name) + "/" + r.RType
+		if records[key] {
+			errs = append(errs, fmt.Errorf("record '%s' (%s): configured more than once", r.Name, r.RType))
+		}
+		records[key] = true
+	}
+
+	for i := range c.Failover {
+		f := &c.Failover[i]
+		if f.Name == "" {
+			errs = append(errs, fmt.Errorf("failover[%d]: 'name' not specified", i))
+			continue
+		}
+		errs = append(errs, f.validate(boxes)...)
+		key := f.Box + "/" + strings.ToLower(f.Name) + "/" + f.RType
+		if records[key] {
+			errs = append(errs, fmt.Errorf("failover '%s' (%s): configured more than once", f.Name, f.RType))
+		}
+		records[key] = true
+	}
+	return errs
+}
+
+// options returns the TLS options and the request timeout of the box.
+func (b *boxConfig) options() []miab.Option {
+	options := []miab.Option{miab.WithTimeout(boxTimeout)}
+	if b.CAFile != "" {
+		options = append(options, miab.WithCAFile(b.CAFile))
+	}
+	if b.ClientCert != "" || b.ClientKey != "" {
+		options = append(options, miab.WithClientCertificate(b.ClientCert, b.ClientKey))
+	}
+	if len(b.PinSHA256) > 0 {
+		options = append(options, miab.WithPinnedCertificates(b.PinSHA256...))
+	}
+	if b.Insecure {
+		options = append(options, miab.WithInsecureSkipVerify())
+	}
+	return options
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEnv = []string{"DNS_INTERVAL", "DNS_ENDPOINT", "DNS_USER", "DNS_PASSWORD", "DNS_CA_FILE", "DNS_CLIENT_CERT",
+	"DNS_CLIENT_KEY", "DNS_PIN_SHA256", "DNS_INSECURE", "DNS_CACHE", "DNS_LISTEN", "DNS_DOMAINS", "DNS_A", "DNS_AAAA",
+	"DNS_SOURCE"}
+
+const testConfigFile = `
+interval: 5m
+boxes:
+  - name: home
+    endpoint: https://box.example.org
+    user: admin@example.org
+    password: secret
+  - name: work
+    endpoint: https://box.example.com
+    user: admin@example.com
+    password: secret
+domains:
+  - name: a.example.org
+    box: home
+    rtypes: [aaaa]
+  - name: b.example.com
+    box: work
+    interval: 10
+    source: http
+  - name: c.example.org
+    box: home
+    source: stun
+    mac: 00:11:22:33:44:55
+`
+
+func TestLoadConfig(t *testing.T) {
+
+	envBox := map[string]string{
+		"DNS_ENDPOINT": "https://box.example.org",
+		"DNS_USER":     "admin@example.org",
+		"DNS_PASSWORD": "secret",
+		"DNS_DOMAINS":  "a.example.org, b.example.org",
+	}
+	with := func(env map[string]string, k, v string) map[string]string {
+		res := map[string]string{k: v}
+		for k, v := range env {
+			if _, ok := res[k]; !ok {
+				res[k] = v
+			}
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+		check   func(t *testing.T, c *config)
+	}{
+		{"environment", "", envBox, "", func(t *testing.T, c *config) {
+			if time.Duration(c.Interval) != defaultInterval {
+				t.Errorf("interval, want: %v, got: %v", defaultInterval, c.Interval)
+			}
+			if len(c.Boxes) != 1 || c.Boxes[0].Name != "default" || c.Boxes[0].client == nil {
+				t.Errorf("unexpected boxes: %+v", c.Boxes)
+			}
+			if len(c.Domains) != 2 {
+				t.Fatalf("want 2 domains, got: %d", len(c.Domains))
+			}
+			for _, d := range c.Domains {
+				if d.Box != "default" || d.Source != "box" || d.Interval != c.Interval || d.client == nil {
+					t.Errorf("unexpected domain: %+v", d)
+				}
+				if !reflect.DeepEqual(d.RTypes, []string{"A"}) {
+					t.Errorf("rtypes, want: [A], got: %v", d.RTypes)
+				}
+			}
+		}},
+		{"interval in seconds", "", with(envBox, "DNS_INTERVAL", "120"), "", func(t *testing.T, c *config) {
+			if time.Duration(c.Interval) != 2*time.Minute {
+				t.Errorf("interval, want: 2m, got: %v", c.Interval)
+			}
+		}},
+		{"interval below minimum", "", with(envBox, "DNS_INTERVAL", "10s"), "", func(t *testing.T, c *config) {
+			if time.Duration(c.Interval) != minInterval {
+				t.Errorf("interval, want: %v, got: %v", minInterval, c.Interval)
+			}
+		}},
+		{"record types", "", with(with(envBox, "DNS_A", "false"), "DNS_AAAA", "true"), "", func(t *testing.T, c *config) {
+			for _, d := range c.Domains {
+				if !reflect.DeepEqual(d.RTypes, []string{"AAAA"}) {
+					t.Errorf("rtypes, want: [AAAA], got: %v", d.RTypes)
+				}
+			}
+		}},
+		{"file", testConfigFile, nil, "", func(t *testing.T, c *config) {
+			a, b, x := c.Domains[0], c.Domains[1], c.Domains[2]
+			if !reflect.DeepEqual(a.RTypes, []string{"AAAA"}) || a.Interval != duration(5*time.Minute) {
+				t.Errorf("unexpected domain: %+v", a)
+			}
+			if b.Interval != duration(minInterval) || b.source == nil {
+				t.Errorf("unexpected domain: %+v", b)
+			}
+			if !reflect.DeepEqual(x.RTypes, []string{"AAAA"}) || x.PrefixLength != defaultPrefixLength ||
+				x.iid.String() != "::211:22ff:fe33:4455" {
+				t.Errorf("unexpected domain: %+v", x)
+			}
+		}},
+		{"file and environment", testConfigFile, map[string]string{"DNS_USER": "other@example.org", "DNS_INTERVAL": "1h"}, "",
+			func(t *testing.T, c *config) {
+				if c.Boxes[0].User != "other@example.org" || c.Boxes[1].User != "admin@example.com" {
+					t.Errorf("unexpected boxes: %+v", c.Boxes)
+				}
+				if c.Interval != duration(time.Hour) || c.Domains[0].Interval != c.Interval {
+					t.Errorf("unexpected intervals: %v, %v", c.Interval, c.Domains[0].Interval)
+				}
+			}},
+		{"invalid interval", "", with(envBox, "DNS_INTERVAL", "soon"), "DNS_INTERVAL", nil},
+		{"invalid boolean", "", with(envBox, "DNS_A", "maybe"), "DNS_A", nil},
+		{"no box", "", map[string]string{"DNS_DOMAINS": "a.example.org"}, "no box configured", nil},
+		{"no domain", "", with(envBox, "DNS_DOMAINS", ";"), "no domain configured", nil},
+		{"invalid endpoint", "", with(envBox, "DNS_ENDPOINT", "box.example.org"), "'url' is not valid", nil},
+		{"unknown source", "", with(envBox, "DNS_SOURCE", "carrier-pigeon"), "unknown source 'carrier-pigeon'", nil},
+		{"unknown key", "domain: a.example.org\n", envBox, "unknown field", nil},
+		{"unknown box", strings.Replace(testConfigFile, "box: work", "box: office", 1), nil, "unknown box 'office'", nil},
+		{"ambiguous box", strings.Replace(testConfigFile, "    box: work\n", "", 1), nil, "'box' not specified", nil},
+		{"unsupported record type", strings.Replace(testConfigFile, "[aaaa]", "[mx]", 1), nil, "unsupported record type 'MX'", nil},
+		{"duplicate record", "", with(envBox, "DNS_DOMAINS", "a.example.org;A.example.org"), "configured more than once", nil},
+		{"interface id without source", strings.Replace(testConfigFile, "source: stun", "source: box", 1), nil,
+			"requires a source of the prefix", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range testEnv {
+				t.Setenv(k, "")
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			file := ""
+			if tc.file != "" {
+				file = filepath.Join(t.TempDir(), "config.yaml")
+				if err := ioutil.WriteFile(file, []byte(tc.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := loadConfig(file)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("want error containing '%s', got: %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	testCases := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90", 90 * time.Second, false},
+		{" 30m ", 30 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"-1", 0, true},
+		{"", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseDuration(tc.in)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dyndnsConfig describes the DynDNS2 compatible server, e.g. for routers that update their address themselves.
+type dyndnsConfig struct {
+	Listen  string       `json:"listen"`
+	TLSCert string       `json:"tls_cert"`
+	TLSKey  string       `json:"tls_key"`
+	Hosts   []dyndnsHost `json:"hosts"`
+}
+
+// dyndnsHost is a host name that may be updated with its own credentials.
+type dyndnsHost struct {
+	Hostname string `json:"hostname"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Box      string `json:"box"`
+
+	domain domainConfig
+}
+
+// dyndnsServer answers DynDNS2 update requests (/nic/update?hostname=...&myip=...), the addresses are set via
+// SetDns. If myip is omitted, the address of the client is used.
+type dyndnsServer struct {
+	hosts   map[string]*dyndnsHost
+	updater *updater
+}
+
+// validate checks the config of the server, the hosts get the clients of their boxes.
+func (c *dyndnsConfig) validate(boxes map[string]*miab.Config) []error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("dyndns: 'listen' has to be [host]:port: %v", err))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, fmt.Errorf("dyndns: 'tls_cert' and 'tls_key' have to be specified both"))
+	}
+	if len(c.Hosts) == 0 {
+		errs = append(errs, fmt.Errorf("dyndns: no host configured"))
+	}
+
+	hosts := map[string]bool{}
+	for i := range c.Hosts {
+		h := &c.Hosts[i]
+		name := fmt.Sprintf("dyndns host[%d]", i)
+		if h.Hostname == "" {
+			errs = append(errs, fmt.Errorf("%s: 'hostname' not specified", name))
+			continue
+		}
+		name = fmt.Sprintf("dyndns host '%s'", h.Hostname)
+		if hosts[strings.ToLower(h.Hostname)] {
+			errs = append(errs, fmt.Errorf("%s: configured more than once", name))
+		}
+		hosts[strings.ToLower(h.Hostname)] = true
+
+		if qname, err := miab.ToASCII(h.Hostname); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		} else if err := miab.ValidateQName(qname, miab.A); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+		if h.User == "" || h.Password == "" {
+			errs = append(errs, fmt.Errorf("%s: 'user' and 'password' have to be specified", name))
+		}
+
+		h.domain = domainConfig{Name: h.Hostname, Box: h.Box}
+		if h.Box == "" {
+			errs = append(errs, fmt.Errorf("%s: 'box' not specified, there is more than one box", name))
+		} else if client, ok := boxes[h.Box]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown box '%s'", name, h.Box))
+		} else {
+			h.domain.client = client
+		}
+	}
+	return errs
+}
+
+func newDyndnsServer(c *dyndnsConfig, u *updater) *dyndnsServer {
+	s := &dyndnsServer{hosts: map[string]*dyndnsHost{}, updater: u}
+	for i := range c.Hosts {
+		s.hosts[strings.ToLower(c.Hosts[i].Hostname)] = &c.Hosts[i]
+	}
+	return s
+}
+
+func (s *dyndnsServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/nic/update", s)
+	mux.Handle("/v3/update", s)
+	return mux
+}
+
+func (s *dyndnsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="dnsupdate"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("badauth\n"))
+		return
+	}
+
+	q := r.URL.Query()
+	var ips []net.IP
+	for _, p := range append(strings.Split(q.Get("myip"), ","), strings.Split(q.Get("myipv6"), ",")...) {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		ip := net.ParseIP(p)
+		if ip == nil {
+			_, _ = w.Write([]byte("911\n"))
+			return
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err == nil && ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	var answers []string
+	for _, hostname := range strings.Split(q.Get("hostname"), ",") {
+		answers = append(answers, s.update(r.Context(), strings.TrimSpace(hostname), user, password, ips))
+	}
+	_, _ = w.Write([]byte(strings.Join(answers, "\n") + "\n"))
+}
+
+// update sets the addresses of the host and returns the DynDNS2 answer (good, nochg, badauth, nohost, notfqdn, 911).
+func (s *dyndnsServer) update(ctx context.Context, hostname, user, password string, ips []net.IP) string {
+
+	if hostname == "" || !strings.Contains(hostname, ".") {
+		return "notfqdn"
+	}
+	h, ok := s.hosts[strings.ToLower(hostname)]
+	if !ok {
+		return "nohost"
+	}
+	if subtle.ConstantTimeCompare([]byte(user), []byte(h.User)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(h.Password)) != 1 {
+		return "badauth"
+	}
+	if len(ips) == 0 {
+		return "911"
+	}
+
+	answer := "nochg"
+	var addresses []string
+	for _, ip := range ips {
+		rtype := string(miab.A)
+		if ip.To4() == nil {
+			rtype = string(miab.AAAA)
+		}
+		value := ip.String()
+		addresses = append(addresses, value)
+
+		// without a cached address the record on the box is compared, e.g. after a restart without a cache file
+		key := recordKey(h.domain, rtype)
+		previous, ok := s.updater.cache.get(key)
+		if !ok {
+			if address, err := boxAddress(ctx, h.domain, rtype); err == nil && address == value {
+				if err := s.updater.cache.set(key, value); err != nil {
+					fmt.Printf("Unable to write the cache: %v\n", err)
+				}
+				previous = address
+			}
+		}
+		if previous == value {
+			s.updater.metrics.push(h.domain.Name, rtype, value, nil)
+			continue
+		}
+
+		e := event{Domain: h.domain.Name, RType: rtype, Address: value, Previous: previous, Time: time.Now()}
+		b, err := miab.SetDnsContext(ctx, h.domain.client, h.domain.Name, miab.ResourceType(rtype), value)
+		if err == nil && !b {
+			err = errors.New("update failed")
+		}
+		if err != nil {
+			fmt.Printf("DynDNS update (%s) for '%s' failed with error: %v\n", rtype, hostname, err)
+			s.updater.metrics.push(h.domain.Name, rtype, "", err)
+			e.Address, e.Error = "", err.Error()
+			s.updater.checkEvents(e)
+			return "911"
+		}
+		fmt.Printf("DynDNS update (%s) for '%s' to %s successful\n", rtype, hostname, value)
+		if err := s.updater.cache.set(key, value); err != nil {
+			fmt.Printf("Unable to write the cache: %v\n", err)
+		}
+		s.updater.metrics.push(h.domain.Name, rtype, value, nil)
+		s.updater.checkEvents(e)
+		answer = "good"
+	}
+	return answer + " " + strings.Join(addresses, ",")
+}
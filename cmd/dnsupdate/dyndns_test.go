@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/rverst/go-miab/miab"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDyndnsServer_Update(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		query    string
+		user     string
+		password string
+		records  miab.Records
+		cached   string // the cached address of the A record
+		fail     bool
+		want     string
+		changes  []string
+	}{
+		{"good", "hostname=home.example.org&myip=192.0.2.1", "home", "secret", nil, "", false,
+			"good 192.0.2.1\n", []string{"PUT home.example.org A 192.0.2.1"}},
+		{"changed", "hostname=home.example.org&myip=192.0.2.1", "home", "secret",
+			miab.Records{{QName: "home.example.org", RType: miab.A, Value: "192.0.2.9"}}, "192.0.2.9", false,
+			"good 192.0.2.1\n", []string{"PUT home.example.org A 192.0.2.1"}},
+		{"nochg cached", "hostname=home.example.org&myip=192.0.2.1", "home", "secret", nil, "192.0.2.1", false,
+			"nochg 192.0.2.1\n", nil},
+		{"nochg on the box", "hostname=home.example.org&myip=192.0.2.1", "home", "secret",
+			miab.Records{{QName: "home.example.org", RType: miab.A, Value: "192.0.2.1"}}, "", false,
+			"nochg 192.0.2.1\n", nil},
+		{"ipv4 and ipv6", "hostname=HOME.example.org&myip=192.0.2.1&myipv6=2001:db8::1", "home", "secret", nil, "192.0.2.1", false,
+			"good 192.0.2.1,2001:db8::1\n", []string{"PUT home.example.org AAAA 2001:db8::1"}},
+		{"address of the client", "hostname=home.example.org", "home", "secret", nil, "", false,
+			"good 192.0.2.1\n", []string{"PUT home.example.org A 192.0.2.1"}},
+		{"multiple hosts", "hostname=home.example.org,nas.example.org&myip=192.0.2.1", "home", "secret", nil, "", false,
+			"good 192.0.2.1\nnohost\n", []string{"PUT home.example.org A 192.0.2.1"}},
+		{"no credentials", "hostname=home.example.org&myip=192.0.2.1", "", "", nil, "", false, "badauth\n", nil},
+		{"wrong password", "hostname=home.example.org&myip=192.0.2.1", "home", "guess", nil, "", false, "badauth\n", nil},
+		{"wrong user", "hostname=home.example.org&myip=192.0.2.1", "office", "secret", nil, "", false, "badauth\n", nil},
+		{"unknown host", "hostname=office.example.org&myip=192.0.2.1", "home", "secret", nil, "", false, "nohost\n", nil},
+		{"not fqdn", "hostname=home&myip=192.0.2.1", "home", "secret", nil, "", false, "notfqdn\n", nil},
+		{"no hostname", "myip=192.0.2.1", "home", "secret", nil, "", false, "notfqdn\n", nil},
+		{"invalid address", "hostname=home.example.org&myip=home", "home", "secret", nil, "", false, "911\n", nil},
+		{"failed update", "hostname=home.example.org&myip=192.0.2.1", "home", "secret", nil, "", true, "911\n", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := newTestBox(t, tc.records...)
+			c := &dyndnsConfig{
+				Listen: "127.0.0.1:0",
+				Hosts:  []dyndnsHost{{Hostname: "home.example.org", User: "home", Password: "secret", Box: "box"}},
+			}
+			if errs := c.validate(map[string]*miab.Config{"box": box.client}); len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			u, _ := newTestUpdater(t, 1)
+			if tc.cached != "" {
+				_ = u.cache.set(recordKey(c.Hosts[0].domain, "A"), tc.cached)
+			}
+			box.fail = tc.fail
+
+			req := httptest.NewRequest(http.MethodGet, "/nic/update?"+tc.query, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			rec := httptest.NewRecorder()
+			newDyndnsServer(c, u).handler().ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if tc.user == "" && rec.Code != http.StatusUnauthorized {
+				t.Errorf("status, want: %d, got: %d", http.StatusUnauthorized, rec.Code)
+			}
+			if got := box.changes(); !reflect.DeepEqual(got, tc.changes) {
+				t.Errorf("changes, want: %v, got: %v", tc.changes, got)
+			}
+		})
+	}
+}
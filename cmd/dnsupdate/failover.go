@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRise          = 2
+	defaultFall          = 3
+	defaultCheckInterval = time.Minute
+	defaultCheckTimeout  = 5 * time.Second
+)
+
+// failoverConfig describes a round-robin address record, that holds only the healthy addresses of the targets.
+type failoverConfig struct {
+	Name     string      `json:"name"`
+	Box      string      `json:"box"`
+	RType    string      `json:"rtype"`
+	Interval duration    `json:"interval"`
+	Targets  []string    `json:"targets"`
+	Check    checkConfig `json:"check"`
+	Rise     int         `json:"rise"` // consecutive successful checks until a target is added
+	Fall     int         `json:"fall"` // consecutive failed checks until a target is removed
+
+	client  *miab.Config
+	targets []string
+}
+
+// checkConfig describes the health check of the targets, a TCP connect or an HTTP(S) request.
+type checkConfig struct {
+	Type     string   `json:"type"`
+	Port     int      `json:"port"`
+	Path     string   `json:"path"`
+	Host     string   `json:"host"`   // the host name of HTTP requests and TLS, the name of the record by default
+	Status   []int    `json:"status"` // the expected status codes, by default any 2xx or 3xx
+	Timeout  duration `json:"timeout"`
+	Insecure bool     `json:"insecure"`
+}
+
+// validate checks the failover record, it gets the client of its box and the targets in canonical form.
+func (f *failoverConfig) validate(boxes map[string]*miab.Config) []error {
+	var errs []error
+	name := fmt.Sprintf("failover '%s' (%s)", f.Name, f.RType)
+
+	rtype := miab.ResourceType(f.RType)
+	if rtype != miab.A && rtype != miab.AAAA {
+		errs = append(errs, fmt.Errorf("%s: unsupported record type '%s', use A or AAAA", name, f.RType))
+	} else if qname, err := miab.ToASCII(f.Name); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", name, err))
+	} else if err := miab.ValidateQName(qname, rtype); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", name, err))
+	}
+
+	if f.Box == "" {
+		errs = append(errs, fmt.Errorf("%s: 'box' not specified, there is more than one box", name))
+	} else if client, ok := boxes[f.Box]; !ok {
+		errs = append(errs, fmt.Errorf("%s: unknown box '%s'", name, f.Box))
+	} else {
+		f.client = client
+	}
+
+	if len(f.Targets) == 0 {
+		errs = append(errs, fmt.Errorf("%s: no target configured", name))
+	}
+	seen := map[string]bool{}
+	for _, t := range f.Targets {
+		ip, err := checkAddress(net.ParseIP(strings.TrimSpace(t)), networkOf(rtype))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: target '%s': %v", name, t, err))
+			continue
+		}
+		if seen[ip.String()] {
+			errs = append(errs, fmt.Errorf("%s: target '%s' configured more than once", name, t))
+		}
+		seen[ip.String()] = true
+		f.targets = append(f.targets, ip.String())
+	}
+
+	switch f.Check.Type {
+	case "tcp":
+		if f.Check.Port == 0 {
+			errs = append(errs, fmt.Errorf("%s: 'port' of the check not specified", name))
+		}
+	case "http", "https":
+	case "":
+		errs = append(errs, fmt.Errorf("%s: 'type' of the check not specified", name))
+	default:
+		errs = append(errs, fmt.Errorf("%s: unknown check '%s', use tcp, http or https", name, f.Check.Type))
+	}
+	if f.Check.Port < 0 || f.Check.Port > 65535 {
+		errs = append(errs, fmt.Errorf("%s: 'port' of the check has to be between 1 and 65535", name))
+	}
+	if f.Check.Timeout >= f.Interval {
+		errs = append(errs, fmt.Errorf("%s: 'timeout' of the check has to be less than the interval", name))
+	}
+	if f.Rise < 1 || f.Fall < 1 {
+		errs = append(errs, fmt.Errorf("%s: 'rise' and 'fall' have to be at least 1", name))
+	}
+	return errs
+}
+
+// failover keeps the health of the targets of a failover record. A target changes its state only after Rise
+// successful or Fall failed checks in a row, so a flapping target doesn't churn the record.
+type failover struct {
+	failoverConfig
+
+	initialized bool
+	healthy     map[string]bool
+	count       map[string]int // consecutive checks contrary to the state
+	http        *http.Client
+}
+
+func newFailover(f failoverConfig) *failover {
+
+	c := f.Check
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{ServerName: c.Host, InsecureSkipVerify: c.Insecure},
+	}
+	return &failover{
+		failoverConfig: f,
+		healthy:        map[string]bool{},
+		count:          map[string]int{},
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(c.Timeout),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// check checks the health of a target.
+func (f *failover) check(ctx context.Context, target string) error {
+
+	c := f.Check
+	if c.Type == "tcp" {
+		dialer := &net.Dialer{Timeout: time.Duration(c.Timeout)}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target, strconv.Itoa(c.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	url := c.Type + "://" + target
+	if c.Port != 0 {
+		url = c.Type + "://" + net.JoinHostPort(target, strconv.Itoa(c.Port))
+	} else if strings.Contains(target, ":") {
+		url = c.Type + "://[" + target + "]"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+c.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = c.Host
+	res, err := f.http.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	for _, s := range c.Status {
+		if res.StatusCode == s {
+			return nil
+		}
+	}
+	if len(c.Status) == 0 && res.StatusCode >= 200 && res.StatusCode < 400 {
+		return nil
+	}
+	return fmt.Errorf("unexpected status %s", res.Status)
+}
+
+// checkTargets checks all targets and updates their states, returns the healthy targets.
+func (f *failover) checkTargets(ctx context.Context) []string {
+
+	results := make([]error, len(f.targets))
+	var wg sync.WaitGroup
+	for i, t := range f.targets {
+		wg.Add(1)
+		go func(i int, t string) {
+			defer wg.Done()
+			results[i] = f.check(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+	// checks aborted by the shutdown don't change the states
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	var healthy []string
+	for i, t := range f.targets {
+		if (results[i] == nil) == f.healthy[t] {
+			f.count[t] = 0
+		} else {
+			f.count[t]++
+			threshold := f.Fall
+			if !f.healthy[t] {
+				threshold = f.Rise
+			}
+			if f.count[t] >= threshold {
+				f.healthy[t], f.count[t] = !f.healthy[t], 0
+				if f.healthy[t] {
+					fmt.Printf("Target %s of '%s' is healthy\n", t, f.Name)
+				} else {
+					fmt.Printf("Target %s of '%s' is unhealthy: %v\n", t, f.Name, results[i])
+				}
+			}
+		}
+		if f.healthy[t] {
+			healthy = append(healthy, t)
+		}
+	}
+	return healthy
+}
+
+// doFailover checks the targets and adds the healthy and deletes all other addresses of the record via AddDns and
+// DeleteDns. If no target is healthy, the record is left unchanged. Returns false if the update failed.
+func (u *updater) doFailover(ctx context.Context, f *failover) bool {
+
+	previous, value, err := f.update(ctx)
+	if ctx.Err() != nil {
+		return false
+	}
+	u.metrics.result(f.Name, f.RType, value, err)
+
+	e := event{Domain: f.Name, RType: f.RType, Address: value, Previous: previous, Time: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	u.checkEvents(e)
+	return err == nil
+}
+
+// update checks the targets and updates the record, returns the previous and the current addresses (comma
+// separated).
+func (f *failover) update(ctx context.Context) (string, string, error) {
+
+	rtype := miab.ResourceType(f.RType)
+	records, err := miab.GetDnsContext(ctx, f.client, f.Name, rtype)
+	if err != nil {
+		fmt.Printf("Unable to get the records (%s) of '%s': %v\n", f.RType, f.Name, err)
+		return "", "", err
+	}
+	var current []string
+	for _, r := range records {
+		if ip := net.ParseIP(r.Value); ip != nil {
+			current = append(current, ip.String())
+		} else {
+			current = append(current, r.Value)
+		}
+	}
+	sort.Strings(current)
+	previous := strings.Join(current, ",")
+
+	// the targets set on the box are assumed healthy at first, so a restart doesn't change the record
+	if !f.initialized {
+		for _, t := range f.targets {
+			f.healthy[t] = contains(current, t)
+		}
+		f.initialized = true
+	}
+
+	healthy := f.checkTargets(ctx)
+	if ctx.Err() != nil {
+		return previous, "", ctx.Err()
+	}
+	if len(healthy) == 0 {
+		fmt.Printf("No healthy target of '%s' (%s), the record is left unchanged\n", f.Name, f.RType)
+		return previous, previous, nil
+	}
+	sort.Strings(healthy)
+
+	// addresses are added before others are deleted, so the record is never empty
+	for _, t := range healthy {
+		if contains(current, t) {
+			continue
+		}
+		if err := f.apply(ctx, miab.AddDnsContext, t); err != nil {
+			return previous, "", err
+		}
+		fmt.Printf("DNS update (%s) for '%s': added %s\n", f.RType, f.Name, t)
+	}
+	for _, c := range current {
+		if contains(healthy, c) {
+			continue
+		}
+		if err := f.apply(ctx, miab.DeleteDnsContext, c); err != nil {
+			return previous, "", err
+		}
+		fmt.Printf("DNS update (%s) for '%s': deleted %s\n", f.RType, f.Name, c)
+	}
+	return previous, strings.Join(healthy, ","), nil
+}
+
+func (f *failover) apply(ctx context.Context, op func(context.Context, *miab.Config, string, miab.ResourceType, string) (bool, error),
+	value string) error {
+	b, err := op(ctx, f.client, f.Name, miab.ResourceType(f.RType), value)
+	if err != nil {
+		fmt.Printf("DNS update (%s) for '%s' failed with error: %v\n", f.RType, f.Name, err)
+		return err
+	}
+	if !b {
+		fmt.Printf("DNS update (%s) for '%s' failed\n", f.RType, f.Name)
+		return errors.New("update failed")
+	}
+	return nil
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
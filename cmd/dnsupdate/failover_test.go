@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startTarget starts an HTTP server on 127.0.0.1, it answers with the status of the path (e.g. /503) to requests
+// for www.example.org. Returns the port of the server.
+func startTarget(t *testing.T) int {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.org" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+			return
+		}
+		status, err := strconv.Atoi(r.URL.Path[1:])
+		if err != nil {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestFailover_Check(t *testing.T) {
+
+	port := startTarget(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	_ = closed.Close()
+
+	testCases := []struct {
+		name    string
+		check   checkConfig
+		wantErr bool
+	}{
+		{"http", checkConfig{Type: "http", Port: port, Path: "/"}, false},
+		{"redirect", checkConfig{Type: "http", Port: port, Path: "/302"}, false},
+		{"server error", checkConfig{Type: "http", Port: port, Path: "/503"}, true},
+		{"expected status", checkConfig{Type: "http", Port: port, Path: "/503", Status: []int{503}}, false},
+		{"unexpected status", checkConfig{Type: "http", Port: port, Path: "/200", Status: []int{204}}, true},
+		{"host", checkConfig{Type: "http", Port: port, Path: "/", Host: "example.org"}, true},
+		{"http refused", checkConfig{Type: "http", Port: closedPort, Path: "/"}, true},
+		{"tcp", checkConfig{Type: "tcp", Port: port}, false},
+		{"tcp refused", checkConfig{Type: "tcp", Port: closedPort}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.check
+			c.Timeout = duration(time.Second)
+			if c.Host == "" {
+				c.Host = "www.example.org"
+			}
+			f := newFailover(failoverConfig{Name: "www.example.org", Check: c})
+			err := f.check(context.Background(), "127.0.0.1")
+			if tc.wantErr != (err != nil) {
+				t.Errorf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFailover_CheckTargets(t *testing.T) {
+
+	port := startTarget(t)
+
+	testCases := []struct {
+		name       string
+		rise, fall int
+		checks     []bool // the result of the check per step
+		want       []bool // healthy after the step
+	}{
+		{"rise", 2, 3,
+			[]bool{true, false, true, true, true},
+			[]bool{false, false, false, true, true}},
+		{"fall", 1, 3,
+			[]bool{true, false, false, true, false, false, false, true},
+			[]bool{true, true, true, true, true, true, false, true}},
+		{"single check", 1, 1,
+			[]bool{false, true, false, true},
+			[]bool{false, true, false, true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFailover(failoverConfig{
+				Name: "www.example.org",
+				Rise: tc.rise,
+				Fall: tc.fall,
+				Check: checkConfig{Type: "http", Port: port, Host: "www.example.org", Timeout: duration(time.Second),
+					Status: []int{http.StatusOK}},
+			})
+			f.targets = []string{"127.0.0.1"}
+
+			for i, ok := range tc.checks {
+				f.Check.Path = "/503"
+				if ok {
+					f.Check.Path = "/200"
+				}
+				healthy := f.checkTargets(context.Background())
+
+				want := []string(nil)
+				if tc.want[i] {
+					want = []string{"127.0.0.1"}
+				}
+				if !reflect.DeepEqual(healthy, want) {
+					t.Errorf("step %d, want: %v, got: %v", i, want, healthy)
+				}
+			}
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		f := newFailover(failoverConfig{
+			Name:  "www.example.org",
+			Rise:  1,
+			Fall:  1,
+			Check: checkConfig{Type: "tcp", Port: port, Timeout: duration(time.Second)},
+		})
+		f.targets = []string{"127.0.0.1"}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if healthy := f.checkTargets(ctx); healthy != nil || f.healthy["127.0.0.1"] || f.count["127.0.0.1"] != 0 {
+			t.Errorf("state changed by a canceled check: %v, %v, %v", healthy, f.healthy, f.count)
+		}
+	})
+}
+
+// listenTargets listens on the same port of each address, so a TCP check of the addresses succeeds and fails for
+// any other address. Returns the port.
+func listenTargets(t *testing.T, addresses ...string) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	for _, a := range addresses {
+		l, err := net.Listen("tcp", net.JoinHostPort(a, strconv.Itoa(port)))
+		if err != nil {
+			t.Skipf("unable to listen on %s: %v", a, err)
+		}
+		t.Cleanup(func() { _ = l.Close() })
+	}
+	return port
+}
+
+func TestUpdater_DoFailover(t *testing.T) {
+
+	boxRecord := func(value string) miab.Record {
+		return miab.Record{QName: "www.example.org", RType: miab.A, Value: value}
+	}
+
+	testCases := []struct {
+		name       string
+		records    []miab.Record
+		healthy    []string
+		rise, fall int
+		fail       bool
+		ok         bool
+		changes    []string
+		value      string
+		events     []string
+	}{
+		{"add before delete", []miab.Record{boxRecord("127.0.0.2")}, []string{"127.0.0.1", "127.0.0.3"}, 1, 1, false, true,
+			[]string{"POST www.example.org A 127.0.0.1", "POST www.example.org A 127.0.0.3", "DELETE www.example.org A 127.0.0.2"},
+			"127.0.0.1,127.0.0.3", []string{"change 0"}},
+		{"unchanged", []miab.Record{boxRecord("127.0.0.1")}, []string{"127.0.0.1"}, 1, 1, false, true,
+			nil, "127.0.0.1", nil},
+		{"other address deleted", []miab.Record{boxRecord("127.0.0.1"), boxRecord("192.0.2.9")}, []string{"127.0.0.1"}, 1, 1,
+			false, true, []string{"DELETE www.example.org A 192.0.2.9"}, "127.0.0.1", []string{"change 0"}},
+		{"no healthy target", []miab.Record{boxRecord("127.0.0.1"), boxRecord("127.0.0.2")}, nil, 1, 1, false, true,
+			nil, "127.0.0.1,127.0.0.2", nil},
+		{"initial state from the box", []miab.Record{boxRecord("127.0.0.1"), boxRecord("127.0.0.2")}, []string{"127.0.0.1", "127.0.0.3"},
+			2, 3, false, true, nil, "127.0.0.1,127.0.0.2", nil},
+		{"empty record", nil, []string{"127.0.0.1"}, 2, 3, false, true, nil, "", nil},
+		{"box failed", []miab.Record{boxRecord("127.0.0.1")}, []string{"127.0.0.1"}, 1, 1, true, false, nil, "", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := newTestBox(t, tc.records...)
+			box.fail = tc.fail
+			u, n := newTestUpdater(t, 3)
+			u.metrics.register("www.example.org", "A", time.Minute)
+
+			f := failoverConfig{Name: "www.example.org", Box: "box", RType: "A", Interval: duration(time.Minute),
+				Targets: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, Rise: tc.rise, Fall: tc.fall,
+				Check: checkConfig{Type: "tcp", Port: listenTargets(t, tc.healthy...), Timeout: duration(time.Second)}}
+			if errs := f.validate(map[string]*miab.Config{"box": box.client}); len(errs) > 0 {
+				t.Fatal(errs)
+			}
+
+			if ok := u.doFailover(context.Background(), newFailover(f)); ok != tc.ok {
+				t.Errorf("want: %v, got: %v", tc.ok, ok)
+			}
+			if got := box.changes(); !reflect.DeepEqual(got, tc.changes) {
+				t.Errorf("changes, want: %v, got: %v", tc.changes, got)
+			}
+			if got := u.metrics.records[record{"www.example.org", "A"}].address; got != tc.value {
+				t.Errorf("value, want: %q, got: %q", tc.value, got)
+			}
+			if got := n.sent(); !reflect.DeepEqual(got, tc.events) {
+				t.Errorf("events, want: %v, got: %v", tc.events, got)
+			}
+		})
+	}
+}
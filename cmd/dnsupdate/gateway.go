@@ -0,0 +1,512 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"strings"
+	"time"
+)
+
+// gatewayConfig describes the RFC 2136 (DNS UPDATE) gateway, e.g. for nsupdate, DHCP servers or cert-manager.
+type gatewayConfig struct {
+	Listen string       `json:"listen"`
+	Keys   []gatewayKey `json:"keys"`
+}
+
+// gatewayKey is a TSIG key, that may update the names of its zones (including subdomains) on a box.
+type gatewayKey struct {
+	Name      string   `json:"name"`
+	Algorithm string   `json:"algorithm"` // hmac-sha256 by default
+	Secret    string   `json:"secret"`    // base64 encoded
+	Zones     []string `json:"zones"`
+	Box       string   `json:"box"`
+
+	client *miab.Config
+}
+
+var tsigAlgorithms = map[string]bool{
+	dns.HmacSHA1: true, dns.HmacSHA224: true, dns.HmacSHA256: true, dns.HmacSHA384: true, dns.HmacSHA512: true,
+}
+
+// validate checks the config of the gateway, the keys get the clients of their boxes.
+func (c *gatewayConfig) validate(boxes map[string]*miab.Config) []error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("rfc2136: 'listen' has to be [host]:port: %v", err))
+	}
+	if len(c.Keys) == 0 {
+		errs = append(errs, fmt.Errorf("rfc2136: no key configured"))
+	}
+
+	keys := map[string]bool{}
+	for i := range c.Keys {
+		k := &c.Keys[i]
+		name := fmt.Sprintf("rfc2136 key[%d]", i)
+		if k.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: 'name' not specified", name))
+			continue
+		}
+		name = fmt.Sprintf("rfc2136 key '%s'", k.Name)
+		k.Name = dns.CanonicalName(k.Name)
+		if keys[k.Name] {
+			errs = append(errs, fmt.Errorf("%s: configured more than once", name))
+		}
+		keys[k.Name] = true
+
+		if k.Algorithm == "" {
+			k.Algorithm = dns.HmacSHA256
+		}
+		k.Algorithm = dns.CanonicalName(k.Algorithm)
+		if !tsigAlgorithms[k.Algorithm] {
+			errs = append(errs, fmt.Errorf("%s: unsupported algorithm '%s'", name, k.Algorithm))
+		}
+		if _, err := base64.StdEncoding.DecodeString(k.Secret); err != nil || k.Secret == "" {
+			errs = append(errs, fmt.Errorf("%s: 'secret' has to be base64 encoded", name))
+		}
+		if len(k.Zones) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no zone configured", name))
+		}
+		for j, z := range k.Zones {
+			if _, ok := dns.IsDomainName(z); !ok {
+				errs = append(errs, fmt.Errorf("%s: invalid zone '%s'", name, z))
+			}
+			k.Zones[j] = dns.CanonicalName(z)
+		}
+
+		if k.Box == "" {
+			errs = append(errs, fmt.Errorf("%s: 'box' not specified, there is more than one box", name))
+		} else if client, ok := boxes[k.Box]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown box '%s'", name, k.Box))
+		} else {
+			k.client = client
+		}
+	}
+	return errs
+}
+
+// gateway translates DNS UPDATE messages into changes of the custom DNS records of a box. The changes are applied
+// in order, but not atomically: if a change fails, the changes before remain.
+type gateway struct {
+	keys map[string]*gatewayKey
+}
+
+func newGateway(c *gatewayConfig) *gateway {
+	g := &gateway{keys: map[string]*gatewayKey{}}
+	for i := range c.Keys {
+		g.keys[c.Keys[i].Name] = &c.Keys[i]
+	}
+	return g
+}
+
+// servers returns the UDP and TCP servers of the gateway.
+func (g *gateway) servers(addr string) []*dns.Server {
+	secrets := map[string]string{}
+	for name, k := range g.keys {
+		secrets[name] = k.Secret
+	}
+	return []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: g, TsigSecret: secrets, MsgAcceptFunc: acceptUpdate},
+		{Addr: addr, Net: "tcp", Handler: g, TsigSecret: secrets, MsgAcceptFunc: acceptUpdate},
+	}
+}
+
+// acceptUpdate accepts update messages, that the default of the server rejects.
+func acceptUpdate(h dns.Header) dns.MsgAcceptAction {
+	const qr = 1 << 15
+	if h.Bits&qr != 0 {
+		return dns.MsgIgnore
+	}
+	if opcode := int(h.Bits>>11) & 0xF; opcode != dns.OpcodeUpdate {
+		return dns.MsgRejectNotImplemented
+	}
+	if h.Qdcount != 1 {
+		return dns.MsgReject
+	}
+	return dns.MsgAccept
+}
+
+func (g *gateway) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+
+	m := new(dns.Msg)
+	rcode := g.update(w, r)
+	m.SetRcode(r, rcode)
+	if t := r.IsTsig(); t != nil && w.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, 300, time.Now().Unix())
+	}
+	_ = w.WriteMsg(m)
+}
+
+// update authenticates and applies the update, returns the response code.
+func (g *gateway) update(w dns.ResponseWriter, r *dns.Msg) int {
+
+	if r.Opcode != dns.OpcodeUpdate {
+		return dns.RcodeNotImplemented
+	}
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError
+	}
+	zone := dns.CanonicalName(r.Question[0].Name)
+
+	t := r.IsTsig()
+	if t == nil {
+		return dns.RcodeRefused
+	}
+	k, ok := g.keys[dns.CanonicalName(t.Hdr.Name)]
+	if !ok || w.TsigStatus() != nil || dns.CanonicalName(t.Algorithm) != k.Algorithm {
+		fmt.Printf("RFC 2136 update of '%s' from %s rejected: TSIG verification failed\n", zone, w.RemoteAddr())
+		return dns.RcodeNotAuth
+	}
+	if !k.permits(zone) {
+		fmt.Printf("RFC 2136 update of '%s' rejected: key '%s' is not permitted\n", zone, k.Name)
+		return dns.RcodeRefused
+	}
+	for _, rr := range append(append([]dns.RR{}, r.Answer...), r.Ns...) {
+		if !dns.IsSubDomain(zone, dns.CanonicalName(rr.Header().Name)) {
+			return dns.RcodeNotZone
+		}
+	}
+
+	u := &gatewayUpdate{key: k, zone: zone}
+	if rcode := u.checkPrerequisites(r.Answer, r.Question[0].Qclass); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	ops, rcode := u.operations(r.Ns, r.Question[0].Qclass)
+	if rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if err := u.apply(ops); err != nil {
+		fmt.Printf("RFC 2136 update of '%s' failed: %v\n", zone, err)
+		return dns.RcodeServerFailure
+	}
+	return dns.RcodeSuccess
+}
+
+// permits reports whether the key may update the name (one of its zones or a subdomain).
+func (k *gatewayKey) permits(name string) bool {
+	for _, z := range k.Zones {
+		if dns.IsSubDomain(z, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayUpdate is an update message being applied.
+type gatewayUpdate struct {
+	key     *gatewayKey
+	zone    string
+	records miab.Records
+}
+
+// gatewayOp is a change of the update section: add a record, delete a record (value), an RRset (rtype) or all
+// RRsets of a name.
+type gatewayOp struct {
+	add   bool
+	name  string
+	rtype miab.ResourceType
+	value string
+}
+
+// current returns the custom records of the box, fetched once per update.
+func (u *gatewayUpdate) current() (miab.Records, error) {
+	if u.records != nil {
+		return u.records, nil
+	}
+	records, err := miab.GetDns(u.key.client, "", miab.NONE)
+	if err != nil {
+		return nil, err
+	}
+	u.records = records
+	if u.records == nil {
+		u.records = miab.Records{}
+	}
+	return u.records, nil
+}
+
+// lookup returns the records of the name, optionally limited to the rtype.
+func (u *gatewayUpdate) lookup(name string, rtype miab.ResourceType) (miab.Records, error) {
+	records, err := u.current()
+	if err != nil {
+		return nil, err
+	}
+	var res miab.Records
+	for _, r := range records {
+		if strings.EqualFold(strings.TrimSuffix(r.QName, "."), name) && (rtype == miab.NONE || r.RType == rtype) {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// checkPrerequisites evaluates the prerequisite section (RFC 2136, section 3.2). The value dependent prerequisites
+// of a name and type form an RRset, that has to match the records exactly.
+func (u *gatewayUpdate) checkPrerequisites(prereqs []dns.RR, class uint16) int {
+
+	type rrset struct {
+		name  string
+		rtype miab.ResourceType
+	}
+	var sets []rrset
+	values := map[rrset][]string{}
+
+	for _, rr := range prereqs {
+		h := rr.Header()
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		name := strings.TrimSuffix(dns.CanonicalName(h.Name), ".")
+		rtype := miab.NONE
+		if h.Rrtype != dns.TypeANY {
+			rtype = miab.ResourceType(dns.TypeToString[h.Rrtype])
+		}
+
+		switch h.Class {
+		case dns.ClassANY, dns.ClassNONE:
+		case class:
+			if h.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+			set := rrset{strings.ToLower(name), rtype}
+			if _, ok := values[set]; !ok {
+				sets = append(sets, set)
+			}
+			values[set] = append(values[set], rrValue(rr))
+			continue
+		default:
+			return dns.RcodeFormatError
+		}
+
+		records, err := u.lookup(name, rtype)
+		if err != nil {
+			fmt.Printf("RFC 2136 update of '%s' failed: %v\n", u.zone, err)
+			return dns.RcodeServerFailure
+		}
+		if h.Class == dns.ClassANY {
+			if len(records) == 0 && h.Rrtype == dns.TypeANY {
+				return dns.RcodeNameError
+			}
+			if len(records) == 0 {
+				return dns.RcodeNXRrset
+			}
+		} else {
+			if len(records) > 0 && h.Rrtype == dns.TypeANY {
+				return dns.RcodeYXDomain
+			}
+			if len(records) > 0 {
+				return dns.RcodeYXRrset
+			}
+		}
+	}
+
+	for _, set := range sets {
+		records, err := u.lookup(set.name, set.rtype)
+		if err != nil {
+			fmt.Printf("RFC 2136 update of '%s' failed: %v\n", u.zone, err)
+			return dns.RcodeServerFailure
+		}
+		current := make([]string, 0, len(records))
+		for _, r := range records {
+			current = append(current, r.Value)
+		}
+		if !sameValues(set.rtype, current, values[set]) {
+			return dns.RcodeNXRrset
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// sameValues reports whether both lists contain the same values of the record type, duplicates are ignored.
+func sameValues(rtype miab.ResourceType, a, b []string) bool {
+	contains := func(values []string, v string) bool {
+		for _, x := range values {
+			if equalValue(rtype, x, v) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range a {
+		if !contains(b, v) {
+			return false
+		}
+	}
+	for _, v := range b {
+		if !contains(a, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// operations translates the update section (RFC 2136, section 3.4.2).
+func (u *gatewayUpdate) operations(updates []dns.RR, class uint16) ([]gatewayOp, int) {
+
+	var ops []gatewayOp
+	for _, rr := range updates {
+		h := rr.Header()
+		op := gatewayOp{name: strings.TrimSuffix(dns.CanonicalName(h.Name), "."), rtype: miab.NONE}
+		if !u.key.permits(dns.CanonicalName(h.Name)) {
+			return nil, dns.RcodeRefused
+		}
+		if h.Rrtype != dns.TypeANY {
+			op.rtype = miab.ResourceType(dns.TypeToString[h.Rrtype])
+			if !op.rtype.IsValid() || op.rtype == miab.NONE {
+				fmt.Printf("RFC 2136 update of '%s' refused: unsupported type %s\n", u.zone, dns.TypeToString[h.Rrtype])
+				return nil, dns.RcodeRefused
+			}
+		}
+
+		switch h.Class {
+		case class:
+			if op.rtype == miab.NONE {
+				return nil, dns.RcodeFormatError
+			}
+			op.add, op.value = true, rrValue(rr)
+		case dns.ClassANY:
+			// delete an RRset or all RRsets of a name
+		case dns.ClassNONE:
+			if op.rtype == miab.NONE {
+				return nil, dns.RcodeFormatError
+			}
+			op.value = rrValue(rr)
+		default:
+			return nil, dns.RcodeFormatError
+		}
+		ops = append(ops, op)
+	}
+	return ops, dns.RcodeSuccess
+}
+
+// apply sends the operations to the box. An RRset deleted and added again (e.g. by nsupdate) is replaced via
+// SetDns or ReplaceRecordSet. The NS records of the zone apex are never deleted with an RRset or all RRsets of the
+// name (RFC 2136, section 3.4.2.3). The operations are not canceled on shutdown, so an update is never applied in
+// part; each request is bounded by the timeout of the box.
+func (u *gatewayUpdate) apply(ops []gatewayOp) error {
+
+	c := u.key.client
+	apex := strings.TrimSuffix(u.zone, ".")
+	done := make([]bool, len(ops))
+	for i, op := range ops {
+		if done[i] {
+			continue
+		}
+		if !op.add && op.value == "" && op.rtype == miab.NS && strings.EqualFold(op.name, apex) {
+			continue
+		}
+
+		switch {
+		case op.add:
+			records, err := u.lookup(op.name, op.rtype)
+			if err != nil {
+				return err
+			}
+			exists := false
+			for _, r := range records {
+				exists = exists || equalValue(op.rtype, r.Value, op.value)
+			}
+			if exists {
+				continue
+			}
+			if _, err := miab.AddDns(c, op.name, op.rtype, op.value); err != nil {
+				return err
+			}
+			fmt.Printf("RFC 2136 update: added %s (%s) %s\n", op.name, op.rtype, op.value)
+
+		case op.value == "" && op.rtype != miab.NONE:
+			if values, ok := replacement(ops[i+1:], op, done[i+1:]); ok {
+				var err error
+				if len(values) == 1 {
+					_, err = miab.SetDns(c, op.name, op.rtype, values[0])
+				} else {
+					_, err = miab.ReplaceRecordSet(c, op.name, op.rtype, values)
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Printf("RFC 2136 update: set %s (%s) %s\n", op.name, op.rtype, strings.Join(values, ", "))
+				break
+			}
+			if _, err := miab.DeleteDns(c, op.name, op.rtype, ""); err != nil {
+				return err
+			}
+			fmt.Printf("RFC 2136 update: deleted %s (%s)\n", op.name, op.rtype)
+
+		case op.value == "":
+			records, err := u.lookup(op.name, miab.NONE)
+			if err != nil {
+				return err
+			}
+			deleted := map[miab.ResourceType]bool{}
+			for _, r := range records {
+				if deleted[r.RType] || r.RType == miab.NS && strings.EqualFold(op.name, apex) {
+					continue
+				}
+				if _, err := miab.DeleteDns(c, op.name, r.RType, ""); err != nil {
+					return err
+				}
+				deleted[r.RType] = true
+			}
+			fmt.Printf("RFC 2136 update: deleted %s\n", op.name)
+
+		default:
+			if _, err := miab.DeleteDns(c, op.name, op.rtype, op.value); err != nil {
+				return err
+			}
+			fmt.Printf("RFC 2136 update: deleted %s (%s) %s\n", op.name, op.rtype, op.value)
+		}
+
+		// the records changed, they are fetched again if needed
+		u.records = nil
+	}
+	return nil
+}
+
+// replacement returns the values added to the RRset after the deletion of the RRset (op), if the RRset is only
+// added to afterwards. The operations are marked as done then.
+func replacement(ops []gatewayOp, op gatewayOp, done []bool) ([]string, bool) {
+	var values []string
+	var idx []int
+	for i, o := range ops {
+		if !strings.EqualFold(o.name, op.name) || o.rtype != op.rtype && o.rtype != miab.NONE {
+			continue
+		}
+		if !o.add {
+			return nil, false
+		}
+		values = append(values, o.value)
+		idx = append(idx, i)
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	for _, i := range idx {
+		done[i] = true
+	}
+	return values, true
+}
+
+// rrValue returns the value of a resource record in the format of the Mail-in-a-Box API.
+func rrValue(rr dns.RR) string {
+	if t, ok := rr.(*dns.TXT); ok {
+		return strings.Join(t.Txt, "")
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// equalValue compares two values of a record type in their canonical form.
+func equalValue(rtype miab.ResourceType, a, b string) bool {
+	if va, err := miab.ParseRecordValue(rtype, a); err == nil {
+		a = va.String()
+	}
+	if vb, err := miab.ParseRecordValue(rtype, b); err == nil {
+		b = vb.String()
+	}
+	if rtype == miab.TXT {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
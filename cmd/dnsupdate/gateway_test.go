@@ -0,0 +1,259 @@
+package main
+
+import (
+	"github.com/miekg/dns"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyName   = "update.example.org."
+	testKeySecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+// startGateway starts the gateway with a key for example.org on the box, returns the address of the server.
+func startGateway(t *testing.T, box *testBox) string {
+
+	c := &gatewayConfig{
+		Listen: "127.0.0.1:0",
+		Keys:   []gatewayKey{{Name: testKeyName, Secret: testKeySecret, Zones: []string{"example.org"}, Box: "box"}},
+	}
+	if errs := c.validate(map[string]*miab.Config{"box": box.client}); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newGateway(c).servers(c.Listen)[0]
+	srv.PacketConn = pc
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func rrs(t *testing.T, s ...string) []dns.RR {
+	var res []dns.RR
+	for _, x := range s {
+		rr, err := dns.NewRR(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, rr)
+	}
+	return res
+}
+
+func TestGateway_Update(t *testing.T) {
+
+	initial := miab.Records{
+		{QName: "example.org", RType: miab.NS, Value: "ns1.example.org."},
+		{QName: "example.org", RType: miab.TXT, Value: "v=spf1 mx -all"},
+		{QName: "www.example.org", RType: miab.A, Value: "192.0.2.1"},
+		{QName: "www.example.org", RType: miab.A, Value: "192.0.2.2"},
+	}
+
+	testCases := []struct {
+		name    string
+		zone    string
+		key     string
+		secret  string
+		build   func(m *dns.Msg)
+		want    int
+		changes []string
+		records []string
+	}{
+		{"add", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeSuccess, []string{"POST host.example.org A 192.0.2.10"}, nil},
+		{"add existing", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Insert(rrs(t, "www.example.org. 300 IN A 192.0.2.1"))
+		}, dns.RcodeSuccess, nil, nil},
+		{"delete value", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Remove(rrs(t, "www.example.org. 0 IN A 192.0.2.2"))
+		}, dns.RcodeSuccess, []string{"DELETE www.example.org A 192.0.2.2"}, nil},
+		{"no tsig", "example.org.", "", "", func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeRefused, nil, nil},
+		{"wrong secret", "example.org.", testKeyName, "b3RoZXJvdGhlcm90aGVy", func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeNotAuth, nil, nil},
+		{"zone not permitted", "example.com.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.com. 300 IN A 192.0.2.10"))
+		}, dns.RcodeRefused, nil, nil},
+		{"name outside of the zone", "sub.example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeNotZone, nil, nil},
+		{"unsupported type", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Insert(rrs(t, "host.example.org. 300 IN HINFO cpu os"))
+		}, dns.RcodeRefused, nil, nil},
+		{"rrset exists", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RRsetUsed(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeSuccess, []string{"POST host.example.org A 192.0.2.10"}, nil},
+		{"rrset missing", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RRsetUsed(rrs(t, "host.example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeNXRrset, nil, nil},
+		{"rrset not expected", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RRsetNotUsed(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeYXRrset, nil, nil},
+		{"name missing", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.NameUsed(rrs(t, "host.example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeNameError, nil, nil},
+		{"name not expected", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.NameNotUsed(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeYXDomain, nil, nil},
+		{"rrset matches exactly", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Used(rrs(t, "www.example.org. 0 IN A 192.0.2.2", "WWW.example.org. 0 IN A 192.0.2.1"))
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeSuccess, []string{"POST host.example.org A 192.0.2.10"}, nil},
+		{"rrset is a superset", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Used(rrs(t, "www.example.org. 0 IN A 192.0.2.1"))
+			m.Insert(rrs(t, "host.example.org. 300 IN A 192.0.2.10"))
+		}, dns.RcodeNXRrset, nil, nil},
+		{"rrset differs", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Used(rrs(t, "www.example.org. 0 IN A 192.0.2.1", "www.example.org. 0 IN A 192.0.2.3"))
+		}, dns.RcodeNXRrset, nil, nil},
+		{"prerequisite of another class", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: "www.example.org.", Rrtype: dns.TypeA,
+				Class: dns.ClassCHAOS}, A: net.ParseIP("192.0.2.1")})
+		}, dns.RcodeFormatError, nil, nil},
+		{"prerequisite with ttl", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.Answer = append(m.Answer, rrs(t, "www.example.org. 300 IN A 192.0.2.1")...)
+		}, dns.RcodeFormatError, nil, nil},
+		{"replacement", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveRRset(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+			m.Insert(rrs(t, "www.example.org. 300 IN A 192.0.2.3"))
+		}, dns.RcodeSuccess, []string{"PUT www.example.org A 192.0.2.3"}, nil},
+		{"replacement set", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveRRset(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+			m.Insert(rrs(t, "www.example.org. 300 IN A 192.0.2.2", "www.example.org. 300 IN A 192.0.2.3"))
+		}, dns.RcodeSuccess, []string{"POST www.example.org A 192.0.2.3", "DELETE www.example.org A 192.0.2.1"}, nil},
+		{"no replacement", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveRRset(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+			m.Insert(rrs(t, "www.example.org. 300 IN A 192.0.2.3"))
+			m.Remove(rrs(t, "www.example.org. 0 IN A 192.0.2.3"))
+		}, dns.RcodeSuccess, []string{"DELETE www.example.org A", "POST www.example.org A 192.0.2.3",
+			"DELETE www.example.org A 192.0.2.3"}, nil},
+		{"delete all rrsets", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveName(rrs(t, "www.example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeSuccess, []string{"DELETE www.example.org A"}, nil},
+		{"delete all rrsets of the apex", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveName(rrs(t, "example.org. 0 IN A 0.0.0.0"))
+		}, dns.RcodeSuccess, []string{"DELETE example.org TXT"}, []string{
+			"example.org NS ns1.example.org.", "www.example.org A 192.0.2.1", "www.example.org A 192.0.2.2"}},
+		{"delete the ns rrset of the apex", "example.org.", testKeyName, testKeySecret, func(m *dns.Msg) {
+			m.RemoveRRset(rrs(t, "example.org. 0 IN NS ns.example.org."))
+		}, dns.RcodeSuccess, nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := newTestBox(t, initial...)
+			addr := startGateway(t, box)
+
+			m := new(dns.Msg)
+			m.SetUpdate(tc.zone)
+			tc.build(m)
+			c := &dns.Client{Timeout: 5 * time.Second}
+			if tc.key != "" {
+				m.SetTsig(tc.key, dns.HmacSHA256, 300, time.Now().Unix())
+				c.TsigSecret = map[string]string{tc.key: tc.secret}
+			}
+
+			r, _, err := c.Exchange(m, addr)
+			if err != nil && tc.want != dns.RcodeNotAuth {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if r == nil {
+				t.Fatal("no response")
+			}
+			if r.Rcode != tc.want {
+				t.Errorf("want: %s, got: %s", dns.RcodeToString[tc.want], dns.RcodeToString[r.Rcode])
+			}
+			if got := box.changes(); !reflect.DeepEqual(got, tc.changes) {
+				t.Errorf("want changes: %q, got: %q", tc.changes, got)
+			}
+			if tc.records != nil {
+				if got := box.values(); !reflect.DeepEqual(got, tc.records) {
+					t.Errorf("want records: %q, got: %q", tc.records, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReplacement(t *testing.T) {
+
+	del := gatewayOp{name: "www.example.org", rtype: miab.A}
+	testCases := []struct {
+		name string
+		ops  []gatewayOp
+		want []string
+		ok   bool
+		done []bool
+	}{
+		{"added", []gatewayOp{
+			{add: true, name: "www.example.org", rtype: miab.A, value: "192.0.2.1"},
+			{add: true, name: "mail.example.org", rtype: miab.A, value: "192.0.2.2"},
+			{add: true, name: "WWW.example.org", rtype: miab.A, value: "192.0.2.3"},
+		}, []string{"192.0.2.1", "192.0.2.3"}, true, []bool{true, false, true}},
+		{"other type", []gatewayOp{
+			{add: true, name: "www.example.org", rtype: miab.AAAA, value: "2001:db8::1"},
+		}, nil, false, []bool{false}},
+		{"deleted afterwards", []gatewayOp{
+			{add: true, name: "www.example.org", rtype: miab.A, value: "192.0.2.1"},
+			{name: "www.example.org", rtype: miab.A, value: "192.0.2.1"},
+		}, nil, false, []bool{false, false}},
+		{"name deleted afterwards", []gatewayOp{
+			{add: true, name: "www.example.org", rtype: miab.A, value: "192.0.2.1"},
+			{name: "www.example.org", rtype: miab.NONE},
+		}, nil, false, []bool{false, false}},
+		{"none", nil, nil, false, []bool{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			done := make([]bool, len(tc.ops))
+			values, ok := replacement(tc.ops, del, done)
+			if ok != tc.ok || !reflect.DeepEqual(values, tc.want) {
+				t.Errorf("want: %v (%v), got: %v (%v)", tc.want, tc.ok, values, ok)
+			}
+			if !reflect.DeepEqual(done, tc.done) {
+				t.Errorf("want done: %v, got: %v", tc.done, done)
+			}
+		})
+	}
+}
+
+func TestEqualValue(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		rtype miab.ResourceType
+		a, b  string
+		want  bool
+	}{
+		{"same address", miab.AAAA, "2001:db8::1", "2001:DB8:0::1", true},
+		{"other address", miab.A, "192.0.2.1", "192.0.2.2", false},
+		{"host name", miab.CNAME, "www.example.org", " WWW.example.org. ", true},
+		{"text", miab.TXT, "v=spf1 -all", "v=spf1 -all", true},
+		{"text case", miab.TXT, "v=spf1 -all", "V=SPF1 -all", false},
+		{"text white space", miab.TXT, " v=spf1 -all", "v=spf1 -all", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := equalValue(tc.rtype, tc.a, tc.b); got != tc.want {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,110 +1,216 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"github.com/rverst/go-miab/miab"
+	"github.com/miekg/dns"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// shutdownTimeout is the maximal duration to wait for in-flight updates and requests on shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// boxTimeout is the maximal duration of a request to a box, shorter than shutdownTimeout so that an update in
+// progress ends before the shutdown gives up on it.
+const boxTimeout = 20 * time.Second
+
 // Provides a simple method to update custom address records on your Mail-in-a-Box instance. This tool is designed to
 // run e.g. in a docker container and updates the address records for the configured domains at a given interval.
+// The boxes and domains are read from a config file (-config or DNS_CONFIG) and the environment (DNS_*), records of
+// other types are kept in sync with the value of a file, command or template and failover records hold the healthy
+// addresses of their targets.
+// With -once, the records are updated a single time and the exit status reports a failure (e.g. for cron).
 func main() {
 
 	fmt.Println("dnsudpate version: 1.0.0")
-	interval, err := strconv.ParseInt(os.Getenv("DNS_INTERVAL"), 10, 32)
-	if err != nil {
-		fmt.Println("Unable to parse interval (seconds), check environment (DNS_INTERVAL). Has to be a unsigned int.")
-		os.Exit(99)
-	}
-
-	if interval < 30 {
-		interval = 30
-	}
 
-	user := os.Getenv("DNS_USER")
-	pass := os.Getenv("DNS_PASSWORD")
-	endp := os.Getenv("DNS_ENDPOINT")
+	file := flag.String("config", os.Getenv("DNS_CONFIG"), "path of the config file (YAML, TOML or JSON), "+
+		"otherwise the environment is used (DNS_INTERVAL in seconds defaults to 1800)")
+	once := flag.Bool("once", false, "update the records a single time and exit, the exit status is 1 on failure")
+	flag.Parse()
 
-	c, err := miab.NewConfig(user, pass, endp)
+	cfg, err := loadConfig(*file)
 	if err != nil {
 		fmt.Println(err)
-		os.Exit(91)
+		os.Exit(1)
+	}
+	for _, b := range cfg.Boxes {
+		if b.client.Insecure() {
+			fmt.Printf("WARNING: TLS certificate verification is disabled for box '%s', "+
+				"the connection and your credentials are NOT protected! Consider using a pinned certificate instead.\n", b.Name)
+		}
 	}
 
-	doV4, err := strconv.ParseBool(os.Getenv("DNS_A"))
+	c, err := newCache(cfg.Cache)
 	if err != nil {
-		doV4 = false
+		fmt.Printf("Unable to read the cache: %v\n", err)
+		os.Exit(1)
 	}
-	doV6, err := strconv.ParseBool(os.Getenv("DNS_AAAA"))
-	if err != nil {
-		doV6 = false
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	verifyRecords(ctx, cfg.Domains, c)
+
+	u := &updater{
+		cache:         c,
+		metrics:       newMetrics(),
+		notifications: cfg.notifications,
+		threshold:     cfg.FailureThreshold,
+		failures:      map[record]int{},
 	}
-	d := os.Getenv("DNS_DOMAINS")
-	var domains []string
-	if strings.Contains(d, ";") {
-		domains = strings.Split(d, ";")
-	} else {
-		domains = strings.Split(d, ",")
+	u.startNotifications()
+	for _, d := range cfg.Domains {
+		for _, rtype := range d.RTypes {
+			u.metrics.register(d.Name, rtype, time.Duration(d.Interval))
+		}
+	}
+	for _, r := range cfg.Records {
+		u.metrics.register(r.Name, r.RType, time.Duration(r.Interval))
+	}
+	for _, f := range cfg.Failover {
+		u.metrics.register(f.Name, f.RType, time.Duration(f.Interval))
 	}
 
-	sigs := make(chan os.Signal, 1)
-	done := make(chan bool, 1)
+	if *once {
+		ok := true
+		for _, d := range cfg.Domains {
+			ok = u.doDnsUpdate(ctx, d) && ok
+		}
+		for _, r := range cfg.Records {
+			ok = u.syncRecord(ctx, r) && ok
+		}
+		// a single check decides on the health of the targets
+		for _, f := range cfg.Failover {
+			f.Rise, f.Fall = 1, 1
+			ok = u.doFailover(ctx, newFailover(f)) && ok
+		}
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if !u.stopNotifications(sctx) {
+			fmt.Println("Timeout sending the notifications")
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
 
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
-	ticker := time.NewTicker(time.Second * time.Duration(interval))
-	defer ticker.Stop()
+	var servers []func(ctx context.Context) error
+	if cfg.Listen != "" {
+		servers = append(servers, serveHttp("HTTP", cfg.Listen, u.metrics.handler(), "", ""))
+	}
+	if cfg.DynDns != nil {
+		s := newDyndnsServer(cfg.DynDns, u)
+		servers = append(servers, serveHttp("DynDNS", cfg.DynDns.Listen, s.handler(), cfg.DynDns.TLSCert, cfg.DynDns.TLSKey))
+	}
+	if cfg.RFC2136 != nil {
+		for _, srv := range newGateway(cfg.RFC2136).servers(cfg.RFC2136.Listen) {
+			servers = append(servers, serveDns(srv))
+		}
+	}
 
-	go func() {
-		<-sigs         // blocked until signal received
-		ticker.C = nil // close ticker channel
-		done <- true   // write on channel to release
-	}()
+	var wg sync.WaitGroup
+	for _, d := range cfg.Domains {
+		wg.Add(1)
+		go func(d domainConfig) {
+			defer wg.Done()
+			run(ctx, time.Duration(d.Interval), func(ctx context.Context) bool { return u.doDnsUpdate(ctx, d) })
+		}(d)
+	}
+	for _, r := range cfg.Records {
+		wg.Add(1)
+		go func(r recordConfig) {
+			defer wg.Done()
+			run(ctx, time.Duration(r.Interval), func(ctx context.Context) bool { return u.syncRecord(ctx, r) })
+		}(r)
+	}
+	for _, f := range cfg.Failover {
+		wg.Add(1)
+		go func(f *failover) {
+			defer wg.Done()
+			run(ctx, time.Duration(f.Interval), func(ctx context.Context) bool { return u.doFailover(ctx, f) })
+		}(newFailover(f))
+	}
 
-	go func() {
-		doDnsUpdate(c, domains, doV4, doV6)
-		for range ticker.C {
-			doDnsUpdate(c, domains, doV4, doV6)
+	if err := sdNotify("READY=1"); err != nil {
+		fmt.Printf("Unable to notify systemd: %v\n", err)
+	}
+	go sdWatchdog(ctx, u.metrics)
+
+	// blocked until signal received
+	<-ctx.Done()
+	stop()
+	fmt.Println("Shutting down, waiting for in-flight updates and requests")
+	_ = sdNotify("STOPPING=1")
+
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, shutdown := range servers {
+		if err := shutdown(sctx); err != nil {
+			fmt.Printf("Shutdown of a server failed: %v\n", err)
 		}
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
 	}()
-
-	// blocked until channel is written
-	<-done
+	select {
+	case <-done:
+	case <-sctx.Done():
+		fmt.Println("Timeout waiting for in-flight updates")
+		os.Exit(1)
+	}
+	if !u.stopNotifications(sctx) {
+		fmt.Println("Timeout sending the notifications")
+		os.Exit(1)
+	}
 }
 
-func doDnsUpdate(config *miab.Config, domains []string, doV4 bool, doV6 bool) {
+// serveHttp starts an HTTP(S) server, returns the function to shut it down gracefully.
+func serveHttp(name, addr string, handler http.Handler, certFile, keyFile string) func(ctx context.Context) error {
 
-	for _, d := range domains {
-		d = strings.Trim(d, " ")
-		if d == "" {
-			continue
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Printf("Unable to listen on %s: %v\n", addr, err)
+		os.Exit(1)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if certFile != "" {
+			err = srv.ServeTLS(l, certFile, keyFile)
+		} else {
+			err = srv.Serve(l)
+		}
+		if err != http.ErrServerClosed {
+			fmt.Printf("%s server failed: %v\n", name, err)
 		}
+	}()
+	return srv.Shutdown
+}
 
-		if doV4 {
-			b, err := miab.UpdateDns4(config, d, "")
-			if err != nil {
-				fmt.Printf("DNS update (A) for '%s' failed with error: %v\n", d, err)
-			} else if b {
-				fmt.Printf("DNS update (A) for '%s' successful\n", d)
-			} else {
-				fmt.Printf("DNS update (A) for '%s' failed\n", d)
-			}
+// serveDns starts a DNS server, returns the function to shut it down gracefully.
+func serveDns(srv *dns.Server) func(ctx context.Context) error {
 
+	started := make(chan error, 1)
+	srv.NotifyStartedFunc = func() { started <- nil }
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			started <- err
 		}
-		if doV6 {
-			b, err := miab.UpdateDns6(config, d, "")
-			if err != nil {
-				fmt.Printf("DNS update (AAAA) for '%s' failed with error: %v\n", d, err)
-			} else if b {
-				fmt.Printf("DNS update (AAAA) for '%s' successful\n", d)
-			} else {
-				fmt.Printf("DNS update (AAAA) for '%s' failed\n", d)
-			}
-		}
+	}()
+	if err := <-started; err != nil {
+		fmt.Printf("Unable to listen on %s/%s: %v\n", srv.Addr, srv.Net, err)
+		os.Exit(1)
 	}
-
+	return srv.ShutdownContext
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staleIntervals is the number of intervals without a successful update after which a record is stale, and
+// without any attempt after which the update of a record is considered hung.
+const staleIntervals = 3
+
+// record identifies a record of a domain.
+type record struct {
+	domain string
+	rtype  string
+}
+
+// recordStats holds the results of the updates of a record.
+type recordStats struct {
+	interval    time.Duration
+	attempts    uint64
+	successes   uint64
+	failures    uint64
+	lastAttempt time.Time
+	lastSuccess time.Time
+	address     string // the current value of the record
+}
+
+// metrics collects the results of the updates, exposed in the Prometheus text format and by the health endpoints.
+type metrics struct {
+	mu      sync.Mutex
+	started time.Time
+	records map[record]*recordStats
+}
+
+func newMetrics() *metrics {
+	return &metrics{started: time.Now(), records: map[record]*recordStats{}}
+}
+
+// register adds a record, so it is exposed before the first update.
+func (m *metrics) register(name, rtype string, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record{name, rtype}] = &recordStats{interval: interval}
+}
+
+// result records the result of an update of a registered record, the value is empty if it is not known (source
+// box).
+func (m *metrics) result(name, rtype, value string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.records[record{name, rtype}]
+	if !ok {
+		return
+	}
+	s.attempts++
+	s.lastAttempt = time.Now()
+	if err != nil {
+		s.failures++
+		return
+	}
+	s.successes++
+	s.lastSuccess = time.Now()
+	if value != "" {
+		s.address = value
+	}
+}
+
+// push records the result of an update pushed by a client (DynDNS), the record is added by its first update and
+// never gets stale.
+func (m *metrics) push(name, rtype, value string, err error) {
+	m.mu.Lock()
+	if _, ok := m.records[record{name, rtype}]; !ok {
+		m.records[record{name, rtype}] = &recordStats{}
+	}
+	m.mu.Unlock()
+	m.result(name, rtype, value, err)
+}
+
+// sorted returns the records in lexical order.
+func (m *metrics) sorted() []record {
+	res := make([]record, 0, len(m.records))
+	for r := range m.records {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].domain != res[j].domain {
+			return res[i].domain < res[j].domain
+		}
+		return res[i].rtype < res[j].rtype
+	})
+	return res
+}
+
+// write writes the metrics in the Prometheus text format.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records := m.sorted()
+	family := func(name, typ, help string, value func(s *recordStats) (string, bool)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, r := range records {
+			if v, ok := value(m.records[r]); ok {
+				fmt.Fprintf(w, "%s{domain=\"%s\",rtype=\"%s\"} %s\n", name, escapeLabel(r.domain), r.rtype, v)
+			}
+		}
+	}
+
+	family("dnsupdate_update_attempts_total", "counter", "Number of update attempts of a record.",
+		func(s *recordStats) (string, bool) { return fmt.Sprint(s.attempts), true })
+	family("dnsupdate_update_successes_total", "counter", "Number of successful updates (or verifications) of a record.",
+		func(s *recordStats) (string, bool) { return fmt.Sprint(s.successes), true })
+	family("dnsupdate_update_failures_total", "counter", "Number of failed updates of a record.",
+		func(s *recordStats) (string, bool) { return fmt.Sprint(s.failures), true })
+	family("dnsupdate_last_success_timestamp_seconds", "gauge", "Unix time of the last successful update of a record.",
+		func(s *recordStats) (string, bool) {
+			if s.lastSuccess.IsZero() {
+				return "", false
+			}
+			return fmt.Sprintf("%.3f", float64(s.lastSuccess.UnixNano())/1e9), true
+		})
+
+	name := "dnsupdate_address_info"
+	fmt.Fprintf(w, "# HELP %s The current address (or value) of a record.\n# TYPE %s gauge\n", name, name)
+	for _, r := range records {
+		if a := m.records[r].address; a != "" {
+			fmt.Fprintf(w, "%s{domain=\"%s\",rtype=\"%s\",address=\"%s\"} 1\n", name, escapeLabel(r.domain), r.rtype, escapeLabel(a))
+		}
+	}
+}
+
+// unready returns the records not updated successfully yet or for more than staleIntervals intervals.
+func (m *metrics) unready() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []string
+	for _, r := range m.sorted() {
+		s := m.records[r]
+		if s.lastSuccess.IsZero() {
+			res = append(res, fmt.Sprintf("%s (%s): not updated yet", r.domain, r.rtype))
+		} else if d := time.Since(s.lastSuccess); s.interval > 0 && d > staleIntervals*s.interval {
+			res = append(res, fmt.Sprintf("%s (%s): last update %s ago", r.domain, r.rtype, d.Round(time.Second)))
+		}
+	}
+	return res
+}
+
+// hung returns the records updated in an interval, that have not been attempted to update for staleIntervals
+// intervals (since the start if never attempted).
+func (m *metrics) hung() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []string
+	for _, r := range m.sorted() {
+		s := m.records[r]
+		last := s.lastAttempt
+		if last.IsZero() {
+			last = m.started
+		}
+		if d := time.Since(last); s.interval > 0 && d > staleIntervals*s.interval {
+			res = append(res, fmt.Sprintf("%s (%s): no attempt for %s", r.domain, r.rtype, d.Round(time.Second)))
+		}
+	}
+	return res
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// handler returns the handler of the endpoints /healthz (alive), /readyz (all records up to date) and /metrics.
+func (m *metrics) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if u := m.unready(); len(u) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(strings.Join(u, "\n") + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+	})
+	return mux
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_Write(t *testing.T) {
+
+	m := newMetrics()
+	m.register("b.example.org", "A", time.Minute)
+	m.register("a.example.org", "AAAA", time.Minute)
+	m.register("a.example.org", "A", time.Minute)
+	m.result("a.example.org", "A", "192.0.2.1", nil)
+	m.result("a.example.org", "A", "", errors.New("failed"))
+	m.result("a.example.org", "AAAA", "", nil)
+	m.result("unknown.example.org", "A", "192.0.2.9", nil)
+	m.push(`q"uote\d.example.org`, "TXT", "v=spf1\n-all", nil)
+	for _, s := range m.records {
+		if !s.lastSuccess.IsZero() {
+			s.lastSuccess = time.Unix(1700000000, 500000000)
+		}
+	}
+
+	want := `# HELP dnsupdate_update_attempts_total Number of update attempts of a record.
+# TYPE dnsupdate_update_attempts_total counter
+dnsupdate_update_attempts_total{domain="a.example.org",rtype="A"} 2
+dnsupdate_update_attempts_total{domain="a.example.org",rtype="AAAA"} 1
+dnsupdate_update_attempts_total{domain="b.example.org",rtype="A"} 0
+dnsupdate_update_attempts_total{domain="q\"uote\\d.example.org",rtype="TXT"} 1
+# HELP dnsupdate_update_successes_total Number of successful updates (or verifications) of a record.
+# TYPE dnsupdate_update_successes_total counter
+dnsupdate_update_successes_total{domain="a.example.org",rtype="A"} 1
+dnsupdate_update_successes_total{domain="a.example.org",rtype="AAAA"} 1
+dnsupdate_update_successes_total{domain="b.example.org",rtype="A"} 0
+dnsupdate_update_successes_total{domain="q\"uote\\d.example.org",rtype="TXT"} 1
+# HELP dnsupdate_update_failures_total Number of failed updates of a record.
+# TYPE dnsupdate_update_failures_total counter
+dnsupdate_update_failures_total{domain="a.example.org",rtype="A"} 1
+dnsupdate_update_failures_total{domain="a.example.org",rtype="AAAA"} 0
+dnsupdate_update_failures_total{domain="b.example.org",rtype="A"} 0
+dnsupdate_update_failures_total{domain="q\"uote\\d.example.org",rtype="TXT"} 0
+# HELP dnsupdate_last_success_timestamp_seconds Unix time of the last successful update of a record.
+# TYPE dnsupdate_last_success_timestamp_seconds gauge
+dnsupdate_last_success_timestamp_seconds{domain="a.example.org",rtype="A"} 1700000000.500
+dnsupdate_last_success_timestamp_seconds{domain="a.example.org",rtype="AAAA"} 1700000000.500
+dnsupdate_last_success_timestamp_seconds{domain="q\"uote\\d.example.org",rtype="TXT"} 1700000000.500
+# HELP dnsupdate_address_info The current address (or value) of a record.
+# TYPE dnsupdate_address_info gauge
+dnsupdate_address_info{domain="a.example.org",rtype="A",address="192.0.2.1"} 1
+dnsupdate_address_info{domain="q\"uote\\d.example.org",rtype="TXT",address="v=spf1\n-all"} 1
+`
+
+	var b strings.Builder
+	m.write(&b)
+	if got := b.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestMetrics_Unready(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		interval    time.Duration
+		lastSuccess time.Duration // before now, never if 0
+		pushed      bool
+		want        []string
+	}{
+		{"not updated yet", time.Minute, 0, false, []string{"home.example.org (A): not updated yet"}},
+		{"up to date", time.Minute, 2 * time.Minute, false, nil},
+		{"stale", time.Minute, 4 * time.Minute, false, []string{"home.example.org (A): last update 4m0s ago"}},
+		{"without interval", 0, time.Hour, false, nil},
+		{"pushed", 0, 0, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMetrics()
+			if tc.pushed {
+				m.push("home.example.org", "A", "192.0.2.1", nil)
+			} else {
+				m.register("home.example.org", "A", tc.interval)
+			}
+			if tc.lastSuccess > 0 {
+				m.records[record{"home.example.org", "A"}].lastSuccess = time.Now().Add(-tc.lastSuccess)
+			}
+			if got := m.unready(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMetrics_Handler(t *testing.T) {
+
+	m := newMetrics()
+	m.register("home.example.org", "A", time.Minute)
+	h := m.handler()
+
+	testCases := []struct {
+		name   string
+		path   string
+		update func()
+		status int
+		body   string
+	}{
+		{"alive", "/healthz", nil, http.StatusOK, "ok\n"},
+		{"not ready", "/readyz", nil, http.StatusServiceUnavailable, "home.example.org (A): not updated yet\n"},
+		{"failed", "/readyz", func() { m.result("home.example.org", "A", "", errors.New("failed")) },
+			http.StatusServiceUnavailable, "home.example.org (A): not updated yet\n"},
+		{"ready", "/readyz", func() { m.result("home.example.org", "A", "192.0.2.1", nil) }, http.StatusOK, "ok\n"},
+		{"stale", "/readyz", func() {
+			m.records[record{"home.example.org", "A"}].lastSuccess = time.Now().Add(-time.Hour)
+		}, http.StatusServiceUnavailable, "home.example.org (A): last update 1h0m0s ago\n"},
+		{"still alive", "/healthz", nil, http.StatusOK, "ok\n"},
+		{"metrics", "/metrics", nil, http.StatusOK, "# HELP dnsupdate_update_attempts_total"},
+		{"unknown", "/status", nil, http.StatusNotFound, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.update != nil {
+				tc.update()
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if w.Code != tc.status {
+				t.Errorf("status, want: %d, got: %d", tc.status, w.Code)
+			}
+			if !strings.HasPrefix(w.Body.String(), tc.body) {
+				t.Errorf("want: %q, got: %q", tc.body, w.Body.String())
+			}
+			if tc.path == "/metrics" && w.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("content type: %s", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestMetrics_Hung(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		interval    time.Duration
+		started     time.Duration // before now
+		lastAttempt time.Duration // before now, never if 0
+		pushed      bool
+		want        []string
+	}{
+		{"attempted", time.Minute, time.Hour, 2 * time.Minute, false, nil},
+		{"no attempt", time.Minute, time.Hour, 4 * time.Minute, false, []string{"home.example.org (A): no attempt for 4m0s"}},
+		{"just started", time.Minute, time.Minute, 0, false, nil},
+		{"never attempted", time.Minute, time.Hour, 0, false, []string{"home.example.org (A): no attempt for 1h0m0s"}},
+		{"without interval", 0, time.Hour, time.Hour, false, nil},
+		{"pushed", 0, time.Hour, time.Hour, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMetrics()
+			m.started = time.Now().Add(-tc.started)
+			if tc.pushed {
+				m.push("home.example.org", "A", "192.0.2.1", nil)
+			} else {
+				m.register("home.example.org", "A", tc.interval)
+			}
+			if tc.lastAttempt > 0 {
+				m.records[record{"home.example.org", "A"}].lastAttempt = time.Now().Add(-tc.lastAttempt)
+			}
+			if got := m.hung(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
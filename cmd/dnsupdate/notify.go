@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	notifyTimeout           = 30 * time.Second
+	notifyQueue             = 100 // events waiting for the notifiers, further events are dropped
+	defaultFailureThreshold = 3
+
+	eventChange   = "change"
+	eventFailure  = "failure"
+	eventRecovery = "recovery"
+)
+
+var (
+	defaultMessage = `{{if eq .Type "change"}}The address of {{.Domain}} ({{.RType}}) changed{{if .Previous}} from {{.Previous}}{{end}} to {{.Address}}.` +
+		`{{else if eq .Type "failure"}}The update of {{.Domain}} ({{.RType}}) failed {{.Failures}} times in a row: {{.Error}}` +
+		`{{else}}The update of {{.Domain}} ({{.RType}}) succeeded again after {{.Failures}} failures.{{end}}`
+	defaultSubject = `dnsupdate: {{.Type}} of {{.Domain}} ({{.RType}})`
+)
+
+// event describes an occurrence notifiers are triggered on.
+type event struct {
+	Type     string    `json:"type"` // change, failure or recovery
+	Domain   string    `json:"domain"`
+	RType    string    `json:"rtype"`
+	Address  string    `json:"address,omitempty"`
+	Previous string    `json:"previous,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+}
+
+// notifier sends the notification of an event, it is aborted if the context is canceled.
+type notifier interface {
+	notify(ctx context.Context, e event) error
+}
+
+// notifierConfig describes a notifier, the settings used depend on the type.
+type notifierConfig struct {
+	Type     string            `json:"type"`     // webhook, smtp or exec
+	Events   []string          `json:"events"`   // change, failure and recovery by default
+	Template string            `json:"template"` // text/template of the message
+	Url      string            `json:"url"`      // webhook: the url the event is posted to (JSON)
+	Headers  map[string]string `json:"headers"`  // webhook: additional headers, e.g. Authorization
+	Server   string            `json:"server"`   // smtp: host:port of the mail server (STARTTLS is used if offered)
+	TLS      string            `json:"tls"`      // smtp: 'required' to refuse a server without STARTTLS
+	User     string            `json:"user"`     // smtp: user for the authentication (optional)
+	Password string            `json:"password"` // smtp: password for the authentication
+	From     string            `json:"from"`     // smtp: sender address
+	To       []string          `json:"to"`       // smtp: recipient addresses
+	Subject  string            `json:"subject"`  // smtp: text/template of the subject
+	Command  string            `json:"command"`  // exec: executed by sh, gets the message on stdin
+}
+
+// notification is a configured notifier with its events and message template.
+type notification struct {
+	notifier
+	name    string
+	events  map[string]bool
+	message *template.Template
+}
+
+// newNotification creates the notifier of the config.
+func newNotification(c notifierConfig) (*notification, error) {
+
+	n := &notification{name: c.Type, events: map[string]bool{}}
+	events := c.Events
+	if len(events) == 0 {
+		events = []string{eventChange, eventFailure, eventRecovery}
+	}
+	for _, e := range events {
+		if e != eventChange && e != eventFailure && e != eventRecovery {
+			return nil, fmt.Errorf("unknown event '%s', use change, failure or recovery", e)
+		}
+		n.events[e] = true
+	}
+
+	msg := c.Template
+	if msg == "" {
+		msg = defaultMessage
+	}
+	var err error
+	if n.message, err = template.New("message").Parse(msg); err != nil {
+		return nil, fmt.Errorf("'template': %v", err)
+	}
+
+	switch c.Type {
+	case "webhook":
+		if c.Url == "" {
+			return nil, errors.New("'url' not specified")
+		}
+		n.notifier = webhookNotifier{url: c.Url, headers: c.Headers}
+		n.name += " " + c.Url
+	case "smtp":
+		if _, _, err := net.SplitHostPort(c.Server); err != nil {
+			return nil, fmt.Errorf("'server' has to be host:port: %v", err)
+		}
+		if c.From == "" || len(c.To) == 0 {
+			return nil, errors.New("'from' and 'to' have to be specified")
+		}
+		if c.TLS != "" && c.TLS != "required" {
+			return nil, fmt.Errorf("unknown 'tls' setting '%s', use required", c.TLS)
+		}
+		subject := c.Subject
+		if subject == "" {
+			subject = defaultSubject
+		}
+		t, err := template.New("subject").Parse(subject)
+		if err != nil {
+			return nil, fmt.Errorf("'subject': %v", err)
+		}
+		n.notifier = smtpNotifier{server: c.Server, user: c.User, password: c.Password, from: c.From, to: c.To,
+			subject: t, requireTLS: c.TLS == "required"}
+		n.name += " " + c.Server
+	case "exec":
+		if c.Command == "" {
+			return nil, errors.New("'command' not specified")
+		}
+		n.notifier = execNotifier{command: c.Command}
+	case "":
+		return nil, errors.New("'type' not specified")
+	default:
+		return nil, fmt.Errorf("unknown type '%s', use webhook, smtp or exec", c.Type)
+	}
+	return n, nil
+}
+
+// send renders the message and notifies, if the notifier is triggered on the event.
+func (n *notification) send(ctx context.Context, e event) error {
+	if !n.events[e.Type] {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := n.message.Execute(&b, e); err != nil {
+		return err
+	}
+	e.Message = b.String()
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	return n.notify(ctx, e)
+}
+
+// webhookNotifier posts the event as JSON.
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+func (w webhookNotifier) notify(ctx context.Context, e event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("response error (%d): %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// smtpNotifier sends the message as e-mail. The credentials are never sent without TLS.
+type smtpNotifier struct {
+	server     string
+	user       string
+	password   string
+	from       string
+	to         []string
+	subject    *template.Template
+	requireTLS bool
+}
+
+func (s smtpNotifier) notify(ctx context.Context, e event) error {
+
+	var subject bytes.Buffer
+	if err := s.subject.Execute(&subject, e); err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", s.from, strings.Join(s.to, ", "),
+		strings.ReplaceAll(subject.String(), "\n", " "), e.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(e.Message, "\n", "\r\n") + "\r\n")
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.server)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// a canceled context interrupts the conversation
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	host, _, _ := net.SplitHostPort(s.server)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	} else if s.requireTLS || s.user != "" {
+		return errors.New("STARTTLS is not offered by the server, refusing to send in plaintext")
+	}
+	if s.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// execNotifier executes a shell command with the message on stdin, the event is passed in environment variables
+// (DNS_EVENT, DNS_DOMAIN, DNS_RTYPE, DNS_ADDRESS, DNS_PREVIOUS, DNS_FAILURES and DNS_ERROR).
+type execNotifier struct {
+	command string
+}
+
+func (x execNotifier) notify(ctx context.Context, e event) error {
+	cmd := shellCommand(ctx, x.command)
+	cmd.Env = append(os.Environ(),
+		"DNS_EVENT="+e.Type,
+		"DNS_DOMAIN="+e.Domain,
+		"DNS_RTYPE="+e.RType,
+		"DNS_ADDRESS="+e.Address,
+		"DNS_PREVIOUS="+e.Previous,
+		"DNS_FAILURES="+strconv.Itoa(e.Failures),
+		"DNS_ERROR="+e.Error,
+	)
+	cmd.Stdin = strings.NewReader(e.Message)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command '%s' failed: %v", x.command, err)
+	}
+	return nil
+}
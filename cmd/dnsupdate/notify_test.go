@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewNotification(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		config  notifierConfig
+		events  []string
+		wantErr bool
+	}{
+		{"webhook", notifierConfig{Type: "webhook", Url: "https://example.org/hook"}, []string{"change", "failure", "recovery"}, false},
+		{"smtp", notifierConfig{Type: "smtp", Server: "mail.example.org:587", From: "dns@example.org",
+			To: []string{"admin@example.org"}, TLS: "required"}, []string{"change", "failure", "recovery"}, false},
+		{"exec", notifierConfig{Type: "exec", Command: "true", Events: []string{"failure"}}, []string{"failure"}, false},
+		{"no type", notifierConfig{}, nil, true},
+		{"unknown type", notifierConfig{Type: "pager"}, nil, true},
+		{"unknown event", notifierConfig{Type: "exec", Command: "true", Events: []string{"update"}}, nil, true},
+		{"invalid template", notifierConfig{Type: "exec", Command: "true", Template: "{{.Domain"}, nil, true},
+		{"webhook without url", notifierConfig{Type: "webhook"}, nil, true},
+		{"smtp without port", notifierConfig{Type: "smtp", Server: "mail.example.org", From: "dns@example.org",
+			To: []string{"admin@example.org"}}, nil, true},
+		{"smtp without recipient", notifierConfig{Type: "smtp", Server: "mail.example.org:25", From: "dns@example.org"}, nil, true},
+		{"smtp with unknown tls", notifierConfig{Type: "smtp", Server: "mail.example.org:25", From: "dns@example.org",
+			To: []string{"admin@example.org"}, TLS: "optional"}, nil, true},
+		{"smtp with invalid subject", notifierConfig{Type: "smtp", Server: "mail.example.org:25", From: "dns@example.org",
+			To: []string{"admin@example.org"}, Subject: "{{"}, nil, true},
+		{"exec without command", notifierConfig{Type: "exec"}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := newNotification(tc.config)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("error expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			var events []string
+			for _, e := range []string{eventChange, eventFailure, eventRecovery} {
+				if n.events[e] {
+					events = append(events, e)
+				}
+			}
+			if !reflect.DeepEqual(events, tc.events) {
+				t.Errorf("events, want: %v, got: %v", tc.events, events)
+			}
+		})
+	}
+}
+
+func TestNotification_Send(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		template string
+		event    event
+		want     string
+	}{
+		{"change", "", event{Type: eventChange, Domain: "home.example.org", RType: "A", Address: "192.0.2.2",
+			Previous: "192.0.2.1"}, "The address of home.example.org (A) changed from 192.0.2.1 to 192.0.2.2."},
+		{"first change", "", event{Type: eventChange, Domain: "home.example.org", RType: "A", Address: "192.0.2.1"},
+			"The address of home.example.org (A) changed to 192.0.2.1."},
+		{"failure", "", event{Type: eventFailure, Domain: "home.example.org", RType: "AAAA", Failures: 3,
+			Error: "timeout"}, "The update of home.example.org (AAAA) failed 3 times in a row: timeout"},
+		{"recovery", "", event{Type: eventRecovery, Domain: "home.example.org", RType: "A", Failures: 4},
+			"The update of home.example.org (A) succeeded again after 4 failures."},
+		{"template", "{{.Type}}: {{.Domain}}", event{Type: eventChange, Domain: "home.example.org"},
+			"change: home.example.org"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := newNotification(notifierConfig{Type: "exec", Command: "true", Template: tc.template})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			n.notifier = notifierFunc(func(_ context.Context, e event) error {
+				got = e.Message
+				return nil
+			})
+			if err := n.send(context.Background(), tc.event); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// notifierFunc is a function used as notifier.
+type notifierFunc func(ctx context.Context, e event) error
+
+func (f notifierFunc) notify(ctx context.Context, e event) error {
+	return f(ctx, e)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"unauthorized", http.StatusUnauthorized, true},
+		{"server error", http.StatusInternalServerError, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got event
+			var auth string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tc.status)
+			}))
+			defer s.Close()
+
+			e := event{Type: eventChange, Domain: "home.example.org", RType: "A", Address: "192.0.2.1",
+				Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Message: "changed"}
+			w := webhookNotifier{url: s.URL, headers: map[string]string{"Authorization": "Bearer token"}}
+			err := w.notify(context.Background(), e)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, e) {
+				t.Errorf("want: %+v, got: %+v", e, got)
+			}
+			if auth != "Bearer token" {
+				t.Errorf("header, want: Bearer token, got: %s", auth)
+			}
+		})
+	}
+}
+
+func TestExecNotifier(t *testing.T) {
+
+	out := filepath.Join(t.TempDir(), "out.txt")
+	e := event{Type: eventFailure, Domain: "home.example.org", RType: "A", Failures: 3, Error: "timeout",
+		Message: "failed"}
+
+	x := execNotifier{command: `printf '%s %s %s %s %s ' "$DNS_EVENT" "$DNS_DOMAIN" "$DNS_RTYPE" "$DNS_FAILURES" "$DNS_ERROR" > ` +
+		out + ` && cat >> ` + out}
+	if err := x.notify(context.Background(), e); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "failure home.example.org A 3 timeout failed"; string(b) != want {
+		t.Errorf("want: %q, got: %q", want, b)
+	}
+
+	if err := (execNotifier{command: "exit 1"}).notify(context.Background(), e); err == nil {
+		t.Error("error expected")
+	}
+}
+
+// startSmtpServer starts a mail server without STARTTLS and AUTH, returns its address and the commands received.
+func startSmtpServer(t *testing.T) (string, func() []string) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	var mu sync.Mutex
+	var commands []string
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+				reply("220 mail.example.org")
+				data := false
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					if data {
+						if line == "." {
+							data = false
+							reply("250 queued")
+						}
+						continue
+					}
+					mu.Lock()
+					commands = append(commands, strings.Fields(line)[0])
+					mu.Unlock()
+					switch strings.ToUpper(strings.Fields(line)[0]) {
+					case "EHLO":
+						reply("250-mail.example.org")
+						reply("250 8BITMIME")
+					case "DATA":
+						data = true
+						reply("354 go ahead")
+					case "QUIT":
+						reply("221 bye")
+						return
+					case "MAIL", "RCPT", "RSET", "NOOP":
+						reply("250 ok")
+					default:
+						reply("502 not implemented")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		res := commands
+		commands = nil
+		return res
+	}
+}
+
+func TestSmtpNotifier_Plaintext(t *testing.T) {
+
+	server, commands := startSmtpServer(t)
+
+	testCases := []struct {
+		name       string
+		user       string
+		requireTLS bool
+		wantErr    bool
+		commands   []string
+	}{
+		{"plaintext", "", false, false, []string{"EHLO", "MAIL", "RCPT", "DATA", "QUIT"}},
+		{"tls required", "", true, true, []string{"EHLO"}},
+		{"credentials", "dns@example.org", false, true, []string{"EHLO"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := newNotification(notifierConfig{Type: "smtp", Server: server, User: tc.user, Password: "secret",
+				From: "dns@example.org", To: []string{"admin@example.org"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := n.notifier.(smtpNotifier)
+			s.requireTLS = tc.requireTLS
+
+			err = s.notify(context.Background(), event{Type: eventChange, Domain: "home.example.org", Message: "changed"})
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got := commands(); !reflect.DeepEqual(got, tc.commands) {
+				t.Errorf("commands, want: %v, got: %v", tc.commands, got)
+			}
+		})
+	}
+}
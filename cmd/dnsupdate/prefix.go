@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPrefixLength = 64
+	ipv6RouteFile       = "/proc/net/ipv6_route"
+)
+
+// interfaceID returns the interface identifier of the domain, given as address (e.g. ::1:2:3:4) or as MAC address
+// (EUI-64). Returns nil if the domain has none, its AAAA record is set to the address of the source then.
+func (d domainConfig) interfaceID() (net.IP, error) {
+	if d.InterfaceID != "" && d.MAC != "" {
+		return nil, errors.New("'interface_id' and 'mac' are mutually exclusive")
+	}
+	if d.InterfaceID != "" {
+		ip := net.ParseIP(d.InterfaceID)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("'interface_id' %s is not an IPv6 address (e.g. ::1:2:3:4)", d.InterfaceID)
+		}
+		return ip, nil
+	}
+	if d.MAC != "" {
+		return eui64(d.MAC)
+	}
+	return nil, nil
+}
+
+// eui64 returns the modified EUI-64 interface identifier of a 48 bit MAC address (RFC 4291, appendix A).
+func eui64(mac string) (net.IP, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return nil, fmt.Errorf("'mac' %s is not a 48 bit MAC address", mac)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip[8:], []byte{hw[0] ^ 0x02, hw[1], hw[2], 0xff, 0xfe, hw[3], hw[4], hw[5]})
+	return ip, nil
+}
+
+// withPrefix returns the address of the prefix (the first bits of the address) and the interface identifier.
+func withPrefix(prefix, iid net.IP, bits int) net.IP {
+	mask := net.CIDRMask(bits, 8*net.IPv6len)
+	p, id := prefix.To16(), iid.To16()
+	ip := make(net.IP, net.IPv6len)
+	for i := range ip {
+		ip[i] = p[i]&mask[i] | id[i]&^mask[i]
+	}
+	return ip
+}
+
+// routerSource takes the IPv6 prefix announced by the router (router advertisement) from the routing table of the
+// host, optionally limited to an interface. A route of the prefix length is preferred, otherwise the longest prefix
+// shorter than it is taken. Only available on Linux.
+type routerSource struct {
+	iface string
+	bits  int
+}
+
+// rtfReject is the flag of unreachable routes, e.g. of a delegated prefix on the router itself.
+const rtfReject = 0x0200
+
+func (s routerSource) address(_ context.Context, network miab.NetworkType) (net.IP, error) {
+
+	if network != miab.TCP6 {
+		return nil, errors.New("the router source only provides IPv6 prefixes")
+	}
+
+	f, err := os.Open(ipv6RouteFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.prefix(f)
+}
+
+// prefix returns the prefix from the routing table in the format of /proc/net/ipv6_route.
+func (s routerSource) prefix(r io.Reader) (net.IP, error) {
+
+	bits := s.bits
+	if bits == 0 {
+		bits = defaultPrefixLength
+	}
+
+	// format: destination, prefix length, source, source prefix length, next hop, metric, refs, use, flags, interface
+	var best net.IP
+	bestBits := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 10 || fields[9] == "lo" || s.iface != "" && fields[9] != s.iface {
+			continue
+		}
+		if flags, err := strconv.ParseUint(fields[8], 16, 32); err != nil || flags&rtfReject != 0 {
+			continue
+		}
+		dst, err := hex.DecodeString(fields[0])
+		if err != nil || len(dst) != net.IPv6len {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil || n == 0 || int(n) > bits || int(n) <= bestBits {
+			continue
+		}
+		if ip := net.IP(dst); ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			best, bestBits = ip, int(n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if best != nil {
+		return best, nil
+	}
+	if s.iface != "" {
+		return nil, fmt.Errorf("no global IPv6 prefix routed on interface %s", s.iface)
+	}
+	return nil, errors.New("no global IPv6 prefix routed")
+}
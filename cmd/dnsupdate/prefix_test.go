@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testRoutes = `00000000000000000000000000000001 80 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000002 00000000 80200001       lo
+20010db8000100000000000000000000 38 00000000000000000000000000000000 00 00000000000000000000000000000000 00000400 00000001 00000000 00200200       lo
+20010db8000200000000000000000000 30 00000000000000000000000000000000 00 00000000000000000000000000000000 00000400 00000001 00000000 00200200     eth0
+20010db8000300000000000000000000 30 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth1
+20010db8000400000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00040001     eth0
+20010db8000500000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00040001     eth1
+fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000002 00000000 00000001     eth0
+20010db8000600000000000000000000 80 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000002 00000000 80200001     eth0
+`
+
+func TestRouterSource_Prefix(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		source  routerSource
+		routes  string
+		want    string
+		wantErr bool
+	}{
+		{"prefix length", routerSource{}, testRoutes, "2001:db8:4::", false},
+		{"interface", routerSource{iface: "eth1"}, testRoutes, "2001:db8:5::", false},
+		{"shorter prefix", routerSource{bits: 56}, testRoutes, "2001:db8:3::", false},
+		{"shorter prefix on interface", routerSource{iface: "eth1", bits: 56}, testRoutes, "2001:db8:3::", false},
+		{"reject route", routerSource{iface: "eth0", bits: 48}, testRoutes, "", true},
+		{"unknown interface", routerSource{iface: "eth2"}, testRoutes, "", true},
+		{"empty", routerSource{}, "", "", true},
+		{"invalid", routerSource{}, "foo bar\n", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ip, err := tc.source.prefix(strings.NewReader(tc.routes))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error expected, got: %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if ip.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, ip)
+			}
+		})
+	}
+}
+
+func TestInterfaceID(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		domain  domainConfig
+		want    string
+		wantErr bool
+	}{
+		{"none", domainConfig{}, "<nil>", false},
+		{"interface id", domainConfig{InterfaceID: "::1:2:3:4"}, "::1:2:3:4", false},
+		{"mac", domainConfig{MAC: "00:11:22:33:44:55"}, "::211:22ff:fe33:4455", false},
+		{"local mac", domainConfig{MAC: "02-00-5e-10-00-01"}, "::5eff:fe10:1", false},
+		{"ipv4 interface id", domainConfig{InterfaceID: "192.0.2.1"}, "", true},
+		{"invalid interface id", domainConfig{InterfaceID: "host"}, "", true},
+		{"invalid mac", domainConfig{MAC: "00:11:22:33:44"}, "", true},
+		{"eui-64 mac", domainConfig{MAC: "00:11:22:33:44:55:66:77"}, "", true},
+		{"both", domainConfig{InterfaceID: "::1", MAC: "00:11:22:33:44:55"}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ip, err := tc.domain.interfaceID()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error expected, got: %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if ip.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, ip)
+			}
+		})
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		prefix string
+		iid    string
+		bits   int
+		want   string
+	}{
+		{"prefix", "2001:db8:1:2::", "::211:22ff:fe33:4455", 64, "2001:db8:1:2:211:22ff:fe33:4455"},
+		{"address", "2001:db8:1:2:a:b:c:d", "::1", 64, "2001:db8:1:2::1"},
+		{"bits of the identifier", "2001:db8:1:2::", "::ffff:1:2:3:4", 56, "2001:db8:1:ff:1:2:3:4"},
+		{"longer prefix", "2001:db8:1:2:a:b:c:d", "::1", 112, "2001:db8:1:2:a:b:c:1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := withPrefix(net.ParseIP(tc.prefix), net.ParseIP(tc.iid), tc.bits)
+			if got.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// recordConfig describes a record of any type (e.g. TXT or CNAME), whose value is read from a file, printed by a
+// command or rendered from a template of the current addresses.
+type recordConfig struct {
+	Name     string   `json:"name"`
+	Box      string   `json:"box"`
+	RType    string   `json:"rtype"`
+	Interval duration `json:"interval"`
+	File     string   `json:"file"`
+	Command  string   `json:"command"`
+	Template string   `json:"template"`
+	Source   string   `json:"source"` // the source of the addresses of the template, http by default
+
+	client   *miab.Config
+	source   source
+	template *template.Template
+}
+
+// templateData is passed to the template of a record, the addresses are only discovered if used.
+type templateData struct {
+	Name  string
+	RType string
+
+	ctx    context.Context
+	source source
+}
+
+// IPv4 returns the current IPv4 address of the source.
+func (t templateData) IPv4() (string, error) {
+	return t.address(miab.TCP4)
+}
+
+// IPv6 returns the current IPv6 address of the source.
+func (t templateData) IPv6() (string, error) {
+	return t.address(miab.TCP6)
+}
+
+func (t templateData) address(network miab.NetworkType) (string, error) {
+	ip, err := t.source.address(t.ctx, network)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// validate checks the record, it gets the client of its box, the source and the parsed template.
+func (r *recordConfig) validate(boxes map[string]*miab.Config, sources map[string]source, configured map[string]sourceConfig) []error {
+	var errs []error
+	name := fmt.Sprintf("record '%s' (%s)", r.Name, r.RType)
+
+	rtype, err := miab.ParseDnsResource(r.RType)
+	if err != nil || rtype == miab.NONE {
+		errs = append(errs, fmt.Errorf("%s: unsupported record type '%s'", name, r.RType))
+	} else if qname, err := miab.ToASCII(r.Name); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", name, err))
+	} else if err := miab.ValidateQName(qname, rtype); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", name, err))
+	}
+
+	if r.Box == "" {
+		errs = append(errs, fmt.Errorf("%s: 'box' not specified, there is more than one box", name))
+	} else if client, ok := boxes[r.Box]; !ok {
+		errs = append(errs, fmt.Errorf("%s: unknown box '%s'", name, r.Box))
+	} else {
+		r.client = client
+	}
+
+	n := 0
+	for _, s := range []string{r.File, r.Command, r.Template} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		errs = append(errs, fmt.Errorf("%s: exactly one of 'file', 'command' and 'template' has to be specified", name))
+	}
+
+	if r.Template != "" {
+		if r.template, err = template.New("value").Parse(r.Template); err != nil {
+			errs = append(errs, fmt.Errorf("%s: 'template': %v", name, err))
+		}
+		if s, ok := sources[r.Source]; ok {
+			r.source = s
+		} else if _, ok := configured[r.Source]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown source '%s'", name, r.Source))
+		}
+	} else if r.Source != "" {
+		errs = append(errs, fmt.Errorf("%s: 'source' is only used by a template", name))
+	}
+	return errs
+}
+
+// value returns the value of the record from its file, command or template, leading and trailing white space is
+// removed.
+func (r recordConfig) value(ctx context.Context) (string, error) {
+
+	var value string
+	switch {
+	case r.File != "":
+		b, err := ioutil.ReadFile(r.File)
+		if err != nil {
+			return "", err
+		}
+		value = string(b)
+	case r.Command != "":
+		ctx, cancel := context.WithTimeout(ctx, sourceTimeout)
+		defer cancel()
+
+		cmd := shellCommand(ctx, r.Command)
+		cmd.Env = append(os.Environ(), "DNS_NAME="+r.Name, "DNS_RTYPE="+r.RType)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("command '%s' failed: %v", r.Command, err)
+		}
+		value = string(out)
+	default:
+		var b bytes.Buffer
+		if err := r.template.Execute(&b, templateData{Name: r.Name, RType: r.RType, ctx: ctx, source: r.source}); err != nil {
+			return "", err
+		}
+		value = b.String()
+	}
+
+	if value = strings.TrimSpace(value); value == "" {
+		return "", errors.New("empty value")
+	}
+	return value, nil
+}
+
+// syncRecord sets the record to its current value, if the value on the box differs. Returns false if the value is
+// not available or the update failed.
+func (u *updater) syncRecord(ctx context.Context, r recordConfig) bool {
+
+	previous, value, err := r.sync(ctx)
+	if ctx.Err() != nil {
+		return false
+	}
+	u.metrics.result(r.Name, r.RType, value, err)
+
+	e := event{Domain: r.Name, RType: r.RType, Address: value, Previous: previous, Time: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	u.checkEvents(e)
+	return err == nil
+}
+
+// sync compares the value with the records on the box and sets it via SetDns if it differs, returns the previous
+// value (if a single record was set) and the current value.
+func (r recordConfig) sync(ctx context.Context) (string, string, error) {
+
+	rtype := miab.ResourceType(r.RType)
+	value, err := r.value(ctx)
+	if err != nil {
+		fmt.Printf("Value of the record (%s) of '%s' not available: %v\n", r.RType, r.Name, err)
+		return "", "", err
+	}
+
+	records, err := miab.GetDnsContext(ctx, r.client, r.Name, rtype)
+	if err != nil {
+		fmt.Printf("Unable to get the record (%s) of '%s': %v\n", r.RType, r.Name, err)
+		return "", "", err
+	}
+	previous := ""
+	if len(records) == 1 {
+		if equalValue(rtype, records[0].Value, value) {
+			return value, value, nil
+		}
+		previous = records[0].Value
+	}
+
+	b, err := miab.SetDnsContext(ctx, r.client, r.Name, rtype, value)
+	if err != nil {
+		fmt.Printf("DNS update (%s) for '%s' failed with error: %v\n", r.RType, r.Name, err)
+		return previous, "", err
+	}
+	if !b {
+		fmt.Printf("DNS update (%s) for '%s' failed\n", r.RType, r.Name)
+		return previous, "", errors.New("update failed")
+	}
+	fmt.Printf("DNS update (%s) for '%s' to %s successful\n", r.RType, r.Name, value)
+	return previous, value, nil
+}
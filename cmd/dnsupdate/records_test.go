@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/rverst/go-miab/miab"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testSource returns the addresses per network, an error if there is none.
+type testSource map[miab.NetworkType]string
+
+func (s testSource) address(_ context.Context, network miab.NetworkType) (net.IP, error) {
+	if a, ok := s[network]; ok {
+		return net.ParseIP(a), nil
+	}
+	return nil, errors.New("no address")
+}
+
+func TestRecordConfig_Sync(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "value.txt")
+	if err := ioutil.WriteFile(file, []byte("  v=spf1 mx -all\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	addresses := testSource{miab.TCP4: "192.0.2.1", miab.TCP6: "2001:db8::1"}
+
+	testCases := []struct {
+		name     string
+		record   recordConfig
+		records  miab.Records
+		fail     bool
+		previous string
+		value    string
+		wantErr  bool
+		changes  []string
+	}{
+		{"file", recordConfig{RType: "TXT", File: file}, nil, false,
+			"", "v=spf1 mx -all", false, []string{"PUT example.org TXT v=spf1 mx -all"}},
+		{"unchanged", recordConfig{RType: "TXT", File: file},
+			miab.Records{{QName: "example.org", RType: miab.TXT, Value: "v=spf1 mx -all"}}, false,
+			"v=spf1 mx -all", "v=spf1 mx -all", false, nil},
+		{"changed", recordConfig{RType: "TXT", File: file},
+			miab.Records{{QName: "example.org", RType: miab.TXT, Value: "v=spf1 -all"}}, false,
+			"v=spf1 -all", "v=spf1 mx -all", false, []string{"PUT example.org TXT v=spf1 mx -all"}},
+		{"replaces several records", recordConfig{RType: "TXT", File: file},
+			miab.Records{
+				{QName: "example.org", RType: miab.TXT, Value: "v=spf1 mx -all"},
+				{QName: "example.org", RType: miab.TXT, Value: "v=spf1 -all"},
+			}, false,
+			"", "v=spf1 mx -all", false, []string{"PUT example.org TXT v=spf1 mx -all"}},
+		{"command", recordConfig{RType: "CNAME", Command: `echo "www.$DNS_NAME"`}, nil, false,
+			"", "www.example.org", false, []string{"PUT example.org CNAME www.example.org."}},
+		{"same value in another form", recordConfig{RType: "CNAME", Command: "echo WWW.example.org."},
+			miab.Records{{QName: "example.org", RType: miab.CNAME, Value: "www.example.org."}}, false,
+			"WWW.example.org.", "WWW.example.org.", false, nil},
+		{"template", recordConfig{RType: "TXT", Template: "v=spf1 ip4:{{.IPv4}} ip6:{{.IPv6}} -all", source: addresses}, nil, false,
+			"", "v=spf1 ip4:192.0.2.1 ip6:2001:db8::1 -all", false,
+			[]string{"PUT example.org TXT v=spf1 ip4:192.0.2.1 ip6:2001:db8::1 -all"}},
+		{"template without address", recordConfig{RType: "TXT", Template: "{{.IPv4}}", source: testSource{}}, nil, false,
+			"", "", true, nil},
+		{"missing file", recordConfig{RType: "TXT", File: file + ".missing"}, nil, false, "", "", true, nil},
+		{"failed command", recordConfig{RType: "TXT", Command: "exit 1"}, nil, false, "", "", true, nil},
+		{"empty value", recordConfig{RType: "TXT", Command: "echo"}, nil, false, "", "", true, nil},
+		{"failed update", recordConfig{RType: "TXT", File: file}, nil, true, "", "", true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := newTestBox(t, tc.records...)
+			box.fail = tc.fail
+			r := tc.record
+			r.Name, r.Box = "example.org", "box"
+			if r.Template != "" {
+				r.Source = "test"
+			}
+			errs := r.validate(map[string]*miab.Config{"box": box.client}, map[string]source{"test": tc.record.source}, nil)
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+
+			previous, value, err := r.sync(context.Background())
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if previous != tc.previous || value != tc.value {
+				t.Errorf("want: %q, %q, got: %q, %q", tc.previous, tc.value, previous, value)
+			}
+			if got := box.changes(); !reflect.DeepEqual(got, tc.changes) {
+				t.Errorf("changes, want: %v, got: %v", tc.changes, got)
+			}
+		})
+	}
+}
+
+func TestRecordConfig_Validate(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		record recordConfig
+		want   int
+	}{
+		{"file", recordConfig{Name: "example.org", Box: "box", RType: "TXT", File: "value.txt"}, 0},
+		{"template", recordConfig{Name: "example.org", Box: "box", RType: "TXT", Template: "{{.IPv4}}", Source: "http"}, 0},
+		{"configured source", recordConfig{Name: "example.org", Box: "box", RType: "TXT", Template: "{{.IPv4}}", Source: "router"}, 0},
+		{"no value", recordConfig{Name: "example.org", Box: "box", RType: "TXT"}, 1},
+		{"file and command", recordConfig{Name: "example.org", Box: "box", RType: "TXT", File: "value.txt", Command: "true"}, 1},
+		{"invalid template", recordConfig{Name: "example.org", Box: "box", RType: "TXT", Template: "{{.IPv4", Source: "http"}, 1},
+		{"unknown source", recordConfig{Name: "example.org", Box: "box", RType: "TXT", Template: "{{.IPv4}}", Source: "stun6"}, 1},
+		{"source without template", recordConfig{Name: "example.org", Box: "box", RType: "TXT", File: "value.txt", Source: "http"}, 1},
+		{"unknown type", recordConfig{Name: "example.org", Box: "box", RType: "FOO", File: "value.txt"}, 1},
+		{"invalid name", recordConfig{Name: "example..org", Box: "box", RType: "TXT", File: "value.txt"}, 1},
+		{"unknown box", recordConfig{Name: "example.org", Box: "other", RType: "TXT", File: "value.txt"}, 1},
+		{"no box", recordConfig{Name: "example.org", RType: "TXT", File: "value.txt"}, 1},
+	}
+
+	boxes := map[string]*miab.Config{"box": nil}
+	sources := map[string]source{"http": newHttpSource(defaultEchoUrls)}
+	configured := map[string]sourceConfig{"router": {Type: "router"}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := tc.record
+			if errs := r.validate(boxes, sources, configured); len(errs) != tc.want {
+				t.Errorf("want %d errors, got: %v", tc.want, errs)
+			}
+		})
+	}
+}
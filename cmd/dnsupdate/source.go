@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const sourceTimeout = 10 * time.Second
+
+var (
+	defaultEchoUrls   = []string{"https://api64.ipify.org", "https://icanhazip.com"}
+	defaultStunServer = "stun.l.google.com:19302"
+)
+
+// source discovers the public address of the host.
+type source interface {
+	// address returns the IPv4 (tcp4) or IPv6 (tcp6) address of the host, the discovery is aborted if the
+	// context is canceled.
+	address(ctx context.Context, network miab.NetworkType) (net.IP, error)
+}
+
+// sourceConfig describes a source of the address, the settings used depend on the type.
+type sourceConfig struct {
+	Type         string   `json:"type"`          // http, stun, interface, router or command
+	Urls         []string `json:"urls"`          // http: echo services, tried in order
+	Server       string   `json:"server"`        // stun: host:port of the STUN server
+	Interface    string   `json:"interface"`     // interface, router: name of the network interface
+	PrefixLength int      `json:"prefix_length"` // router: length of the prefix preferred, 64 by default
+	Command      string   `json:"command"`       // command: executed by sh, prints the address
+}
+
+// newSource creates the source of the config.
+func newSource(c sourceConfig) (source, error) {
+	switch c.Type {
+	case "http":
+		urls := c.Urls
+		if len(urls) == 0 {
+			urls = defaultEchoUrls
+		}
+		return newHttpSource(urls), nil
+	case "stun":
+		server := c.Server
+		if server == "" {
+			server = defaultStunServer
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("'server' has to be host:port: %v", err)
+		}
+		return stunSource{server: server}, nil
+	case "interface":
+		if c.Interface == "" {
+			return nil, errors.New("'interface' not specified")
+		}
+		return interfaceSource{name: c.Interface}, nil
+	case "router":
+		if c.PrefixLength < 0 || c.PrefixLength > 128 {
+			return nil, errors.New("'prefix_length' has to be between 1 and 128")
+		}
+		return routerSource{iface: c.Interface, bits: c.PrefixLength}, nil
+	case "command":
+		if c.Command == "" {
+			return nil, errors.New("'command' not specified")
+		}
+		return commandSource{command: c.Command}, nil
+	case "":
+		return nil, errors.New("'type' not specified")
+	}
+	return nil, fmt.Errorf("unknown type '%s', use http, stun, interface, router or command", c.Type)
+}
+
+// checkAddress checks that the address is of the IP version of the network.
+func checkAddress(ip net.IP, network miab.NetworkType) (net.IP, error) {
+	if ip == nil {
+		return nil, errors.New("no valid IP address")
+	}
+	if network == miab.TCP4 {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		return nil, fmt.Errorf("%s is not an IPv4 address", ip)
+	}
+	if ip.To4() == nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("%s is not an IPv6 address", ip)
+}
+
+// httpSource queries echo services, that respond with the address of the remote host as plain text.
+type httpSource struct {
+	urls    []string
+	clients map[miab.NetworkType]*http.Client
+}
+
+// newHttpSource creates the source with a client per network, that connects via IPv4 (tcp4) or IPv6 (tcp6).
+func newHttpSource(urls []string) httpSource {
+	s := httpSource{urls: urls, clients: map[miab.NetworkType]*http.Client{}}
+	for _, network := range []miab.NetworkType{miab.TCP4, miab.TCP6} {
+		network := network
+		dialer := &net.Dialer{Timeout: sourceTimeout}
+		s.clients[network] = &http.Client{
+			Timeout: sourceTimeout,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+					return dialer.DialContext(ctx, string(network), addr)
+				},
+				TLSHandshakeTimeout: sourceTimeout,
+				// a connection kept alive would report the address it was opened with, e.g. a previous
+				// temporary IPv6 address
+				DisableKeepAlives: true,
+			},
+		}
+	}
+	return s
+}
+
+func (s httpSource) address(ctx context.Context, network miab.NetworkType) (net.IP, error) {
+
+	client, ok := s.clients[network]
+	if !ok {
+		return nil, fmt.Errorf("unknown network '%s'", network)
+	}
+
+	var errs []string
+	for _, u := range s.urls {
+		ip, err := s.query(ctx, client, u, network)
+		if err == nil {
+			return ip, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", u, err))
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+func (s httpSource) query(ctx context.Context, client *http.Client, url string, network miab.NetworkType) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("response error (%d)", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 256))
+	if err != nil {
+		return nil, err
+	}
+	return checkAddress(net.ParseIP(strings.TrimSpace(string(body))), network)
+}
+
+// stunSource queries the mapped address of the host from a STUN server (RFC 5389 binding request).
+type stunSource struct {
+	server string
+}
+
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunMappedAddress   = 0x0001
+	stunXorMapped       = 0x0020
+)
+
+func (s stunSource) address(ctx context.Context, network miab.NetworkType) (net.IP, error) {
+
+	udp := "udp4"
+	if network == miab.TCP6 {
+		udp = "udp6"
+	}
+	dialer := &net.Dialer{Timeout: sourceTimeout}
+	conn, err := dialer.DialContext(ctx, udp, s.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// a canceled context interrupts the read
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return nil, err
+	}
+
+	// UDP is unreliable, the request is retransmitted a few times
+	buf := make([]byte, 1024)
+	for i := 0; i < 3; i++ {
+		if _, err = conn.Write(req); err != nil {
+			return nil, err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(sourceTimeout / 3))
+		var n int
+		if n, err = conn.Read(buf); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if n < 20 || binary.BigEndian.Uint16(buf[0:]) != stunBindingResponse || !bytes.Equal(buf[8:20], req[8:20]) {
+			err = errors.New("unexpected response of the STUN server")
+			continue
+		}
+		ip, err := parseStunResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		return checkAddress(ip, network)
+	}
+	return nil, fmt.Errorf("STUN server %s: %v", s.server, err)
+}
+
+// parseStunResponse returns the (XOR-)MAPPED-ADDRESS of a binding response.
+func parseStunResponse(msg []byte) (net.IP, error) {
+	length := int(binary.BigEndian.Uint16(msg[2:]))
+	if 20+length > len(msg) {
+		return nil, errors.New("truncated response of the STUN server")
+	}
+
+	var mapped net.IP
+	attrs := msg[20 : 20+length]
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:])
+		l := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+l > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+l]
+		if (typ == stunXorMapped || typ == stunMappedAddress) && l >= 8 {
+			size := net.IPv4len
+			if value[1] == 0x02 {
+				size = net.IPv6len
+			}
+			if 4+size <= l {
+				ip := make(net.IP, size)
+				copy(ip, value[4:4+size])
+				if typ == stunXorMapped {
+					// the address is XORed with the magic cookie and the transaction id
+					for i := range ip {
+						ip[i] ^= msg[4+i]
+					}
+					return ip, nil
+				}
+				mapped = ip
+			}
+		}
+		// attributes are padded to a multiple of 4 bytes
+		next := 4 + (l+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if mapped != nil {
+		return mapped, nil
+	}
+	return nil, errors.New("no mapped address in the response of the STUN server")
+}
+
+// interfaceSource takes the address of a local network interface, public addresses are preferred.
+type interfaceSource struct {
+	name string
+}
+
+func (s interfaceSource) address(_ context.Context, network miab.NetworkType) (net.IP, error) {
+
+	iface, err := net.InterfaceByName(s.name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var private net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		ip, err := checkAddress(ipNet.IP, network)
+		if err != nil {
+			continue
+		}
+		if !ip.IsPrivate() {
+			return ip, nil
+		}
+		if private == nil {
+			private = ip
+		}
+	}
+	if private != nil {
+		return private, nil
+	}
+	return nil, fmt.Errorf("interface %s has no global %s address", s.name, ipVersion(network))
+}
+
+// commandSource executes a shell command, that prints the address. The record type (A or AAAA) is passed in
+// the environment variable DNS_RTYPE.
+type commandSource struct {
+	command string
+}
+
+func (s commandSource) address(ctx context.Context, network miab.NetworkType) (net.IP, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, sourceTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, s.command)
+	cmd.Env = append(os.Environ(), "DNS_RTYPE="+string(rtypeOf(network)))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command '%s' failed: %v", s.command, err)
+	}
+	return checkAddress(net.ParseIP(strings.TrimSpace(string(out))), network)
+}
+
+// shellCommand returns the command executed by sh, which is killed when the context is done. The output is not
+// waited for longer than a second after that, as processes started by the command may still hold it open.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+func rtypeOf(network miab.NetworkType) miab.ResourceType {
+	if network == miab.TCP6 {
+		return miab.AAAA
+	}
+	return miab.A
+}
+
+func networkOf(rtype miab.ResourceType) miab.NetworkType {
+	if rtype == miab.AAAA {
+		return miab.TCP6
+	}
+	return miab.TCP4
+}
+
+func ipVersion(network miab.NetworkType) string {
+	if network == miab.TCP6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+var testTransaction = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+// stunMessage returns a binding response with the attributes.
+func stunMessage(attrs ...[]byte) []byte {
+	msg := make([]byte, 20)
+	binary.BigEndian.PutUint16(msg[0:], stunBindingResponse)
+	binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+	copy(msg[8:], testTransaction)
+	for _, a := range attrs {
+		msg = append(msg, a...)
+	}
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-20))
+	return msg
+}
+
+// stunAttribute returns the attribute padded to a multiple of 4 bytes.
+func stunAttribute(typ uint16, value []byte) []byte {
+	a := make([]byte, 4, 4+len(value)+3)
+	binary.BigEndian.PutUint16(a[0:], typ)
+	binary.BigEndian.PutUint16(a[2:], uint16(len(value)))
+	a = append(a, value...)
+	for len(a)%4 != 0 {
+		a = append(a, 0)
+	}
+	return a
+}
+
+// stunAddress returns the value of a (XOR-)MAPPED-ADDRESS attribute.
+func stunAddress(ip string, xor bool) []byte {
+	addr := net.ParseIP(ip)
+	family := byte(0x02)
+	if v4 := addr.To4(); v4 != nil {
+		addr, family = v4, 0x01
+	}
+	value := append([]byte{0, family, 0x12, 0x34}, addr...)
+	if xor {
+		key := append([]byte{0x21, 0x12, 0xa4, 0x42}, testTransaction...)
+		for i := range addr {
+			value[4+i] ^= key[i]
+		}
+	}
+	return value
+}
+
+func TestParseStunResponse(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		msg     []byte
+		want    string
+		wantErr bool
+	}{
+		{"xor mapped ipv4", stunMessage(stunAttribute(stunXorMapped, stunAddress("192.0.2.1", true))), "192.0.2.1", false},
+		{"xor mapped ipv6", stunMessage(stunAttribute(stunXorMapped, stunAddress("2001:db8::1", true))), "2001:db8::1", false},
+		{"mapped", stunMessage(stunAttribute(stunMappedAddress, stunAddress("192.0.2.2", false))), "192.0.2.2", false},
+		{"xor mapped preferred", stunMessage(
+			stunAttribute(stunMappedAddress, stunAddress("192.0.2.2", false)),
+			stunAttribute(stunXorMapped, stunAddress("192.0.2.1", true))), "192.0.2.1", false},
+		{"padded attribute", stunMessage(
+			stunAttribute(0x8022, []byte("miab!")),
+			stunAttribute(stunXorMapped, stunAddress("192.0.2.1", true))), "192.0.2.1", false},
+		{"short address", stunMessage(stunAttribute(stunXorMapped, []byte{0, 1, 0, 0})), "", true},
+		{"truncated ipv6", stunMessage(stunAttribute(stunXorMapped, stunAddress("2001:db8::1", true)[:12])), "", true},
+		{"no address", stunMessage(stunAttribute(0x8022, []byte("miab"))), "", true},
+		{"truncated", stunMessage(stunAttribute(stunXorMapped, stunAddress("192.0.2.1", true)))[:24], "", true},
+		{"empty", stunMessage(), "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ip, err := parseStunResponse(tc.msg)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error expected, got: %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if ip.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, ip)
+			}
+		})
+	}
+}
+
+func TestHttpSource_Address(t *testing.T) {
+
+	var conns int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/text":
+			_, _ = w.Write([]byte("unknown\n"))
+		default:
+			host, _, _ := net.SplitHostPort(r.RemoteAddr)
+			_, _ = w.Write([]byte(host + "\n"))
+		}
+	}))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	s.Start()
+	defer s.Close()
+
+	testCases := []struct {
+		name    string
+		urls    []string
+		network miab.NetworkType
+		want    string
+		wantErr bool
+	}{
+		{"ipv4", []string{s.URL}, miab.TCP4, "127.0.0.1", false},
+		{"next url", []string{s.URL + "/error", s.URL + "/text", s.URL}, miab.TCP4, "127.0.0.1", false},
+		{"failed", []string{s.URL + "/error", s.URL + "/text"}, miab.TCP4, "", true},
+		{"ipv6 to ipv4 only", []string{s.URL}, miab.TCP6, "", true},
+		{"unknown network", []string{s.URL}, "udp", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ip, err := newHttpSource(tc.urls).address(context.Background(), tc.network)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("error expected, got: %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if ip.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, ip)
+			}
+		})
+	}
+
+	// connections are not kept alive, so a changed address of the host is reported
+	atomic.StoreInt32(&conns, 0)
+	h := newHttpSource([]string{s.URL})
+	for i := 0; i < 2; i++ {
+		if _, err := h.address(context.Background(), miab.TCP4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Errorf("want 2 connections, got: %d", n)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdNotify sends a state (e.g. READY=1) to the service manager, if started by systemd with Type=notify
+// (NOTIFY_SOCKET is set). See sd_notify(3).
+func sdNotify(state string) error {
+
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// a leading @ denotes a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns the interval to notify the watchdog of systemd (half of WatchdogSec), zero if the
+// watchdog is not enabled for the process. See sd_watchdog_enabled(3).
+func sdWatchdogInterval() time.Duration {
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// sdWatchdog notifies the watchdog of systemd until the context is canceled. The watchdog is only notified while
+// the updates make progress (see metrics.hung), so systemd restarts the service if an update hangs.
+func sdWatchdog(ctx context.Context, m *metrics) {
+
+	interval := sdWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if hung := m.hung(); len(hung) > 0 {
+				fmt.Printf("Watchdog not notified, no progress: %s\n", strings.Join(hung, ", "))
+				continue
+			}
+			_ = sdNotify("WATCHDOG=1")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenNotify listens on a notify socket set as NOTIFY_SOCKET, returns the function reading the next state.
+func listenNotify(t *testing.T) func(timeout time.Duration) (string, error) {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	return func(timeout time.Duration) (string, error) {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		b := make([]byte, 256)
+		n, err := conn.Read(b)
+		return string(b[:n]), err
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		socket  func(t *testing.T) func(time.Duration) (string, error)
+		wantErr bool
+	}{
+		{"socket", listenNotify, false},
+		{"abstract socket", func(t *testing.T) func(time.Duration) (string, error) {
+			name := fmt.Sprintf("dnsupdate-test-%d-%d", os.Getpid(), time.Now().UnixNano())
+			conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: "\x00" + name, Net: "unixgram"})
+			if err != nil {
+				t.Skipf("abstract sockets not supported: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+			t.Setenv("NOTIFY_SOCKET", "@"+name)
+			return func(timeout time.Duration) (string, error) {
+				_ = conn.SetReadDeadline(time.Now().Add(timeout))
+				b := make([]byte, 256)
+				n, err := conn.Read(b)
+				return string(b[:n]), err
+			}
+		}, false},
+		{"not started by systemd", func(t *testing.T) func(time.Duration) (string, error) {
+			t.Setenv("NOTIFY_SOCKET", "")
+			return nil
+		}, false},
+		{"missing socket", func(t *testing.T) func(time.Duration) (string, error) {
+			t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing"))
+			return nil
+		}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			read := tc.socket(t)
+			err := sdNotify("READY=1")
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if read == nil {
+				return
+			}
+			if state, err := read(time.Second); err != nil || state != "READY=1" {
+				t.Errorf("want: READY=1, got: %q, %v", state, err)
+			}
+		})
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+
+	pid := strconv.Itoa(os.Getpid())
+
+	testCases := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{"enabled", "30000000", "", 15 * time.Second},
+		{"enabled for the process", "30000000", pid, 15 * time.Second},
+		{"enabled for another process", "30000000", pid + "0", 0},
+		{"not enabled", "", "", 0},
+		{"invalid", "30s", "", 0},
+		{"zero", "0", "", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tc.usec)
+			t.Setenv("WATCHDOG_PID", tc.pid)
+			if got := sdWatchdogInterval(); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSdWatchdog(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		metrics func() *metrics
+		want    bool
+	}{
+		{"updated", func() *metrics {
+			m := newMetrics()
+			m.register("home.example.org", "A", time.Minute)
+			m.result("home.example.org", "A", "192.0.2.1", nil)
+			return m
+		}, true},
+		{"failed", func() *metrics {
+			m := newMetrics()
+			m.register("home.example.org", "A", time.Minute)
+			m.result("home.example.org", "A", "", errors.New("timeout"))
+			return m
+		}, true},
+		{"not attempted yet", func() *metrics {
+			m := newMetrics()
+			m.register("home.example.org", "A", time.Minute)
+			return m
+		}, true},
+		{"hung", func() *metrics {
+			m := newMetrics()
+			m.started = time.Now().Add(-time.Hour)
+			m.register("home.example.org", "A", time.Minute)
+			m.register("www.example.org", "A", time.Minute)
+			m.result("www.example.org", "A", "192.0.2.1", nil)
+			return m
+		}, false},
+		{"pushed", func() *metrics {
+			m := newMetrics()
+			m.started = time.Now().Add(-time.Hour)
+			m.push("home.example.org", "A", "192.0.2.1", nil)
+			return m
+		}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			read := listenNotify(t)
+			t.Setenv("WATCHDOG_USEC", "20000")
+			t.Setenv("WATCHDOG_PID", "")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				sdWatchdog(ctx, tc.metrics())
+				close(done)
+			}()
+			state, err := read(200 * time.Millisecond)
+			cancel()
+			<-done
+
+			if tc.want && (err != nil || state != "WATCHDOG=1") {
+				t.Errorf("want: WATCHDOG=1, got: %q, %v", state, err)
+			} else if !tc.want && err == nil {
+				t.Errorf("want no notification, got: %q", state)
+			}
+		})
+	}
+}
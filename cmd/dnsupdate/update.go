@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordKey identifies the record of a domain in the cache.
+func recordKey(d domainConfig, rtype string) string {
+	return d.Box + "/" + strings.ToLower(d.Name) + "/" + rtype
+}
+
+// verifyRecords fetches the records of the domains with a source from the boxes, so the cache reflects the
+// values actually set (e.g. after a restart or a change by hand).
+func verifyRecords(ctx context.Context, domains []domainConfig, c *cache) {
+
+	for _, d := range domains {
+		if d.source == nil {
+			continue
+		}
+		for _, rtype := range d.RTypes {
+			// only a single record can be updated in place, otherwise the cache is cleared to set the record
+			value, err := boxAddress(ctx, d, rtype)
+			if err != nil {
+				fmt.Printf("Unable to verify the record (%s) of '%s': %v\n", rtype, d.Name, err)
+				continue
+			}
+			key := recordKey(d, rtype)
+			if cached, ok := c.get(key); ok && cached != value {
+				fmt.Printf("Record (%s) of '%s' on the box differs from the cache (%s)\n", rtype, d.Name, cached)
+			}
+			if err := c.set(key, value); err != nil {
+				fmt.Printf("Unable to write the cache: %v\n", err)
+			}
+		}
+	}
+}
+
+// boxAddress returns the address of the record of the domain on the box, empty if there is not exactly one record.
+func boxAddress(ctx context.Context, d domainConfig, rtype string) (string, error) {
+	records, err := miab.GetDnsContext(ctx, d.client, d.Name, miab.ResourceType(rtype))
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 1 {
+		if ip := net.ParseIP(records[0].Value); ip != nil {
+			return ip.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// updater updates the records of the domains and keeps track of the results.
+type updater struct {
+	cache   *cache
+	metrics *metrics
+
+	notifications []*notification
+	threshold     int
+	events        chan event     // the queue of the notifications, they are sent synchronously if nil
+	sent          sync.WaitGroup // done when the queue is closed and empty
+	cancelNotify  context.CancelFunc
+
+	mu       sync.Mutex
+	failures map[record]int
+}
+
+// doDnsUpdate updates the address records of the domain. If the domain has a source, the address is discovered
+// locally and the record only updated if it has changed, otherwise the box takes the address of the remote host.
+// With an interface identifier the address is built from the prefix of the discovered address.
+// Returns false if an update failed.
+func (u *updater) doDnsUpdate(ctx context.Context, d domainConfig) bool {
+
+	ok := true
+	for _, rtype := range d.RTypes {
+		previous, _ := u.cache.get(recordKey(d, rtype))
+		value, err := u.updateRecord(ctx, d, rtype)
+		if ctx.Err() != nil {
+			return false
+		}
+		u.metrics.result(d.Name, rtype, value, err)
+
+		e := event{Domain: d.Name, RType: rtype, Address: value, Previous: previous, Time: time.Now()}
+		if err != nil {
+			e.Error = err.Error()
+			ok = false
+		}
+		u.checkEvents(e)
+	}
+	return ok
+}
+
+// run calls update at the interval until the context is canceled, an update in progress is aborted then (requests
+// to the box are finished, they are bounded by the timeout of the client).
+func run(ctx context.Context, interval time.Duration, update func(ctx context.Context) bool) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		update(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkEvents notifies on a change of the address, on failure after threshold consecutive failures and on recovery.
+func (u *updater) checkEvents(e event) {
+
+	r := record{e.Domain, e.RType}
+	u.mu.Lock()
+	failures := u.failures[r]
+	if e.Error != "" {
+		u.failures[r] = failures + 1
+	} else {
+		delete(u.failures, r)
+	}
+	u.mu.Unlock()
+
+	var events []event
+	if e.Error != "" && failures+1 == u.threshold {
+		f := e
+		f.Type, f.Failures = eventFailure, failures+1
+		events = append(events, f)
+	}
+	if e.Error == "" && failures >= u.threshold {
+		r := e
+		r.Type, r.Failures = eventRecovery, failures
+		events = append(events, r)
+	}
+	if e.Error == "" && e.Address != "" && e.Address != e.Previous {
+		c := e
+		c.Type = eventChange
+		events = append(events, c)
+	}
+
+	for _, e := range events {
+		if u.events == nil {
+			u.notify(context.Background(), e)
+			continue
+		}
+		select {
+		case u.events <- e:
+		default:
+			fmt.Printf("Notification of the %s of '%s' dropped, too many pending notifications\n", e.Type, e.Domain)
+		}
+	}
+}
+
+// startNotifications sends the notifications in the background, so slow notifiers don't delay the updates.
+func (u *updater) startNotifications() {
+	var ctx context.Context
+	ctx, u.cancelNotify = context.WithCancel(context.Background())
+	u.events = make(chan event, notifyQueue)
+	u.sent.Add(1)
+	go func() {
+		defer u.sent.Done()
+		for e := range u.events {
+			u.notify(ctx, e)
+		}
+	}()
+}
+
+// stopNotifications sends the pending notifications. If the context is canceled before, the notifications in
+// progress are aborted and the pending ones dropped, returns false then.
+func (u *updater) stopNotifications(ctx context.Context) bool {
+	close(u.events)
+	done := make(chan struct{})
+	go func() {
+		u.sent.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		u.cancelNotify()
+		return true
+	case <-ctx.Done():
+		u.cancelNotify()
+		<-done
+		return false
+	}
+}
+
+// notify sends the event to all notifiers, unless the context is canceled.
+func (u *updater) notify(ctx context.Context, e event) {
+	for _, n := range u.notifications {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := n.send(ctx, e); err != nil {
+			fmt.Printf("Notification (%s) of the %s of '%s' failed: %v\n", n.name, e.Type, e.Domain, err)
+		}
+	}
+}
+
+// updateRecord updates a record of the domain, returns the address if known.
+func (u *updater) updateRecord(ctx context.Context, d domainConfig, rtype string) (string, error) {
+
+	network := networkOf(miab.ResourceType(rtype))
+
+	value := ""
+	if d.source != nil {
+		ip, err := d.source.address(ctx, network)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			fmt.Printf("Address discovery (%s) for '%s' failed with error: %v\n", rtype, d.Name, err)
+			return "", err
+		}
+		if d.iid != nil {
+			ip = withPrefix(ip, d.iid, d.PrefixLength)
+		}
+		value = ip.String()
+		if cached, ok := u.cache.get(recordKey(d, rtype)); ok && cached == value {
+			return value, nil
+		}
+	}
+
+	// an address given is set via any network, only the box needs to connect via the IP version of the record
+	var b bool
+	var err error
+	if value == "" {
+		b, err = miab.SetOrAddAddressRecordContext(ctx, d.client, network, d.Name, value, false)
+	} else {
+		b, err = miab.SetDnsContext(ctx, d.client, d.Name, miab.ResourceType(rtype), value)
+	}
+	if err != nil {
+		fmt.Printf("DNS update (%s) for '%s' failed with error: %v\n", rtype, d.Name, err)
+		return "", err
+	}
+	if !b {
+		fmt.Printf("DNS update (%s) for '%s' failed\n", rtype, d.Name)
+		return "", errors.New("update failed")
+	}
+
+	if value == "" {
+		fmt.Printf("DNS update (%s) for '%s' successful\n", rtype, d.Name)
+		return "", nil
+	}
+	fmt.Printf("DNS update (%s) for '%s' to %s successful\n", rtype, d.Name, value)
+	if err := u.cache.set(recordKey(d, rtype), value); err != nil {
+		fmt.Printf("Unable to write the cache: %v\n", err)
+	}
+	return value, nil
+}
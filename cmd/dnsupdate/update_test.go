@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rverst/go-miab/miab"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testBox is a Mail-in-a-Box serving the custom DNS records from memory.
+type testBox struct {
+	mu       sync.Mutex
+	records  miab.Records
+	requests []string // the changes as "method qname rtype value"
+	fail     bool     // all requests fail with a server error
+
+	server *httptest.Server
+	client *miab.Config
+}
+
+func newTestBox(t *testing.T, records ...miab.Record) *testBox {
+	b := &testBox{records: records}
+	b.server = httptest.NewServer(http.HandlerFunc(b.serve))
+	t.Cleanup(b.server.Close)
+
+	var err error
+	if b.client, err = miab.NewConfig("admin@example.org", "secret", b.server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func (b *testBox) serve(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fail {
+		http.Error(w, "failed", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Path == "/admin/system/version" {
+		_, _ = w.Write([]byte("v60"))
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/dns/custom"), "/")
+	parts := append(strings.Split(path, "/"), "", "")
+	qname, rtype := parts[0], miab.ResourceType(parts[1])
+	body, _ := ioutil.ReadAll(r.Body)
+	value := string(body)
+
+	matches := func(rec miab.Record) bool {
+		return (qname == "" || rec.QName == qname) && (rtype == "" || rec.RType == rtype)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		res := miab.Records{}
+		for _, rec := range b.records {
+			if matches(rec) {
+				res = append(res, rec)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	case http.MethodPut:
+		b.remove(func(rec miab.Record) bool { return matches(rec) })
+		b.records = append(b.records, miab.Record{QName: qname, RType: rtype, Value: value})
+	case http.MethodPost:
+		b.records = append(b.records, miab.Record{QName: qname, RType: rtype, Value: value})
+	case http.MethodDelete:
+		b.remove(func(rec miab.Record) bool { return matches(rec) && (value == "" || rec.Value == value) })
+	}
+	b.requests = append(b.requests, strings.TrimSpace(fmt.Sprintf("%s %s %s %s", r.Method, qname, rtype, value)))
+	_, _ = w.Write([]byte("updated DNS: " + qname))
+}
+
+func (b *testBox) remove(f func(miab.Record) bool) {
+	var res miab.Records
+	for _, rec := range b.records {
+		if !f(rec) {
+			res = append(res, rec)
+		}
+	}
+	b.records = res
+}
+
+// values returns the records as sorted "qname rtype value".
+func (b *testBox) values() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	res := []string{}
+	for _, r := range b.records {
+		res = append(res, fmt.Sprintf("%s %s %s", r.QName, r.RType, r.Value))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// changes returns the changes requested since the last call.
+func (b *testBox) changes() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	res := b.requests
+	b.requests = nil
+	return res
+}
+
+// testNotifier records the events notified as "type failures".
+type testNotifier struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (n *testNotifier) notify(_ context.Context, e event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, fmt.Sprintf("%s %d", e.Type, e.Failures))
+	return nil
+}
+
+// sent returns the events notified since the last call.
+func (n *testNotifier) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	res := n.events
+	n.events = nil
+	return res
+}
+
+// newTestUpdater returns an updater notifying the events synchronously.
+func newTestUpdater(t *testing.T, threshold int, events ...string) (*updater, *testNotifier) {
+	c, err := newCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &testNotifier{}
+	nc, err := newNotification(notifierConfig{Type: "exec", Command: "true", Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.notifier = n
+	return &updater{
+		cache:         c,
+		metrics:       newMetrics(),
+		notifications: []*notification{nc},
+		threshold:     threshold,
+		failures:      map[record]int{},
+	}, n
+}
+
+func TestUpdater_CheckEvents(t *testing.T) {
+
+	type step struct {
+		failed   bool
+		address  string
+		previous string
+		want     []string
+	}
+	ok := func(address, previous string, want ...string) step { return step{false, address, previous, want} }
+	fail := func(want ...string) step { return step{true, "", "", want} }
+
+	testCases := []struct {
+		name      string
+		threshold int
+		events    []string
+		steps     []step
+	}{
+		{"change", 3, nil, []step{
+			ok("192.0.2.1", "", "change 0"),
+			ok("192.0.2.1", "192.0.2.1"),
+			ok("192.0.2.2", "192.0.2.1", "change 0"),
+			ok("", ""),
+		}},
+		{"failure", 3, nil, []step{
+			fail(), fail(), fail("failure 3"), fail(), fail(),
+			ok("192.0.2.1", "192.0.2.1", "recovery 5"),
+			fail(),
+		}},
+		{"failures below threshold", 3, nil, []step{
+			fail(), fail(),
+			ok("192.0.2.1", "192.0.2.1"),
+			fail(), fail(),
+			ok("192.0.2.1", "192.0.2.1"),
+		}},
+		{"recovery with change", 2, nil, []step{
+			fail(), fail("failure 2"),
+			ok("192.0.2.2", "192.0.2.1", "recovery 2", "change 0"),
+		}},
+		{"threshold of one", 1, nil, []step{
+			fail("failure 1"), fail(),
+			ok("", "", "recovery 2"),
+		}},
+		{"filtered events", 1, []string{eventFailure}, []step{
+			ok("192.0.2.1", ""),
+			fail("failure 1"),
+			ok("192.0.2.2", "192.0.2.1"),
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, n := newTestUpdater(t, tc.threshold, tc.events...)
+			for i, s := range tc.steps {
+				e := event{Domain: "home.example.org", RType: "A", Address: s.address, Previous: s.previous}
+				if s.failed {
+					e.Error = "update failed"
+				}
+				u.checkEvents(e)
+				if got := n.sent(); !reflect.DeepEqual(got, s.want) {
+					t.Errorf("step %d, want: %v, got: %v", i, s.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdater_DoDnsUpdate(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		rtypes  []string
+		source  source
+		iid     string
+		cached  map[string]string
+		fail    bool
+		ok      bool
+		changes []string
+		cache   map[string]string
+	}{
+		{"new address", []string{"A"}, testSource{miab.TCP4: "192.0.2.1"}, "", nil, false, true,
+			[]string{"PUT home.example.org A 192.0.2.1"}, map[string]string{"box/home.example.org/A": "192.0.2.1"}},
+		{"unchanged address", []string{"A"}, testSource{miab.TCP4: "192.0.2.1"}, "",
+			map[string]string{"box/home.example.org/A": "192.0.2.1"}, false, true,
+			nil, map[string]string{"box/home.example.org/A": "192.0.2.1"}},
+		{"changed address", []string{"A"}, testSource{miab.TCP4: "192.0.2.2"}, "",
+			map[string]string{"box/home.example.org/A": "192.0.2.1"}, false, true,
+			[]string{"PUT home.example.org A 192.0.2.2"}, map[string]string{"box/home.example.org/A": "192.0.2.2"}},
+		{"both networks", []string{"A", "AAAA"}, testSource{miab.TCP4: "192.0.2.1", miab.TCP6: "2001:db8::1"}, "",
+			map[string]string{"box/home.example.org/AAAA": "2001:db8::1"}, false, true,
+			[]string{"PUT home.example.org A 192.0.2.1"},
+			map[string]string{"box/home.example.org/A": "192.0.2.1", "box/home.example.org/AAAA": "2001:db8::1"}},
+		{"interface id", []string{"AAAA"}, testSource{miab.TCP6: "2001:db8::1"}, "::1:2:3:4", nil, false, true,
+			[]string{"PUT home.example.org AAAA 2001:db8::1:2:3:4"},
+			map[string]string{"box/home.example.org/AAAA": "2001:db8::1:2:3:4"}},
+		{"address of the host", []string{"A"}, nil, "", nil, false, true,
+			[]string{"PUT home.example.org A"}, map[string]string{}},
+		{"discovery failed", []string{"A", "AAAA"}, testSource{miab.TCP4: "192.0.2.1"}, "", nil, false, false,
+			[]string{"PUT home.example.org A 192.0.2.1"}, map[string]string{"box/home.example.org/A": "192.0.2.1"}},
+		{"update failed", []string{"A"}, testSource{miab.TCP4: "192.0.2.2"}, "",
+			map[string]string{"box/home.example.org/A": "192.0.2.1"}, true, false,
+			nil, map[string]string{"box/home.example.org/A": "192.0.2.1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := newTestBox(t)
+			box.fail = tc.fail
+			u, _ := newTestUpdater(t, 3)
+			for k, v := range tc.cached {
+				u.cache.values[k] = v
+			}
+			d := domainConfig{Name: "home.example.org", Box: "box", RTypes: tc.rtypes, PrefixLength: 64,
+				client: box.client, source: tc.source, iid: net.ParseIP(tc.iid)}
+
+			if ok := u.doDnsUpdate(context.Background(), d); ok != tc.ok {
+				t.Errorf("want: %v, got: %v", tc.ok, ok)
+			}
+			if got := box.changes(); !reflect.DeepEqual(got, tc.changes) {
+				t.Errorf("changes, want: %v, got: %v", tc.changes, got)
+			}
+			if !reflect.DeepEqual(u.cache.values, tc.cache) {
+				t.Errorf("cache, want: %v, got: %v", tc.cache, u.cache.values)
+			}
+		})
+	}
+}
+
+func TestBoxAddress(t *testing.T) {
+
+	box := newTestBox(t,
+		miab.Record{QName: "home.example.org", RType: miab.A, Value: "192.0.2.1"},
+		miab.Record{QName: "home.example.org", RType: miab.AAAA, Value: "2001:0db8:0:0::1"},
+		miab.Record{QName: "multi.example.org", RType: miab.A, Value: "192.0.2.1"},
+		miab.Record{QName: "multi.example.org", RType: miab.A, Value: "192.0.2.2"},
+		miab.Record{QName: "invalid.example.org", RType: miab.A, Value: "example.org"},
+	)
+
+	testCases := []struct {
+		name    string
+		domain  string
+		rtype   string
+		fail    bool
+		want    string
+		wantErr bool
+	}{
+		{"A", "home.example.org", "A", false, "192.0.2.1", false},
+		{"AAAA normalized", "home.example.org", "AAAA", false, "2001:db8::1", false},
+		{"several records", "multi.example.org", "A", false, "", false},
+		{"no record", "other.example.org", "A", false, "", false},
+		{"no address", "invalid.example.org", "A", false, "", false},
+		{"failed", "home.example.org", "A", true, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box.fail = tc.fail
+			defer func() { box.fail = false }()
+
+			d := domainConfig{Name: tc.domain, Box: "box", client: box.client}
+			got, err := boxAddress(context.Background(), d, tc.rtype)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("wantErr: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyRecords(t *testing.T) {
+
+	box := newTestBox(t,
+		miab.Record{QName: "home.example.org", RType: miab.A, Value: "192.0.2.1"},
+		miab.Record{QName: "multi.example.org", RType: miab.A, Value: "192.0.2.1"},
+		miab.Record{QName: "multi.example.org", RType: miab.A, Value: "192.0.2.2"},
+	)
+	src := testSource{}
+
+	testCases := []struct {
+		name   string
+		domain domainConfig
+		cached string
+		fail   bool
+		want   string
+	}{
+		{"set from the box", domainConfig{Name: "home.example.org", RTypes: []string{"A"}, source: src}, "", false, "192.0.2.1"},
+		{"differs from the box", domainConfig{Name: "home.example.org", RTypes: []string{"A"}, source: src}, "192.0.2.9", false, "192.0.2.1"},
+		{"several records cleared", domainConfig{Name: "multi.example.org", RTypes: []string{"A"}, source: src}, "192.0.2.1", false, ""},
+		{"missing record cleared", domainConfig{Name: "other.example.org", RTypes: []string{"A"}, source: src}, "192.0.2.1", false, ""},
+		{"without source", domainConfig{Name: "home.example.org", RTypes: []string{"A"}}, "192.0.2.9", false, "192.0.2.9"},
+		{"box failed", domainConfig{Name: "home.example.org", RTypes: []string{"A"}, source: src}, "192.0.2.9", true, "192.0.2.9"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box.fail = tc.fail
+			defer func() { box.fail = false }()
+
+			c, err := newCache("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := tc.domain
+			d.Box, d.client = "box", box.client
+			if tc.cached != "" {
+				c.values[recordKey(d, "A")] = tc.cached
+			}
+
+			verifyRecords(context.Background(), []domainConfig{d}, c)
+			if got, _ := c.get(recordKey(d, "A")); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if got := box.changes(); len(got) > 0 {
+				t.Errorf("no changes expected, got: %v", got)
+			}
+		})
+	}
+}
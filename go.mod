@@ -1,9 +1,37 @@
 module github.com/rverst/go-miab
 
+go 1.21
+
 require (
+	filippo.io/age v1.2.1
+	github.com/miekg/dns v1.1.63
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/pelletier/go-toml v1.2.0
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/cobra v0.0.5
-	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.3.2
-	gopkg.in/yaml.v3 v3.0.0-20190709130402-674ba3eaed22
+	github.com/zalando/go-keyring v0.2.8
+	golang.org/x/net v0.35.0
+	golang.org/x/term v0.29.0
+	gopkg.in/yaml.v2 v2.2.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/danieljoos/wincred v1.2.3 // indirect
+	github.com/fsnotify/fsnotify v1.4.7 // indirect
+	github.com/godbus/dbus/v5 v5.2.2 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/magiconair/properties v1.8.0 // indirect
+	github.com/mitchellh/mapstructure v1.1.2 // indirect
+	github.com/spf13/afero v1.1.2 // indirect
+	github.com/spf13/jwalterweatherman v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.3 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/mod v0.20.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	golang.org/x/tools v0.24.1 // indirect
 )
@@ -3,11 +3,11 @@ package miab
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
-	"time"
 )
 
 const (
@@ -29,14 +29,15 @@ func (a AliasDomains) String() string {
 	return r.String()
 }
 
-// ToString returns a string of the AliasDomains in the provided Format.
+// ToString returns a string of the AliasDomains in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (a AliasDomains) ToString(format Format) string {
-	s, err := toString(a, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(a, format)
+}
+
+// Encode writes the AliasDomains in the provided Format to w.
+func (a AliasDomains) Encode(w io.Writer, format Format) error {
+	return encode(w, a, format)
 }
 
 // Aliases defines an array of Alias
@@ -63,14 +64,15 @@ func (a AliasDomain) String() string {
 	return r.String()
 }
 
-// ToString returns a string of the AliasDomains in the provided Format.
+// ToString returns a string of the AliasDomain in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (a AliasDomain) ToString(format Format) string {
-	s, err := toString(a, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(a, format)
+}
+
+// Encode writes the AliasDomain in the provided Format to w.
+func (a AliasDomain) Encode(w io.Writer, format Format) error {
+	return encode(w, a, format)
 }
 
 // Alias defines an e-mail alias
@@ -84,7 +86,7 @@ type Alias struct {
 
 func exeAlias(c *Config, path, body string) error {
 
-	client := &http.Client{Timeout: time.Second * 30}
+	client := c.client()
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/%s", c.url(), aliasPath, path), strings.NewReader(body))
 	if err != nil {
 		return err
@@ -114,7 +116,7 @@ func exeAlias(c *Config, path, body string) error {
 // GetAliases returns a list of existing e-mail aliases.
 func GetAliases(c *Config) (AliasDomains, error) {
 
-	client := &http.Client{Timeout: time.Second * 30}
+	client := c.client()
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s?format=json", c.url(), aliasPath), nil)
 	if err != nil {
 		return nil, err
@@ -148,14 +150,25 @@ func GetAliases(c *Config) (AliasDomains, error) {
 // AddAlias adds a new alias.
 // The parameter `forwardsTo` can be a comma separated list of addresses.
 func AddAlias(c *Config, address, forwardsTo string) error {
+	address, err := EmailToASCII(address)
+	if err != nil {
+		return err
+	}
+	if forwardsTo, err = emailsToASCII(forwardsTo); err != nil {
+		return err
+	}
 
-	body := fmt.Sprintf("address=%s&forwards_to=%s", address, forwardsTo)
+	body := url.Values{"address": {address}, "forwards_to": {forwardsTo}}.Encode()
 	return exeAlias(c, "add", body)
 }
 
 // DeleteAlias removes an alias.
 func DeleteAlias(c *Config, address string) error {
+	address, err := EmailToASCII(address)
+	if err != nil {
+		return err
+	}
 
-	body := fmt.Sprintf("address=%s", address)
+	body := url.Values{"address": {address}}.Encode()
 	return exeAlias(c, "remove", body)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -80,11 +81,11 @@ func TestAliasDomain_ToString(t *testing.T) {
 	}
 
 	want := strings.Builder{}
-	want.WriteString(csvAliasHead)
+	want.WriteString(`domain,address,display-address,forwards-to,permitted-senders,required`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "test@example.org", "test@example.org", "mail@example.org;info@example.org", "", false`)
+	want.WriteString(`example.org,test@example.org,test@example.org,"mail@example.org,info@example.org",,false`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "abuse@example.org", "abuse@example.org", "mail@example.org", "", true`)
+	want.WriteString(`example.org,abuse@example.org,abuse@example.org,mail@example.org,,true`)
 	want.WriteByte('\n')
 
 	got := testAliasDomain1.ToString(CSV)
@@ -133,13 +134,13 @@ func TestAliasDomains_ToString(t *testing.T) {
 	}
 
 	want := strings.Builder{}
-	want.WriteString(csvAliasHead)
+	want.WriteString(`domain,address,display-address,forwards-to,permitted-senders,required`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "test@example.org", "test@example.org", "mail@example.org;info@example.org", "", false`)
+	want.WriteString(`example.org,test@example.org,test@example.org,"mail@example.org,info@example.org",,false`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "abuse@example.org", "abuse@example.org", "mail@example.org", "", true`)
+	want.WriteString(`example.org,abuse@example.org,abuse@example.org,mail@example.org,,true`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.com", "abuse@example.com", "abuse@example.com", "mail@example.com", "", true`)
+	want.WriteString(`example.com,abuse@example.com,abuse@example.com,mail@example.com,,true`)
 	want.WriteByte('\n')
 
 	got := testAliasDomains.ToString(CSV)
@@ -194,11 +195,12 @@ func TestAddAlias(t *testing.T) {
 	}{
 		{"user@example.org", "user2@example.org", 200, false},
 		{"user@example.org", "user2@example.org", 503, true},
+		{"user@example.org", "user2@example.org,user+tag@example.org", 200, false},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("address=%s&forwards_to=%s", tc.email, tc.forwards))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"address": {tc.email}, "forwards_to": {tc.forwards}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := AddAlias(c, tc.email, tc.forwards)
@@ -225,7 +227,7 @@ func TestRemoveAlias(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("address=%s", tc.email))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"address": {tc.email}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := DeleteAlias(c, tc.email)
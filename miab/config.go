@@ -1,17 +1,31 @@
 package miab
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
+const defaultTimeout = 30 * time.Second
+
 var (
-	regexUrl  = *regexp.MustCompile(`^(?P<schema>https?)://(?P<domain>.+)$`)
-	errNoUser = errors.New("'user' not specified")
-	errNoPass = errors.New("'password' not specified")
-	errInvUrl = errors.New("'url' is not valid")
+	regexUrl      = *regexp.MustCompile(`^(?P<schema>https?)://(?P<domain>.+)$`)
+	errNoUser     = errors.New("'user' not specified")
+	errNoPass     = errors.New("'password' not specified")
+	errInvUrl     = errors.New("'url' is not valid")
+	errNoCACert   = errors.New("no valid certificate found in CA bundle")
+	errInvPin     = errors.New("'pin' has to be a hex encoded SHA-256 fingerprint")
+	errPinMatch   = errors.New("server certificate does not match any pinned fingerprint")
+	errInvTimeout = errors.New("'timeout' has to be positive")
 )
 
 // Config holds the details to communicate with the Mail-in-a-Box API.
@@ -20,10 +34,20 @@ type Config struct {
 	password string
 	scheme   string
 	domain   string
+	tls      *tls.Config
+
+	transport    *http.Transport // shared by the requests, so connections are reused (nil: http.DefaultTransport)
+	noValidation bool
+	timeout      time.Duration
+	version      *versionCache // the version of the box, once it is known (see checkVersion)
 }
 
+// Option configures optional settings of a Config, see NewConfig.
+type Option func(c *Config) error
+
 // NewConfig creates a new configuration to access the Mail-in-a-Box API.
-func NewConfig(user, password, url string) (*Config, error) {
+// The TLS settings can be adjusted by passing options, e.g. WithCAFile or WithPinnedCertificates.
+func NewConfig(user, password, url string, options ...Option) (*Config, error) {
 	if user == "" {
 		return nil, errNoUser
 	}
@@ -39,14 +63,173 @@ func NewConfig(user, password, url string) (*Config, error) {
 		return nil, errInvUrl
 	}
 
-	return &Config{
+	c := &Config{
 		user:     user,
 		password: password,
 		scheme:   res[0][1],
 		domain:   strings.TrimRight(res[0][2], `/`),
-	}, nil
+		version:  &versionCache{},
+	}
+
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.tls != nil {
+		c.transport = c.newTransport()
+	}
+	return c, nil
+}
+
+// WithCACert adds the PEM encoded certificates to the pool of trusted certificate authorities. The certificates
+// are trusted in addition to the system roots, which is handy for boxes behind an internal CA.
+func WithCACert(pem []byte) Option {
+	return func(c *Config) error {
+		t := c.tlsConfig()
+		if t.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+			t.RootCAs = pool
+		}
+		if !t.RootCAs.AppendCertsFromPEM(pem) {
+			return errNoCACert
+		}
+		return nil
+	}
+}
+
+// WithCAFile reads a PEM encoded CA bundle from the file and adds it to the trusted certificate authorities,
+// see WithCACert.
+func WithCAFile(file string) Option {
+	return func(c *Config) error {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read CA bundle: %v", err)
+		}
+		return WithCACert(pem)(c)
+	}
+}
+
+// WithClientCertificate loads a PEM encoded certificate and key pair from the files and uses it to authenticate
+// the client in the TLS handshake.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *Config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		t := c.tlsConfig()
+		t.Certificates = append(t.Certificates, cert)
+		return nil
+	}
+}
+
+// WithPinnedCertificates pins the server certificate to the given SHA-256 fingerprints (hex encoded, colons are
+// allowed, e.g. the output of `openssl x509 -noout -fingerprint -sha256`). If pins are set, the connection is
+// accepted if the leaf certificate matches one of them, the certificate chain is not verified. This allows to
+// reach a box via IP or with a self-signed certificate without disabling verification altogether.
+func WithPinnedCertificates(fingerprints ...string) Option {
+	return func(c *Config) error {
+		var pins [][]byte
+		for _, f := range fingerprints {
+			f = strings.Replace(strings.TrimSpace(f), ":", "", -1)
+			if f == "" {
+				continue
+			}
+			pin, err := hex.DecodeString(f)
+			if err != nil || len(pin) != sha256.Size {
+				return errInvPin
+			}
+			pins = append(pins, pin)
+		}
+		if len(pins) == 0 {
+			return errInvPin
+		}
+
+		t := c.tlsConfig()
+		t.InsecureSkipVerify = true
+		t.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errPinMatch
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+			return errPinMatch
+		}
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of the server certificate. WARNING: This makes the connection
+// vulnerable to man-in-the-middle attacks and exposes the credentials, use WithPinnedCertificates if possible.
+// Pinned certificates are still checked, regardless of the order of the options.
+func WithInsecureSkipVerify() Option {
+	return func(c *Config) error {
+		c.tlsConfig().InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithTimeout sets the maximal duration of a request to the Mail-in-a-Box API, 30 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		if timeout <= 0 {
+			return errInvTimeout
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// WithoutValidation disables the client-side validation of qnames and values of dns records, they are sent to
+// the Mail-in-a-Box API as provided (internationalized domain names are still converted, if possible).
+func WithoutValidation() Option {
+	return func(c *Config) error {
+		c.noValidation = true
+		return nil
+	}
+}
+
+// Insecure reports if the verification of the server certificate is disabled (see WithInsecureSkipVerify).
+func (c *Config) Insecure() bool {
+	return c.tls != nil && c.tls.InsecureSkipVerify && c.tls.VerifyPeerCertificate == nil
+}
+
+func (c *Config) tlsConfig() *tls.Config {
+	if c.tls == nil {
+		c.tls = &tls.Config{}
+	}
+	return c.tls
 }
 
 func (c *Config) url() string {
 	return fmt.Sprintf("%s://%s", c.scheme, c.domain)
 }
+
+// newTransport returns a new http.Transport with the TLS settings of the config.
+func (c *Config) newTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if c.tls != nil {
+		tr.TLSClientConfig = c.tls.Clone()
+	}
+	return tr
+}
+
+// client returns the http.Client to use for requests to the Mail-in-a-Box API.
+func (c *Config) client() *http.Client {
+	timeout := c.timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	if c.transport == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	return &http.Client{Timeout: timeout, Transport: c.transport}
+}
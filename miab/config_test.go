@@ -1,8 +1,17 @@
 package miab
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -48,9 +57,9 @@ func TestNewConfig(t *testing.T) {
 		pass string
 		want Config
 	}{
-		{"testUser", "secretPassw0rd", Config{"testUser", "secretPassw0rd", "http", "example.org"}},
-		{"t", "s", Config{"t", "s", "http", "example.org"}},
-		{"1234567890", "1234567890", Config{"1234567890", "1234567890", "http", "example.org"}},
+		{"testUser", "secretPassw0rd", Config{user: "testUser", password: "secretPassw0rd", scheme: "http", domain: "example.org"}},
+		{"t", "s", Config{user: "t", password: "s", scheme: "http", domain: "example.org"}},
+		{"1234567890", "1234567890", Config{user: "1234567890", password: "1234567890", scheme: "http", domain: "example.org"}},
 	}
 
 	for _, tc := range testCasesUrlPass {
@@ -83,10 +92,11 @@ func TestNewConfig(t *testing.T) {
 				return
 			}
 
-			if dns == nil {
-				t.Error("dns == nil")
+			if dns == nil || dns.version == nil {
+				t.Fatal("dns == nil")
 			}
 
+			tc.want.version = dns.version
 			if tc.want != *dns {
 				t.Errorf("expected: %v, got %v", tc.want, dns)
 			}
@@ -155,8 +165,8 @@ func TestConfig_url(t *testing.T) {
 		cfg  Config
 		want string
 	}{
-		{Config{"t", "s", "http", "example.org"}, "http://example.org"},
-		{Config{"t", "s", "https", "example.org"}, "https://example.org"},
+		{Config{user: "t", password: "s", scheme: "http", domain: "example.org"}, "http://example.org"},
+		{Config{user: "t", password: "s", scheme: "https", domain: "example.org"}, "https://example.org"},
 	}
 
 	for _, tc := range testCases {
@@ -167,3 +177,148 @@ func TestConfig_url(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_TLS(t *testing.T) {
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256(ts.Certificate().Raw)
+	pin := hex.EncodeToString(sum[:])
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+
+	var colonPin []string
+	for i := 0; i < len(pin); i += 2 {
+		colonPin = append(colonPin, strings.ToUpper(pin[i:i+2]))
+	}
+
+	testCases := []struct {
+		name      string
+		options   []Option
+		wantError bool
+	}{
+		{"no options", nil, true},
+		{"ca cert", []Option{WithCACert(caPem)}, false},
+		{"pinned", []Option{WithPinnedCertificates(pin)}, false},
+		{"pinned with colons", []Option{WithPinnedCertificates(strings.Join(colonPin, ":"))}, false},
+		{"pinned mismatch", []Option{WithPinnedCertificates(strings.Repeat("00", sha256.Size))}, true},
+		{"insecure", []Option{WithInsecureSkipVerify()}, false},
+		{"pinned mismatch and insecure", []Option{WithPinnedCertificates(strings.Repeat("00", sha256.Size)), WithInsecureSkipVerify()}, true},
+		{"insecure and pinned mismatch", []Option{WithInsecureSkipVerify(), WithPinnedCertificates(strings.Repeat("00", sha256.Size))}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfig("test", "secret", ts.URL, tc.options...)
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+
+			_, err = GetDns(c, "", NONE)
+			if tc.wantError && err == nil {
+				t.Error("want error, got: nil")
+			} else if !tc.wantError && err != nil {
+				t.Errorf("got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_TLSOptionErrors(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		option Option
+		want   error
+	}{
+		{"invalid ca", WithCACert([]byte("no certificate")), errNoCACert},
+		{"no pin", WithPinnedCertificates(), errInvPin},
+		{"short pin", WithPinnedCertificates("abcdef"), errInvPin},
+		{"invalid pin", WithPinnedCertificates(strings.Repeat("zz", sha256.Size)), errInvPin},
+		{"zero timeout", WithTimeout(0), errInvTimeout},
+		{"negative timeout", WithTimeout(-time.Second), errInvTimeout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfig("test", "secret", "https://example.org", tc.option)
+			if err != tc.want {
+				t.Errorf("expected error: %v, got %v", tc.want, err)
+			}
+			if c != nil {
+				t.Error("c != nil")
+			}
+		})
+	}
+
+	if _, err := NewConfig("test", "secret", "https://example.org", WithCAFile("does/not/exist.pem")); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestConfig_Transport(t *testing.T) {
+
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	sum := sha256.Sum256(ts.Certificate().Raw)
+	c, err := NewConfig("test", "secret", ts.URL, WithPinnedCertificates(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	copied := *c
+	for _, cfg := range []*Config{c, c, &copied} {
+		if _, err := GetDns(cfg, "", NONE); err != nil {
+			t.Fatalf("err != nil: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("want 1 connection, got: %d", n)
+	}
+}
+
+func TestConfig_Timeout(t *testing.T) {
+	c, _ := NewConfig("test", "secret", "https://example.org")
+	if got := c.client().Timeout; got != defaultTimeout {
+		t.Errorf("want: %v, got: %v", defaultTimeout, got)
+	}
+
+	c, _ = NewConfig("test", "secret", "https://example.org", WithTimeout(5*time.Second), WithInsecureSkipVerify())
+	if got := c.client().Timeout; got != 5*time.Second {
+		t.Errorf("want: %v, got: %v", 5*time.Second, got)
+	}
+}
+
+func TestConfig_Insecure(t *testing.T) {
+	c, _ := NewConfig("test", "secret", "https://example.org")
+	if c.Insecure() {
+		t.Error("expected secure config")
+	}
+
+	c, _ = NewConfig("test", "secret", "https://example.org", WithPinnedCertificates(strings.Repeat("00", sha256.Size)))
+	if c.Insecure() {
+		t.Error("expected pinned config to be secure")
+	}
+
+	c, _ = NewConfig("test", "secret", "https://example.org", WithInsecureSkipVerify())
+	if !c.Insecure() {
+		t.Error("expected insecure config")
+	}
+
+	c, _ = NewConfig("test", "secret", "https://example.org",
+		WithPinnedCertificates(strings.Repeat("00", sha256.Size)), WithInsecureSkipVerify())
+	if c.Insecure() {
+		t.Error("expected pinned and insecure config to be secure")
+	}
+}
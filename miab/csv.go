@@ -0,0 +1,230 @@
+package miab
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumnAliases maps the column names of older csv outputs to the current ones.
+var csvColumnAliases = map[string]string{
+	"domainname":       "qname",
+	"recordtype":       "rtype",
+	"displayaddress":   "display-address",
+	"forwardsto":       "forwards-to",
+	"permittedsenders": "permitted-senders",
+}
+
+// CsvError describes an invalid row of a csv input.
+type CsvError struct {
+	Row int   // Row is the number of the row (1-based, including the header row).
+	Err error // Err is the reason.
+}
+
+func (e *CsvError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func marshallCsv(i interface{}) (string, error) {
+	columns, rows, err := tableData(i)
+	if err != nil {
+		return "", err
+	}
+
+	r := strings.Builder{}
+	w := csv.NewWriter(&r)
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// csvRow is a row of a csv input, the values are accessed by the column name.
+type csvRow struct {
+	row     int
+	columns map[string]int
+	values  []string
+}
+
+func (r csvRow) get(column string) string {
+	if n, ok := r.columns[column]; ok && n < len(r.values) {
+		return strings.TrimSpace(r.values[n])
+	}
+	return ""
+}
+
+func (r csvRow) list(column string) []string {
+	var res []string
+	for _, v := range strings.FieldsFunc(r.get(column), func(c rune) bool { return c == ',' || c == ';' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// readCsv reads a csv input with a header row, the required columns have to be present.
+func readCsv(in io.Reader, required ...string) ([]csvRow, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv input is empty")
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for n, h := range header {
+		c := strings.ToLower(strings.TrimSpace(h))
+		if a, ok := csvColumnAliases[strings.Replace(c, " ", "", -1)]; ok {
+			c = a
+		}
+		columns[c] = n
+	}
+	for _, c := range required {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("csv input has no column '%s'", c)
+		}
+	}
+
+	var rows []csvRow
+	for {
+		values, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, csvRow{row: line, columns: columns, values: values})
+	}
+	return rows, nil
+}
+
+// ParseRecordsCsv reads Records from a csv input with the columns qname, rtype and value,
+// as written by the CSV Format.
+func ParseRecordsCsv(in io.Reader) (Records, error) {
+	rows, err := readCsv(in, "qname", "rtype", "value")
+	if err != nil {
+		return nil, err
+	}
+
+	var result Records
+	for _, r := range rows {
+		rtype, err := ParseDnsResource(r.get("rtype"))
+		if err != nil {
+			return nil, &CsvError{Row: r.row, Err: err}
+		}
+		if r.get("qname") == "" {
+			return nil, &CsvError{Row: r.row, Err: fmt.Errorf("'qname' is empty")}
+		}
+		result = append(result, Record{QName: r.get("qname"), RType: rtype, Value: r.get("value")})
+	}
+	return result, nil
+}
+
+// ParseMailDomainsCsv reads MailDomains from a csv input with the column email and the optional columns domain,
+// privileges, status, mailbox and password, as written by the CSV Format (which never contains passwords).
+// The domain defaults to the domain of the e-mail address, the status to Active.
+func ParseMailDomainsCsv(in io.Reader) (MailDomains, error) {
+	rows, err := readCsv(in, "email")
+	if err != nil {
+		return nil, err
+	}
+
+	var result MailDomains
+	index := map[string]int{}
+	for _, r := range rows {
+		email := r.get("email")
+		at := strings.LastIndex(email, "@")
+		if at < 1 || at == len(email)-1 {
+			return nil, &CsvError{Row: r.row, Err: fmt.Errorf("'%s' is not a valid e-mail address", email)}
+		}
+
+		domain := r.get("domain")
+		if domain == "" {
+			domain = email[at+1:]
+		}
+		status := Status(r.get("status"))
+		if status == "" {
+			status = Active
+		}
+		var priv []interface{}
+		for _, p := range r.list("privileges") {
+			priv = append(priv, p)
+		}
+
+		n, ok := index[domain]
+		if !ok {
+			n = len(result)
+			index[domain] = n
+			result = append(result, MailDomain{Domain: domain})
+		}
+		result[n].Users = append(result[n].Users, User{
+			Email:      email,
+			Privileges: priv,
+			Status:     status,
+			Mailbox:    r.get("mailbox"),
+			Password:   r.get("password"),
+		})
+	}
+	return result, nil
+}
+
+// ParseAliasDomainsCsv reads AliasDomains from a csv input with the columns address and forwards-to and the
+// optional columns domain, display-address, permitted-senders and required, as written by the CSV Format.
+// The domain defaults to the domain of the address.
+func ParseAliasDomainsCsv(in io.Reader) (AliasDomains, error) {
+	rows, err := readCsv(in, "address", "forwards-to")
+	if err != nil {
+		return nil, err
+	}
+
+	var result AliasDomains
+	index := map[string]int{}
+	for _, r := range rows {
+		address := r.get("address")
+		at := strings.LastIndex(address, "@")
+		if at < 0 || at == len(address)-1 {
+			return nil, &CsvError{Row: r.row, Err: fmt.Errorf("'%s' is not a valid alias address", address)}
+		}
+
+		domain := r.get("domain")
+		if domain == "" {
+			domain = address[at+1:]
+		}
+		display := r.get("display-address")
+		if display == "" {
+			display = address
+		}
+		required := false
+		if s := r.get("required"); s != "" {
+			if required, err = strconv.ParseBool(s); err != nil {
+				return nil, &CsvError{Row: r.row, Err: fmt.Errorf("'required' has to be true or false")}
+			}
+		}
+
+		n, ok := index[domain]
+		if !ok {
+			n = len(result)
+			index[domain] = n
+			result = append(result, AliasDomain{Domain: domain})
+		}
+		result[n].Aliases = append(result[n].Aliases, Alias{
+			Address:          address,
+			DisplayAddress:   display,
+			ForwardsTo:       r.list("forwards-to"),
+			PermittedSenders: r.list("permitted-senders"),
+			Required:         required,
+		})
+	}
+	return result, nil
+}
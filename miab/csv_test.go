@@ -0,0 +1,151 @@
+package miab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCsv_RoundTrip(t *testing.T) {
+
+	dkim := Record{QName: "mail._domainkey.example.org", RType: TXT, Value: `"v=DKIM1; k=rsa; s=email; " "p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8A"`}
+	records := append(Records{dkim}, testRecs...)
+
+	s, err := toString(records, CSV)
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	want := "qname,rtype,value\n" +
+		`mail._domainkey.example.org,TXT,"""v=DKIM1; k=rsa; s=email; "" ""p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8A"""` + "\n" +
+		"example.org,A,127.0.0.1\nexample.org,AAAA,::1\n"
+	if s != want {
+		t.Errorf("wrong format, want:\n%s\ngot:\n%s", want, s)
+	}
+
+	gotRecords, err := ParseRecordsCsv(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if len(gotRecords) != len(records) {
+		t.Fatalf("want: %d records, got: %d", len(records), len(gotRecords))
+	}
+	for i := range records {
+		if records[i] != gotRecords[i] {
+			t.Errorf("want: %v, got: %v", records[i], gotRecords[i])
+		}
+	}
+
+	gotUsers, err := ParseMailDomainsCsv(strings.NewReader(testMailDomains.ToString(CSV)))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if gotUsers.ToString(CSV) != testMailDomains.ToString(CSV) {
+		t.Errorf("want:\n%s\ngot:\n%s", testMailDomains.ToString(CSV), gotUsers.ToString(CSV))
+	}
+
+	gotAliases, err := ParseAliasDomainsCsv(strings.NewReader(testAliasDomains.ToString(CSV)))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if gotAliases.ToString(CSV) != testAliasDomains.ToString(CSV) {
+		t.Errorf("want:\n%s\ngot:\n%s", testAliasDomains.ToString(CSV), gotAliases.ToString(CSV))
+	}
+}
+
+func TestParseMailDomainsCsv(t *testing.T) {
+	in := "email,password,privileges\n" +
+		"admin@example.org,secret1,admin\n" +
+		"user@example.com,secret2,\n" +
+		"user2@example.org,secret3,\n"
+
+	got, err := ParseMailDomainsCsv(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if len(got) != 2 || got[0].Domain != "example.org" || len(got[0].Users) != 2 || got[1].Domain != "example.com" {
+		t.Fatalf("unexpected result: %v", got)
+	}
+	u := got[0].Users[0]
+	if u.Email != "admin@example.org" || u.Password != "secret1" || u.Status != Active ||
+		len(privileges(u.Privileges)) != 1 || privileges(u.Privileges)[0] != "admin" {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	// the passwords are never written
+	for _, format := range []Format{JSON, YAML, NDJSON, TOML, CSV, TABLE, MARKDOWN, HTML, PLAIN} {
+		if out := got.ToString(format); strings.Contains(out, "secret") {
+			t.Errorf("%s output contains a password: %s", format, out)
+		}
+	}
+}
+
+func TestParseAliasDomainsCsv_Legacy(t *testing.T) {
+	// the format written by earlier versions
+	in := `"domain", address", "displayAddress", "forwardsTo", "permittedSenders", "required"` + "\n" +
+		`"example.org", "test@example.org", "test@example.org", "mail@example.org;info@example.org", "", false` + "\n"
+
+	_, err := ParseAliasDomainsCsv(strings.NewReader(in))
+	if err == nil {
+		t.Error("error expected, the header is broken")
+	}
+
+	in = strings.Replace(in, ` address"`, ` "address"`, 1)
+	got, err := ParseAliasDomainsCsv(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if len(got) != 1 || len(got[0].Aliases) != 1 || len(got[0].Aliases[0].ForwardsTo) != 2 ||
+		got[0].Aliases[0].ForwardsTo[1] != "info@example.org" || got[0].Aliases[0].Required {
+		t.Errorf("unexpected result: %v", got)
+	}
+}
+
+func TestParseCsv_Errors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		parse func(in string) error
+		in    string
+		row   int
+	}{
+		{"records empty", parseRecords, "", 0},
+		{"records missing column", parseRecords, "qname,value\nexample.org,127.0.0.1\n", 0},
+		{"records invalid rtype", parseRecords, "qname,rtype,value\nexample.org,A,127.0.0.1\nexample.org,B,127.0.0.1\n", 3},
+		{"records empty qname", parseRecords, "qname,rtype,value\n,A,127.0.0.1\n", 2},
+		{"records bare quote", parseRecords, "qname,rtype,value\nexample.org,TXT,a\"b\n", 0},
+		{"users invalid email", parseUsers, "email\nadmin@example.org\nadmin.example.org\n", 3},
+		{"aliases invalid required", parseAliases, "address,forwards-to,required\ntest@example.org,mail@example.org,maybe\n", 2},
+		{"aliases invalid address", parseAliases, "address,forwards-to\ntest,mail@example.org\n", 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.parse(tc.in)
+			if err == nil {
+				t.Fatal("error expected")
+			}
+			if tc.row > 0 {
+				e, ok := err.(*CsvError)
+				if !ok {
+					t.Fatalf("want CsvError, got: %v", err)
+				}
+				if e.Row != tc.row {
+					t.Errorf("want row: %d, got: %d", tc.row, e.Row)
+				}
+			}
+		})
+	}
+}
+
+func parseRecords(in string) error {
+	_, err := ParseRecordsCsv(strings.NewReader(in))
+	return err
+}
+
+func parseUsers(in string) error {
+	_, err := ParseMailDomainsCsv(strings.NewReader(in))
+	return err
+}
+
+func parseAliases(in string) error {
+	_, err := ParseAliasDomainsCsv(strings.NewReader(in))
+	return err
+}
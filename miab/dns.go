@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"os"
-	"regexp"
 	"strings"
-	"time"
+	"sync"
 )
 
 // ResourceType defines a dns resource type (e.g. 'A', 'AAAA', TXT...)
@@ -21,8 +20,6 @@ type ResourceType string
 type NetworkType string
 
 var (
-	regexQname     = *regexp.MustCompile(`^\*?\.?(?:[a-zA-Z0-9-]{2,63}\.?)+\.([a-zA-Z]{2,})$`)
-	errInvQname    = errors.New("'qname' seems to be invalid")
 	errInvNet      = errors.New("'network' has to be `tcp4` or `tcp6`")
 	errRtypeNotSet = errors.New("'rtype' has to be set")
 )
@@ -63,10 +60,27 @@ const TCP4 = NetworkType(`tcp4`)
 // TCP6 - transport via TCP/IPv6
 const TCP6 = NetworkType(`tcp6`)
 
-var allResourceTypes = []ResourceType{A, AAAA, TXT, CNAME, MX, SRV, SSHFP, CAA, NS}
+// TLSA - TLS certificate association (RFC 6698), has to be registered with RegisterResourceType
+const TLSA = ResourceType(`TLSA`)
 
-// IsValid checks if the ResourceType is valid (supported by the Mail-in-a-Box API).
+// DS - delegation signer (RFC 4034), has to be registered with RegisterResourceType
+const DS = ResourceType(`DS`)
+
+var (
+	rtypesMu         sync.RWMutex
+	allResourceTypes = []ResourceType{A, AAAA, TXT, CNAME, MX, SRV, SSHFP, CAA, NS}
+	minVersions      = map[ResourceType]Version{}
+
+	// knownMinVersions are the first Mail-in-a-Box versions accepting the resource types, used if no version is
+	// passed to RegisterResourceType.
+	knownMinVersions = map[ResourceType]string{TLSA: "v60", DS: "v60"}
+)
+
+// IsValid checks if the ResourceType is valid (supported by the Mail-in-a-Box API or registered with
+// RegisterResourceType).
 func (r *ResourceType) IsValid() bool {
+	rtypesMu.RLock()
+	defer rtypesMu.RUnlock()
 
 	for _, t := range allResourceTypes {
 		if (*r) == t {
@@ -76,6 +90,65 @@ func (r *ResourceType) IsValid() bool {
 	return false
 }
 
+// RegisterResourceType adds a ResourceType that is supported by Mail-in-a-Box versions since minVersion
+// (e.g. `v60`). An empty minVersion defaults to the known minimum version of TLSA and DS (v60) and to all versions
+// for other types. Before records of the type are set, added or deleted, the version
+// of the box is checked (see GetVersion). The values are parsed and validated by parse, which may be nil for
+// TLSA and DS to use the built-in parsers.
+func RegisterResourceType(rtype ResourceType, minVersion string, parse func(string) (RecordValue, error)) error {
+	rtype = ResourceType(strings.ToUpper(string(rtype)))
+	if rtype == NONE {
+		return errRtypeNotSet
+	}
+
+	var version Version
+	if minVersion == "" {
+		minVersion = knownMinVersions[rtype]
+	}
+	if minVersion != "" {
+		var err error
+		if version, err = ParseVersion(minVersion); err != nil {
+			return err
+		}
+	}
+
+	if parse == nil {
+		parse = extraValueParsers[rtype]
+		if parse == nil {
+			return fmt.Errorf("no parser for the resource type '%s' provided", rtype)
+		}
+	}
+
+	rtypesMu.Lock()
+	defer rtypesMu.Unlock()
+	if _, ok := valueParsers[rtype]; !ok {
+		allResourceTypes = append(allResourceTypes, rtype)
+	}
+	valueParsers[rtype] = parse
+	minVersions[rtype] = version
+	return nil
+}
+
+// checkVersion checks if the box supports records of the ResourceType. The version of the box is requested only
+// once per Config.
+func checkVersion(ctx context.Context, c *Config, rtype ResourceType) error {
+	rtypesMu.RLock()
+	minVersion, ok := minVersions[rtype]
+	rtypesMu.RUnlock()
+	if !ok || minVersion == (Version{}) {
+		return nil
+	}
+
+	v, err := c.version.get(ctx, c)
+	if err != nil {
+		return fmt.Errorf("unable to check if %s records are supported: %v", rtype, err)
+	}
+	if v.Less(minVersion) {
+		return fmt.Errorf("%s records are not supported by the box (version %s, %s or later required)", rtype, v, minVersion)
+	}
+	return nil
+}
+
 // ParseDnsResource parses the provided string to a ResourceType if possible.
 func ParseDnsResource(value string) (ResourceType, error) {
 
@@ -104,14 +177,15 @@ func dnsPath(qname string, rtype ResourceType) string {
 // Records defines an array of Record
 type Records []Record
 
-// ToString returns a string of the Records in the provided Format
+// ToString returns a string of the Records in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (r Records) ToString(format Format) string {
-	s, err := toString(r, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(r, format)
+}
+
+// Encode writes the Records in the provided Format to w.
+func (r Records) Encode(w io.Writer, format Format) error {
+	return encode(w, r, format)
 }
 
 // String returns a string representation of the Records
@@ -133,14 +207,15 @@ type Record struct {
 	Value string       `json:"value"` // Value holds the value of the record
 }
 
-// ToString returns a string of the Record in the provided Format
+// ToString returns a string of the Record in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (r Record) ToString(format Format) string {
-	s, err := toString(r, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(r, format)
+}
+
+// Encode writes the Record in the provided Format to w.
+func (r Record) Encode(w io.Writer, format Format) error {
+	return encode(w, r, format)
 }
 
 // String returns a string representation of the Record
@@ -148,18 +223,70 @@ func (r Record) String() string {
 	return fmt.Sprintf("%s\t%s\t%s", r.QName, r.RType, r.Value)
 }
 
-func execDns(c *Config, method, qname string, rtype ResourceType, value string) (bool, error) {
+// checkQName converts the qname to ASCII (see ToASCII) and validates it (see ValidateQName), unless the
+// validation is disabled (see WithoutValidation).
+func (c *Config) checkQName(qname string, rtype ResourceType) (string, error) {
+	a, err := ToASCII(qname)
+	if c.noValidation {
+		if err != nil {
+			return qname, nil
+		}
+		return a, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateQName(a, rtype); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(a, "."), nil
+}
 
-	if !regexQname.MatchString(qname) {
-		return false, errInvQname
+// checkValue validates a non-empty value and returns it in canonical form (see ParseRecordValue), unless the
+// validation is disabled (see WithoutValidation).
+func (c *Config) checkValue(rtype ResourceType, value string) (string, error) {
+	if value == "" || c.noValidation {
+		return value, nil
+	}
+	v, err := ParseRecordValue(rtype, value)
+	if err != nil {
+		return "", err
 	}
+	return v.String(), nil
+}
+
+func execDns(ctx context.Context, c *Config, method, qname string, rtype ResourceType, value string) (bool, error) {
 
 	if !rtype.IsValid() {
 		return false, errRtypeNotSet
 	}
 
-	client := &http.Client{Timeout: time.Second * 30}
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), strings.NewReader(value))
+	qname, err := c.checkQName(qname, rtype)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := c.checkValue(rtype, value)
+	if err != nil {
+		return false, err
+	}
+	// the box deletes only records matching the value as stored, so the value of a deletion is sent as given
+	if method != http.MethodDelete {
+		value = v
+	}
+
+	if err := checkVersion(ctx, c, rtype); err != nil {
+		return false, err
+	}
+
+	return sendDns(ctx, c, method, qname, rtype, value)
+}
+
+// sendDns sends the change of a record to the Mail-in-a-Box API, without any checks.
+func sendDns(ctx context.Context, c *Config, method, qname string, rtype ResourceType, value string) (bool, error) {
+
+	client := c.client()
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), strings.NewReader(value))
 	if err != nil {
 		return false, err
 	}
@@ -192,13 +319,21 @@ func execDns(c *Config, method, qname string, rtype ResourceType, value string)
 // filter the records returned. NOTE: Due to a weired behavior in the Mail-in-a-Box api, if the qname is given
 // and the rtype not (NONE), the rtype defaults to A records.
 func GetDns(c *Config, qname string, rtype ResourceType) (Records, error) {
+	return GetDnsContext(context.Background(), c, qname, rtype)
+}
 
-	if qname != "" && !regexQname.MatchString(qname) {
-		return nil, errInvQname
+// GetDnsContext is like GetDns, the request is canceled with the context.
+func GetDnsContext(ctx context.Context, c *Config, qname string, rtype ResourceType) (Records, error) {
+
+	var err error
+	if qname != "" {
+		if qname, err = c.checkQName(qname, rtype); err != nil {
+			return nil, err
+		}
 	}
 
-	client := &http.Client{Timeout: time.Second * 30}
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), nil)
+	client := c.client()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -229,27 +364,138 @@ func GetDns(c *Config, qname string, rtype ResourceType) (Records, error) {
 }
 
 // SetDns sets a custom DNS record replacing any existing records with the same qname and rtype.
+// The value is validated and sent in canonical form, see ParseRecordValue.
 // Use SetDns (instead of AddDns) when you only have one value for a qname and rtype,
 // such as typical A records (without round-robin).
 // Returns true if the DNS was updated
 func SetDns(c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+	return SetDnsContext(context.Background(), c, qname, rtype, value)
+}
 
-	return execDns(c, http.MethodPut, qname, rtype, value)
+// SetDnsContext is like SetDns, the requests are canceled with the context.
+func SetDnsContext(ctx context.Context, c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+
+	return execDns(ctx, c, http.MethodPut, qname, rtype, value)
 }
 
 // AddDns adds a new custom DNS record. Use AddDns when you have multiple TXT records or round-robin A records.
+// The value is validated and sent in canonical form, see ParseRecordValue.
 // Returns true if the DNS was updated
 func AddDns(c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+	return AddDnsContext(context.Background(), c, qname, rtype, value)
+}
 
-	return execDns(c, http.MethodPost, qname, rtype, value)
+// AddDnsContext is like AddDns, the requests are canceled with the context.
+func AddDnsContext(ctx context.Context, c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+
+	return execDns(ctx, c, http.MethodPost, qname, rtype, value)
 }
 
 // DeleteDns removes custom DNS records. If the value empty, deletes all records matching the qname and rtype.
-// If the value is present, deletes only the record matching the qname, rtype and value.
+// If the value is present, deletes only the record matching the qname, rtype and value. The value is validated but
+// sent as given (not in canonical form), as it has to match the value stored on the box.
 // Returns true if the DNS was updated
 func DeleteDns(c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+	return DeleteDnsContext(context.Background(), c, qname, rtype, value)
+}
+
+// DeleteDnsContext is like DeleteDns, the requests are canceled with the context.
+func DeleteDnsContext(ctx context.Context, c *Config, qname string, rtype ResourceType, value string) (bool, error) {
+
+	return execDns(ctx, c, http.MethodDelete, qname, rtype, value)
+}
+
+// ReplaceRecordSet replaces the custom DNS records of the qname and rtype with records of the values, e.g. to set
+// a round-robin A record set or several TXT records. Only the minimal set of changes is applied: missing values
+// are added first, then obsolete records are deleted, so the record set never is empty in between. If a change
+// fails, the changes made so far are rolled back. An empty list of values deletes all records.
+// Returns true if the DNS was updated.
+func ReplaceRecordSet(c *Config, qname string, rtype ResourceType, values []string) (bool, error) {
+	return ReplaceRecordSetContext(context.Background(), c, qname, rtype, values)
+}
+
+// ReplaceRecordSetContext is like ReplaceRecordSet, the requests are canceled with the context. A rollback is not
+// canceled, so the record set is not left half replaced.
+func ReplaceRecordSetContext(ctx context.Context, c *Config, qname string, rtype ResourceType, values []string) (bool, error) {
+
+	if !rtype.IsValid() {
+		return false, errRtypeNotSet
+	}
+	qname, err := c.checkQName(qname, rtype)
+	if err != nil {
+		return false, err
+	}
+
+	var want []string
+	wanted := map[string]bool{}
+	for _, v := range values {
+		if v == "" {
+			return false, fmt.Errorf("'value' must not be empty")
+		}
+		if v, err = c.checkValue(rtype, v); err != nil {
+			return false, err
+		}
+		if key := canonicalValue(rtype, v); !wanted[key] {
+			wanted[key] = true
+			want = append(want, v)
+		}
+	}
+
+	if err := checkVersion(ctx, c, rtype); err != nil {
+		return false, err
+	}
+
+	records, err := GetDnsContext(ctx, c, qname, rtype)
+	if err != nil {
+		return false, err
+	}
+	current := map[string]bool{}
+	var obsolete []string
+	for _, r := range records {
+		if r.RType != rtype || !strings.EqualFold(strings.TrimSuffix(r.QName, "."), qname) {
+			continue
+		}
+		key := canonicalValue(rtype, r.Value)
+		current[key] = true
+		if !wanted[key] {
+			obsolete = append(obsolete, r.Value)
+		}
+	}
 
-	return execDns(c, http.MethodDelete, qname, rtype, value)
+	type change struct {
+		method string
+		value  string
+	}
+	var done []change
+	rollback := func(cause error) error {
+		for i := len(done) - 1; i >= 0; i-- {
+			method := http.MethodDelete
+			if done[i].method == http.MethodDelete {
+				method = http.MethodPost
+			}
+			if _, err := sendDns(context.WithoutCancel(ctx), c, method, qname, rtype, done[i].value); err != nil {
+				return fmt.Errorf("%v (rollback failed: %v)", cause, err)
+			}
+		}
+		return cause
+	}
+
+	for _, v := range want {
+		if current[canonicalValue(rtype, v)] {
+			continue
+		}
+		if _, err := sendDns(ctx, c, http.MethodPost, qname, rtype, v); err != nil {
+			return false, rollback(err)
+		}
+		done = append(done, change{http.MethodPost, v})
+	}
+	for _, v := range obsolete {
+		if _, err := sendDns(ctx, c, http.MethodDelete, qname, rtype, v); err != nil {
+			return false, rollback(err)
+		}
+		done = append(done, change{http.MethodDelete, v})
+	}
+	return len(done) > 0, nil
 }
 
 // SetOrAddAddressRecord sets or adds a custom A or AAAA record of the qname. If the value is empty, the server
@@ -258,36 +504,46 @@ func DeleteDns(c *Config, qname string, rtype ResourceType, value string) (bool,
 // Consider using UpdateDns4 or UpdateDns6 for dynamic DNS!
 // Returns true if the DNS was set or updated.
 func SetOrAddAddressRecord(c *Config, network NetworkType, qname, value string, add bool) (bool, error) {
+	return SetOrAddAddressRecordContext(context.Background(), c, network, qname, value, add)
+}
 
-	if !regexQname.MatchString(qname) {
-		return false, errInvQname
-	}
+// SetOrAddAddressRecordContext is like SetOrAddAddressRecord, the request is canceled with the context.
+func SetOrAddAddressRecordContext(ctx context.Context, c *Config, network NetworkType, qname, value string, add bool) (bool, error) {
 
 	if network != TCP4 && network != TCP6 {
 		return false, errInvNet
 	}
 
-	dialer := &net.Dialer{
-		Timeout:   time.Second * 30,
-		KeepAlive: 0,
-		DualStack: false,
-	}
-
-	tr := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-		return dialer.DialContext(ctx, network, addr)
-	}}
-
 	rtype := A
 	if network == TCP6 {
 		rtype = AAAA
 	}
-	client := &http.Client{Transport: tr}
+
+	qname, err := c.checkQName(qname, rtype)
+	if err != nil {
+		return false, err
+	}
+
+	if value, err = c.checkValue(rtype, value); err != nil {
+		return false, err
+	}
+
+	// the box takes the address of the connection, so the connection has to use the network of the record
+	dialer := &net.Dialer{}
+	tr := c.newTransport()
+	tr.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, string(network), addr)
+	}
+	defer tr.CloseIdleConnections()
+
+	client := c.client()
+	client.Transport = tr
 
 	method := http.MethodPut
 	if add {
 		method = http.MethodPost
 	}
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), strings.NewReader(value))
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s", c.url(), dnsPath(qname, rtype)), strings.NewReader(value))
 	if err != nil {
 		return false, err
 	}
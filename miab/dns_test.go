@@ -2,13 +2,20 @@ package miab
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 )
 
 var testRec1 = Record{
@@ -34,7 +41,11 @@ func basicAuthHeader(username, password string) string {
 }
 
 func getDnsTestServer(t *testing.T, method string, status int, response string, rtype ResourceType, testUri bool, body string) *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return httptest.NewServer(getDnsTestHandler(t, method, status, response, rtype, testUri, body))
+}
+
+func getDnsTestHandler(t *testing.T, method string, status int, response string, rtype ResourceType, testUri bool, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		var err error
 		if r.Header.Get("Authorization") != basicAuthHeader("test", "secret") {
@@ -65,7 +76,35 @@ func getDnsTestServer(t *testing.T, method string, status int, response string,
 			w.WriteHeader(status)
 			_, _ = w.Write([]byte(response))
 		}
+	})
+}
+
+// getDualStackTestServer starts a server listening on IPv4 and IPv6, it fails requests that are not sent over the
+// network. Returns the URL of the server for the network.
+func getDualStackTestServer(t *testing.T, network NetworkType, handler http.Handler) string {
+	l, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		t.Skipf("IPv6 not available: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if (net.ParseIP(host).To4() != nil) != (network == TCP4) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "request from %s", r.RemoteAddr)
+			return
+		}
+		handler.ServeHTTP(w, r)
 	}))
+	_ = ts.Listener.Close()
+	ts.Listener = l
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	host := "127.0.0.1"
+	if network == TCP6 {
+		host = "[::1]"
+	}
+	return fmt.Sprintf("http://%s:%d", host, l.Addr().(*net.TCPAddr).Port)
 }
 
 func TestRecord_String(t *testing.T) {
@@ -91,7 +130,7 @@ func TestRecord_ToString(t *testing.T) {
 		t.Error("Unable to unmarshal generated yaml", err)
 	}
 
-	want := fmt.Sprintf("%s\n\"example.org\", \"A\", \"127.0.0.1\"\n", csvDnsHead)
+	want := "qname,rtype,value\nexample.org,A,127.0.0.1\n"
 	c := testRec1.ToString(CSV)
 	if c != want {
 		t.Errorf("wrong format, want: \n%s\n\ngot:\n%s", want, c)
@@ -125,7 +164,7 @@ func TestRecords_ToString(t *testing.T) {
 		t.Error("Unable to unmarshal generated json", err)
 	}
 
-	expectedCsv := fmt.Sprintf("%s\n\"example.org\", \"A\", \"127.0.0.1\"\n\"example.org\", \"AAAA\", \"::1\"\n", csvDnsHead)
+	expectedCsv := "qname,rtype,value\nexample.org,A,127.0.0.1\nexample.org,AAAA,::1\n"
 	c := testRecs.ToString(CSV)
 	if c != expectedCsv {
 		t.Errorf("wrong format, expected: \n%s\n\ngot:\n%s", expectedCsv, c)
@@ -283,6 +322,38 @@ func TestDeleteDns(t *testing.T) {
 	testSetAddDel(t, "DeleteDns", http.MethodDelete)
 }
 
+func TestDns_Canonical(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		rtype  ResourceType
+		value  string
+		want   string
+	}{
+		{"SetDns MX", http.MethodPut, MX, "10 mail.example.org", "10 mail.example.org."},
+		{"AddDns CAA", http.MethodPost, CAA, "0 ISSUE ca.example.net", `0 issue "ca.example.net"`},
+		{"DeleteDns MX", http.MethodDelete, MX, "10 mail.example.org", "10 mail.example.org"},
+		{"DeleteDns CAA", http.MethodDelete, CAA, "0 ISSUE ca.example.net", "0 ISSUE ca.example.net"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := getDnsTestServer(t, tc.method, 200, "updated DNS:", tc.rtype, true, tc.want)
+			defer ts.Close()
+			c, _ := NewConfig("test", "secret", ts.URL)
+
+			op := map[string]func(*Config, string, ResourceType, string) (bool, error){
+				http.MethodPut:    SetDns,
+				http.MethodPost:   AddDns,
+				http.MethodDelete: DeleteDns,
+			}[tc.method]
+			if ok, err := op(c, "test.example.org", tc.rtype, tc.value); err != nil || !ok {
+				t.Errorf("want: true, got: %v (%v)", ok, err)
+			}
+		})
+	}
+}
+
 func testSetAddDel(t *testing.T, name, httpMethod string) {
 	testCases := []struct {
 		name         string
@@ -298,11 +369,15 @@ func testSetAddDel(t *testing.T, name, httpMethod string) {
 		{fmt.Sprintf("%s OK AAAA", name), "test.example.org", "::1", AAAA, 200, "updated DNS:", true, false},
 		{fmt.Sprintf("%s OK TXT", name), "test.example.org", "FooBar", TXT, 200, "updated DNS:", true, false},
 		{fmt.Sprintf("%s OK CNAME", name), "test.example.org", "foo.example.com.", CNAME, 200, "updated DNS:", true, false},
-		{fmt.Sprintf("%s OK MX", name), "test.example.org", "example.org.", MX, 200, "updated DNS:", true, false},
-		{fmt.Sprintf("%s OK SRV", name), "test.example.org", "_sip._tcp.example.org.", SRV, 200, "updated DNS:", true, false},
-		{fmt.Sprintf("%s OK SSHFP", name), "test.example.org", "SSHFP 2 1 123456789abcdef67890123456789abcdef67890", SSHFP, 200, "updated DNS:", true, false},
+		{fmt.Sprintf("%s OK MX", name), "test.example.org", "10 mail.example.org.", MX, 200, "updated DNS:", true, false},
+		{fmt.Sprintf("%s OK SRV", name), "test.example.org", "10 5 5060 sip.example.org.", SRV, 200, "updated DNS:", true, false},
+		{fmt.Sprintf("%s OK SSHFP", name), "test.example.org", "2 1 123456789abcdef67890123456789abcdef67890", SSHFP, 200, "updated DNS:", true, false},
 		{fmt.Sprintf("%s OK CAA", name), "test.example.org", `0 issue "ca.example.net"`, CAA, 200, "updated DNS:", true, false},
-		{fmt.Sprintf("%s OK NS", name), "test.example.org", "example.org", NS, 200, "updated DNS:", true, false},
+		{fmt.Sprintf("%s OK NS", name), "test.example.org", "ns1.example.org.", NS, 200, "updated DNS:", true, false},
+		{fmt.Sprintf("%s invalid A", name), "test.example.org", "::1", A, 200, "updated DNS:", false, true},
+		{fmt.Sprintf("%s invalid AAAA", name), "test.example.org", "127.0.0.1", AAAA, 200, "updated DNS:", false, true},
+		{fmt.Sprintf("%s invalid MX", name), "test.example.org", "mail.example.org.", MX, 200, "updated DNS:", false, true},
+		{fmt.Sprintf("%s invalid CAA", name), "test.example.org", `0 issues "ca.example.net"`, CAA, 200, "updated DNS:", false, true},
 		{fmt.Sprintf("%s invalid rtype NONE", name), "test.example.org", "127.0.0.1", NONE, 200, "", false, true},
 		{fmt.Sprintf("%s invalid rtype B", name), "test.example.org", "127.0.0.1", NONE, 200, "", false, true},
 		{fmt.Sprintf("%s invalid qname", name), "test%example_org", "127.0.0.1", A, 200, "", false, true},
@@ -428,10 +503,9 @@ func testSetOrAddAddressRecord(t *testing.T, name, method string) {
 			if tc.network == TCP6 {
 				rtype = AAAA
 			}
-			ts := getDnsTestServer(t, method, tc.statusCode, tc.responseBody, rtype, true, tc.value)
-			defer ts.Close()
+			url := getDualStackTestServer(t, tc.network, getDnsTestHandler(t, method, tc.statusCode, tc.responseBody, rtype, true, tc.value))
 
-			c, _ := NewConfig("test", "secret", ts.URL)
+			c, _ := NewConfig("test", "secret", url)
 			got, err := SetOrAddAddressRecord(c, tc.network, tc.qname, tc.value, name == "AddAddressRecord")
 
 			if tc.wantError && err == nil {
@@ -448,6 +522,49 @@ func testSetOrAddAddressRecord(t *testing.T, name, method string) {
 	}
 }
 
+func TestSetOrAddAddressRecordContext(t *testing.T) {
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name    string
+		network NetworkType
+		server  NetworkType
+		handler http.Handler
+		ctx     context.Context
+		options []Option
+	}{
+		{"IPv4 to IPv6 only", TCP4, TCP6, getDnsTestHandler(t, http.MethodPut, 200, "updated DNS:", A, true, ""),
+			context.Background(), nil},
+		{"IPv6 to IPv4 only", TCP6, TCP4, getDnsTestHandler(t, http.MethodPut, 200, "updated DNS:", AAAA, true, ""),
+			context.Background(), nil},
+		{"canceled", TCP4, TCP4, slow, canceled, nil},
+		{"timeout", TCP4, TCP4, slow, context.Background(), []Option{WithTimeout(50 * time.Millisecond)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url := getDualStackTestServer(t, tc.server, tc.handler)
+			c, _ := NewConfig("test", "secret", url, tc.options...)
+
+			start := time.Now()
+			if ok, err := SetOrAddAddressRecordContext(tc.ctx, c, tc.network, "test.example.org", "", false); ok || err == nil {
+				t.Errorf("want error, got: %v, %v", ok, err)
+			}
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("request not aborted, took %v", d)
+			}
+		})
+	}
+}
+
 func TestUpdateDns4(t *testing.T) {
 
 	testCases := []struct {
@@ -506,10 +623,10 @@ func TestUpdateDns6(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 
-			ts := getDnsTestServer(t, http.MethodPut, 200, fmt.Sprintf("updated DNS: %s", tc.value), AAAA, true, tc.value)
-			defer ts.Close()
+			url := getDualStackTestServer(t, TCP6,
+				getDnsTestHandler(t, http.MethodPut, 200, fmt.Sprintf("updated DNS: %s", tc.value), AAAA, true, tc.value))
 
-			c, _ := NewConfig("test", "secret", ts.URL)
+			c, _ := NewConfig("test", "secret", url)
 			got, err := UpdateDns6(c, tc.qname, tc.value)
 
 			if tc.wantError && err == nil {
@@ -525,3 +642,192 @@ func TestUpdateDns6(t *testing.T) {
 		})
 	}
 }
+
+// testDnsBox simulates the custom dns records of a Mail-in-a-Box, requests with a value in failOn fail.
+type testDnsBox struct {
+	mu       sync.Mutex
+	records  Records
+	failOn   map[string]bool
+	requests []string
+}
+
+func (b *testDnsBox) server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		qname, rtype := "test.example.org", ResourceType("A")
+		if r.URL.Path != "/"+dnsPath(qname, rtype) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		value := string(body)
+		if r.Method != http.MethodGet {
+			b.requests = append(b.requests, r.Method+" "+value)
+		}
+		if b.failOn[value] {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad request"))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(b.records)
+			return
+		case http.MethodPost:
+			b.records = append(b.records, Record{QName: qname, RType: rtype, Value: value})
+		case http.MethodDelete:
+			var res Records
+			for _, x := range b.records {
+				if x.Value != value {
+					res = append(res, x)
+				}
+			}
+			b.records = res
+		}
+		_, _ = w.Write([]byte("updated DNS: example.org"))
+	}))
+}
+
+func (b *testDnsBox) values() []string {
+	var res []string
+	for _, r := range b.records {
+		res = append(res, r.Value)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestReplaceRecordSet(t *testing.T) {
+	testCases := []struct {
+		name         string
+		current      []string
+		values       []string
+		failOn       []string
+		want         []string
+		wantRequests []string
+		wantUpdated  bool
+		wantError    bool
+	}{
+		{"unchanged", []string{"127.0.0.1", "127.0.0.2"}, []string{"127.0.0.2", "127.0.0.1"}, nil,
+			[]string{"127.0.0.1", "127.0.0.2"}, nil, false, false},
+		{"add and delete", []string{"127.0.0.1", "127.0.0.2"}, []string{"127.0.0.2", "127.0.0.3"}, nil,
+			[]string{"127.0.0.2", "127.0.0.3"}, []string{"POST 127.0.0.3", "DELETE 127.0.0.1"}, true, false},
+		{"from empty", nil, []string{"127.0.0.1", "127.0.0.2", "127.0.0.1"}, nil,
+			[]string{"127.0.0.1", "127.0.0.2"}, []string{"POST 127.0.0.1", "POST 127.0.0.2"}, true, false},
+		{"delete all", []string{"127.0.0.1", "127.0.0.2"}, nil, nil,
+			nil, []string{"DELETE 127.0.0.1", "DELETE 127.0.0.2"}, true, false},
+		{"rollback add", []string{"127.0.0.1"}, []string{"127.0.0.2", "127.0.0.3"}, []string{"127.0.0.3"},
+			[]string{"127.0.0.1"}, []string{"POST 127.0.0.2", "POST 127.0.0.3", "DELETE 127.0.0.2"}, false, true},
+		{"rollback delete", []string{"127.0.0.1", "127.0.0.2"}, []string{"127.0.0.3"}, []string{"127.0.0.2"},
+			[]string{"127.0.0.1", "127.0.0.2"},
+			[]string{"POST 127.0.0.3", "DELETE 127.0.0.1", "DELETE 127.0.0.2", "POST 127.0.0.1", "DELETE 127.0.0.3"}, false, true},
+		{"invalid value", []string{"127.0.0.1"}, []string{"127.0.0.2", "::1"}, nil,
+			[]string{"127.0.0.1"}, nil, false, true},
+		{"empty value", []string{"127.0.0.1"}, []string{""}, nil,
+			[]string{"127.0.0.1"}, nil, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := &testDnsBox{failOn: map[string]bool{}}
+			for _, v := range tc.current {
+				box.records = append(box.records, Record{QName: "test.example.org", RType: A, Value: v})
+			}
+			for _, v := range tc.failOn {
+				box.failOn[v] = true
+			}
+			ts := box.server()
+			defer ts.Close()
+			c, _ := NewConfig("test", "secret", ts.URL)
+
+			got, err := ReplaceRecordSet(c, "test.example.org", A, tc.values)
+			if (err != nil) != tc.wantError {
+				t.Errorf("want error: %v, got: %v", tc.wantError, err)
+			}
+			if got != tc.wantUpdated {
+				t.Errorf("want: %v, got: %v", tc.wantUpdated, got)
+			}
+			if !equalValues(box.values(), tc.want) {
+				t.Errorf("want records: %v, got: %v", tc.want, box.values())
+			}
+			if !equalValues(box.requests, tc.wantRequests) {
+				t.Errorf("want requests: %v, got: %v", tc.wantRequests, box.requests)
+			}
+		})
+	}
+}
+
+func TestReplaceRecordSetContext(t *testing.T) {
+
+	box := &testDnsBox{failOn: map[string]bool{}, records: Records{{QName: "test.example.org", RType: A, Value: "127.0.0.1"}}}
+	ts := box.server()
+	defer ts.Close()
+
+	// the context is canceled by the second addition, the rollback is sent nevertheless
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	posts := 0
+	handler := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		if r.Method == http.MethodPost {
+			if posts++; posts == 2 {
+				cancel()
+			}
+		}
+	})
+	box.failOn["127.0.0.3"] = true
+	c, _ := NewConfig("test", "secret", ts.URL)
+
+	if _, err := ReplaceRecordSetContext(ctx, c, "test.example.org", A, []string{"127.0.0.2", "127.0.0.3"}); err == nil {
+		t.Error("error expected")
+	}
+	if want := []string{"127.0.0.1"}; !equalValues(box.values(), want) {
+		t.Errorf("want records: %v, got: %v", want, box.values())
+	}
+	if want := []string{"POST 127.0.0.2", "POST 127.0.0.3", "DELETE 127.0.0.2"}; !equalValues(box.requests, want) {
+		t.Errorf("want requests: %v, got: %v", want, box.requests)
+	}
+}
+
+func TestDnsContext_Canceled(t *testing.T) {
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("updated DNS: example.org"))
+	}))
+	defer ts.Close()
+	c, _ := NewConfig("test", "secret", ts.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name string
+		call func() error
+	}{
+		{"GetDnsContext", func() error { _, err := GetDnsContext(ctx, c, "test.example.org", A); return err }},
+		{"SetDnsContext", func() error { _, err := SetDnsContext(ctx, c, "test.example.org", A, "127.0.0.1"); return err }},
+		{"AddDnsContext", func() error { _, err := AddDnsContext(ctx, c, "test.example.org", A, "127.0.0.1"); return err }},
+		{"DeleteDnsContext", func() error { _, err := DeleteDnsContext(ctx, c, "test.example.org", A, ""); return err }},
+		{"ReplaceRecordSetContext", func() error {
+			_, err := ReplaceRecordSetContext(ctx, c, "test.example.org", A, []string{"127.0.0.1"})
+			return err
+		}},
+		{"GetVersionContext", func() error { _, err := GetVersionContext(ctx, c); return err }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("want: %v, got: %v", context.Canceled, err)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Errorf("want no requests, got: %d", requests)
+	}
+}
@@ -0,0 +1,569 @@
+package miab
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RecordValue defines the typed value of a dns record, see ParseRecordValue.
+type RecordValue interface {
+	RType() ResourceType // RType returns the ResourceType of the value.
+	String() string      // String returns the value in the format expected by the Mail-in-a-Box API.
+	Validate() error     // Validate checks if the value is valid.
+}
+
+// ValueError describes an invalid value of a dns record.
+type ValueError struct {
+	RType ResourceType // RType is the ResourceType of the record.
+	Value string       // Value is the invalid value.
+	Err   error        // Err is the reason.
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid %s value '%s': %v", e.RType, e.Value, e.Err)
+}
+
+var valueParsers = map[ResourceType]func(string) (RecordValue, error){
+	A:     func(s string) (RecordValue, error) { return parseIPValue(A, s) },
+	AAAA:  func(s string) (RecordValue, error) { return parseIPValue(AAAA, s) },
+	MX:    parseMXValue,
+	SRV:   parseSRVValue,
+	CAA:   parseCAAValue,
+	SSHFP: parseSSHFPValue,
+	TXT:   parseTXTValue,
+	CNAME: func(s string) (RecordValue, error) { return parseHostValue(CNAME, s) },
+	NS:    func(s string) (RecordValue, error) { return parseHostValue(NS, s) },
+}
+
+var valueSyntax = map[ResourceType]string{
+	A:     "<IPv4 address>, e.g. 192.0.2.1",
+	AAAA:  "<IPv6 address>, e.g. 2001:db8::1",
+	MX:    "<priority> <host>, e.g. 10 mail.example.org.",
+	SRV:   "<priority> <weight> <port> <target>, e.g. 10 5 5060 sip.example.org.",
+	CAA:   `<flags> <tag> "<value>", e.g. 0 issue "letsencrypt.org"`,
+	SSHFP: "<algorithm> <fingerprint type> <fingerprint (hex)>, e.g. 4 2 123456789abcdef...",
+	TXT:   "<text>, e.g. v=spf1 mx -all",
+	CNAME: "<host>, e.g. www.example.org.",
+	NS:    "<host>, e.g. ns1.example.org.",
+	TLSA:  "<usage> <selector> <matching type> <data (hex)>, e.g. 3 1 1 0123456789abcdef...",
+	DS:    "<key tag> <algorithm> <digest type> <digest (hex)>, e.g. 12345 13 2 0123456789abcdef...",
+}
+
+// extraValueParsers are the parsers of the resource types that have to be registered, see RegisterResourceType.
+var extraValueParsers = map[ResourceType]func(string) (RecordValue, error){
+	TLSA: parseTLSAValue,
+	DS:   parseDSValue,
+}
+
+// textTypes are the resource types holding free text, their values are kept verbatim since leading and trailing
+// white space is significant.
+var textTypes = map[ResourceType]bool{TXT: true, ResourceType("SPF"): true}
+
+// ParseRecordValue parses the value of a record of the ResourceType and validates it.
+// The String method of the result returns the value in canonical form (e.g. with a trailing dot on host names).
+// Leading and trailing white space is ignored, except for the text of TXT (and SPF) records.
+func ParseRecordValue(rtype ResourceType, value string) (RecordValue, error) {
+	rtypesMu.RLock()
+	parse, ok := valueParsers[rtype]
+	rtypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a valid resource type", rtype)
+	}
+	s := value
+	if !textTypes[rtype] {
+		s = strings.TrimSpace(value)
+	}
+	v, err := parse(s)
+	if err == nil {
+		err = v.Validate()
+	}
+	if err != nil {
+		return nil, &ValueError{RType: rtype, Value: value, Err: err}
+	}
+	return v, nil
+}
+
+// ValueSyntax returns a description of the value syntax of the ResourceType, with an example.
+func ValueSyntax(rtype ResourceType) string {
+	return valueSyntax[rtype]
+}
+
+// ParseValue parses the Value of the Record, see ParseRecordValue.
+func (r Record) ParseValue() (RecordValue, error) {
+	return ParseRecordValue(r.RType, r.Value)
+}
+
+// AValue defines the value of an A record.
+type AValue struct {
+	IP net.IP // IP is the IPv4 address.
+}
+
+// RType returns A.
+func (v AValue) RType() ResourceType { return A }
+
+// String returns the IPv4 address.
+func (v AValue) String() string { return v.IP.String() }
+
+// Validate checks if the IP is an IPv4 address.
+func (v AValue) Validate() error {
+	if v.IP.To4() == nil {
+		return fmt.Errorf("not an IPv4 address")
+	}
+	return nil
+}
+
+// AAAAValue defines the value of an AAAA record.
+type AAAAValue struct {
+	IP net.IP // IP is the IPv6 address.
+}
+
+// RType returns AAAA.
+func (v AAAAValue) RType() ResourceType { return AAAA }
+
+// String returns the IPv6 address.
+func (v AAAAValue) String() string { return v.IP.String() }
+
+// Validate checks if the IP is an IPv6 address.
+func (v AAAAValue) Validate() error {
+	if len(v.IP) != net.IPv6len || v.IP.To4() != nil {
+		return fmt.Errorf("not an IPv6 address")
+	}
+	return nil
+}
+
+func parseIPValue(rtype ResourceType, s string) (RecordValue, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP address")
+	}
+	if rtype == A {
+		if strings.Contains(s, ":") {
+			return nil, fmt.Errorf("not an IPv4 address")
+		}
+		return AValue{IP: ip.To4()}, nil
+	}
+	if !strings.Contains(s, ":") {
+		return nil, fmt.Errorf("not an IPv6 address")
+	}
+	return AAAAValue{IP: ip}, nil
+}
+
+// MXValue defines the value of a MX record.
+type MXValue struct {
+	Priority uint16 // Priority is the preference of the mail exchange, lower values are preferred.
+	Host     string // Host is the mail exchange, `.` for a null MX (RFC 7505).
+}
+
+// RType returns MX.
+func (v MXValue) RType() ResourceType { return MX }
+
+// String returns the value as `<priority> <host>`.
+func (v MXValue) String() string { return fmt.Sprintf("%d %s", v.Priority, fqdn(v.Host)) }
+
+// Validate checks if the Host is a valid host name.
+func (v MXValue) Validate() error {
+	if v.Host != "." && !isHostname(v.Host) {
+		return fmt.Errorf("'%s' is not a valid host name", v.Host)
+	}
+	return nil
+}
+
+func parseMXValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return nil, fmt.Errorf("priority and host expected")
+	}
+	p, err := parseUint(f[0], "priority", 16)
+	if err != nil {
+		return nil, err
+	}
+	host, err := ToASCII(f[1])
+	if err != nil {
+		return nil, err
+	}
+	return MXValue{Priority: uint16(p), Host: host}, nil
+}
+
+// SRVValue defines the value of a SRV record.
+type SRVValue struct {
+	Priority uint16 // Priority of the target, lower values are preferred.
+	Weight   uint16 // Weight of targets with the same priority.
+	Port     uint16 // Port of the service.
+	Target   string // Target is the host providing the service, `.` if the service is not available.
+}
+
+// RType returns SRV.
+func (v SRVValue) RType() ResourceType { return SRV }
+
+// String returns the value as `<priority> <weight> <port> <target>`.
+func (v SRVValue) String() string {
+	return fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, fqdn(v.Target))
+}
+
+// Validate checks if the Target is a valid host name.
+func (v SRVValue) Validate() error {
+	if v.Target != "." && !isHostname(v.Target) {
+		return fmt.Errorf("'%s' is not a valid host name", v.Target)
+	}
+	return nil
+}
+
+func parseSRVValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) != 4 {
+		return nil, fmt.Errorf("priority, weight, port and target expected")
+	}
+	var n [3]uint64
+	for i, name := range []string{"priority", "weight", "port"} {
+		var err error
+		if n[i], err = parseUint(f[i], name, 16); err != nil {
+			return nil, err
+		}
+	}
+	target, err := ToASCII(f[3])
+	if err != nil {
+		return nil, err
+	}
+	return SRVValue{Priority: uint16(n[0]), Weight: uint16(n[1]), Port: uint16(n[2]), Target: target}, nil
+}
+
+// CAAValue defines the value of a CAA record.
+type CAAValue struct {
+	Flags uint8  // Flags of the record, 128 marks the record as critical.
+	Tag   string // Tag is the property, one of `issue`, `issuewild` or `iodef`.
+	Value string // Value of the property (without quotes).
+}
+
+// RType returns CAA.
+func (v CAAValue) RType() ResourceType { return CAA }
+
+// String returns the value as `<flags> <tag> "<value>"`.
+func (v CAAValue) String() string {
+	return fmt.Sprintf(`%d %s "%s"`, v.Flags, v.Tag, strings.Replace(v.Value, `"`, `\"`, -1))
+}
+
+// Validate checks the Tag and the Value of the property.
+func (v CAAValue) Validate() error {
+	switch v.Tag {
+	case "issue", "issuewild":
+		domain := strings.TrimSpace(strings.SplitN(v.Value, ";", 2)[0])
+		if domain != "" && !isHostname(domain) {
+			return fmt.Errorf("'%s' is not a valid issuer domain", domain)
+		}
+	case "iodef":
+		u, err := url.Parse(v.Value)
+		if err != nil || (u.Scheme != "mailto" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("iodef has to be a mailto, http or https URL")
+		}
+	default:
+		return fmt.Errorf("tag has to be issue, issuewild or iodef")
+	}
+	return nil
+}
+
+func parseCAAValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) < 3 {
+		return nil, fmt.Errorf("flags, tag and value expected")
+	}
+	flags, err := parseUint(f[0], "flags", 8)
+	if err != nil {
+		return nil, err
+	}
+	// the value may contain white space, so only the flags and the tag are cut off
+	value := strings.TrimSpace(s)
+	for _, x := range f[:2] {
+		value = strings.TrimSpace(strings.TrimPrefix(value, x))
+	}
+	if strings.HasPrefix(value, `"`) {
+		if value, err = strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("value is not quoted properly")
+		}
+	}
+	return CAAValue{Flags: uint8(flags), Tag: strings.ToLower(f[1]), Value: value}, nil
+}
+
+// SSHFPValue defines the value of a SSHFP record.
+type SSHFPValue struct {
+	Algorithm   uint8  // Algorithm of the key: 1 (RSA), 2 (DSA), 3 (ECDSA), 4 (Ed25519) or 6 (Ed448).
+	Type        uint8  // Type of the fingerprint: 1 (SHA-1) or 2 (SHA-256).
+	Fingerprint string // Fingerprint is the hex encoded fingerprint of the key.
+}
+
+// RType returns SSHFP.
+func (v SSHFPValue) RType() ResourceType { return SSHFP }
+
+// String returns the value as `<algorithm> <type> <fingerprint>`.
+func (v SSHFPValue) String() string {
+	return fmt.Sprintf("%d %d %s", v.Algorithm, v.Type, strings.ToLower(v.Fingerprint))
+}
+
+// Validate checks the Algorithm, the Type and the length of the Fingerprint.
+func (v SSHFPValue) Validate() error {
+	switch v.Algorithm {
+	case 1, 2, 3, 4, 6:
+	default:
+		return fmt.Errorf("algorithm has to be 1 (RSA), 2 (DSA), 3 (ECDSA), 4 (Ed25519) or 6 (Ed448)")
+	}
+
+	b, err := hex.DecodeString(v.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("fingerprint is not hex encoded")
+	}
+	switch v.Type {
+	case 1:
+		if len(b) != 20 {
+			return fmt.Errorf("SHA-1 fingerprint has to be 20 bytes long")
+		}
+	case 2:
+		if len(b) != 32 {
+			return fmt.Errorf("SHA-256 fingerprint has to be 32 bytes long")
+		}
+	default:
+		return fmt.Errorf("fingerprint type has to be 1 (SHA-1) or 2 (SHA-256)")
+	}
+	return nil
+}
+
+func parseSSHFPValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) != 3 {
+		return nil, fmt.Errorf("algorithm, fingerprint type and fingerprint expected")
+	}
+	alg, err := parseUint(f[0], "algorithm", 8)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := parseUint(f[1], "fingerprint type", 8)
+	if err != nil {
+		return nil, err
+	}
+	return SSHFPValue{Algorithm: uint8(alg), Type: uint8(typ), Fingerprint: f[2]}, nil
+}
+
+// TXTValue defines the value of a TXT record.
+type TXTValue struct {
+	Text string // Text of the record.
+}
+
+// RType returns TXT.
+func (v TXTValue) RType() ResourceType { return TXT }
+
+// String returns the Text.
+func (v TXTValue) String() string { return v.Text }
+
+// Validate checks if the Text is not empty or blank.
+func (v TXTValue) Validate() error {
+	if strings.TrimSpace(v.Text) == "" {
+		return fmt.Errorf("text is empty")
+	}
+	return nil
+}
+
+func parseTXTValue(s string) (RecordValue, error) {
+	return TXTValue{Text: s}, nil
+}
+
+// CNAMEValue defines the value of a CNAME record.
+type CNAMEValue struct {
+	Target string // Target is the canonical name.
+}
+
+// RType returns CNAME.
+func (v CNAMEValue) RType() ResourceType { return CNAME }
+
+// String returns the Target with a trailing dot.
+func (v CNAMEValue) String() string { return fqdn(v.Target) }
+
+// Validate checks if the Target is a valid host name.
+func (v CNAMEValue) Validate() error {
+	if !isHostname(v.Target) {
+		return fmt.Errorf("'%s' is not a valid host name", v.Target)
+	}
+	return nil
+}
+
+// NSValue defines the value of a NS record.
+type NSValue struct {
+	Host string // Host is the name server.
+}
+
+// RType returns NS.
+func (v NSValue) RType() ResourceType { return NS }
+
+// String returns the Host with a trailing dot.
+func (v NSValue) String() string { return fqdn(v.Host) }
+
+// Validate checks if the Host is a valid host name.
+func (v NSValue) Validate() error {
+	if !isHostname(v.Host) {
+		return fmt.Errorf("'%s' is not a valid host name", v.Host)
+	}
+	return nil
+}
+
+func parseHostValue(rtype ResourceType, s string) (RecordValue, error) {
+	if strings.ContainsAny(s, " \t") {
+		return nil, fmt.Errorf("a single host name expected")
+	}
+	host, err := ToASCII(s)
+	if err != nil {
+		return nil, err
+	}
+	if rtype == NS {
+		return NSValue{Host: host}, nil
+	}
+	return CNAMEValue{Target: host}, nil
+}
+
+// TLSAValue defines the value of a TLSA record.
+type TLSAValue struct {
+	Usage        uint8  // Usage of the certificate: 0 (PKIX-TA), 1 (PKIX-EE), 2 (DANE-TA) or 3 (DANE-EE).
+	Selector     uint8  // Selector of the data: 0 (full certificate) or 1 (public key).
+	MatchingType uint8  // MatchingType of the data: 0 (exact match), 1 (SHA-256) or 2 (SHA-512).
+	Data         string // Data is the hex encoded certificate association data.
+}
+
+// RType returns TLSA.
+func (v TLSAValue) RType() ResourceType { return TLSA }
+
+// String returns the value as `<usage> <selector> <matching type> <data>`.
+func (v TLSAValue) String() string {
+	return fmt.Sprintf("%d %d %d %s", v.Usage, v.Selector, v.MatchingType, strings.ToLower(v.Data))
+}
+
+// Validate checks the Usage, the Selector, the MatchingType and the length of the Data.
+func (v TLSAValue) Validate() error {
+	if v.Usage > 3 {
+		return fmt.Errorf("usage has to be 0, 1, 2 or 3")
+	}
+	if v.Selector > 1 {
+		return fmt.Errorf("selector has to be 0 or 1")
+	}
+	b, err := hex.DecodeString(v.Data)
+	if err != nil || len(b) == 0 {
+		return fmt.Errorf("data is not hex encoded")
+	}
+	switch v.MatchingType {
+	case 0:
+	case 1:
+		if len(b) != 32 {
+			return fmt.Errorf("SHA-256 data has to be 32 bytes long")
+		}
+	case 2:
+		if len(b) != 64 {
+			return fmt.Errorf("SHA-512 data has to be 64 bytes long")
+		}
+	default:
+		return fmt.Errorf("matching type has to be 0, 1 or 2")
+	}
+	return nil
+}
+
+func parseTLSAValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) != 4 {
+		return nil, fmt.Errorf("usage, selector, matching type and data expected")
+	}
+	var n [3]uint64
+	for i, name := range []string{"usage", "selector", "matching type"} {
+		var err error
+		if n[i], err = parseUint(f[i], name, 8); err != nil {
+			return nil, err
+		}
+	}
+	return TLSAValue{Usage: uint8(n[0]), Selector: uint8(n[1]), MatchingType: uint8(n[2]), Data: f[3]}, nil
+}
+
+// DSValue defines the value of a DS record.
+type DSValue struct {
+	KeyTag     uint16 // KeyTag of the referenced DNSKEY.
+	Algorithm  uint8  // Algorithm of the referenced DNSKEY, e.g. 8 (RSA/SHA-256) or 13 (ECDSA P-256).
+	DigestType uint8  // DigestType of the Digest: 1 (SHA-1), 2 (SHA-256) or 4 (SHA-384).
+	Digest     string // Digest is the hex encoded digest of the referenced DNSKEY.
+}
+
+// RType returns DS.
+func (v DSValue) RType() ResourceType { return DS }
+
+// String returns the value as `<key tag> <algorithm> <digest type> <digest>`.
+func (v DSValue) String() string {
+	return fmt.Sprintf("%d %d %d %s", v.KeyTag, v.Algorithm, v.DigestType, strings.ToLower(v.Digest))
+}
+
+// Validate checks the Algorithm, the DigestType and the length of the Digest.
+func (v DSValue) Validate() error {
+	switch v.Algorithm {
+	case 5, 7, 8, 10, 13, 14, 15, 16:
+	default:
+		return fmt.Errorf("algorithm has to be 5, 7, 8, 10, 13, 14, 15 or 16")
+	}
+
+	b, err := hex.DecodeString(v.Digest)
+	if err != nil {
+		return fmt.Errorf("digest is not hex encoded")
+	}
+	switch v.DigestType {
+	case 1:
+		if len(b) != 20 {
+			return fmt.Errorf("SHA-1 digest has to be 20 bytes long")
+		}
+	case 2:
+		if len(b) != 32 {
+			return fmt.Errorf("SHA-256 digest has to be 32 bytes long")
+		}
+	case 4:
+		if len(b) != 48 {
+			return fmt.Errorf("SHA-384 digest has to be 48 bytes long")
+		}
+	default:
+		return fmt.Errorf("digest type has to be 1 (SHA-1), 2 (SHA-256) or 4 (SHA-384)")
+	}
+	return nil
+}
+
+func parseDSValue(s string) (RecordValue, error) {
+	f := strings.Fields(s)
+	if len(f) != 4 {
+		return nil, fmt.Errorf("key tag, algorithm, digest type and digest expected")
+	}
+	tag, err := parseUint(f[0], "key tag", 16)
+	if err != nil {
+		return nil, err
+	}
+	alg, err := parseUint(f[1], "algorithm", 8)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := parseUint(f[2], "digest type", 8)
+	if err != nil {
+		return nil, err
+	}
+	return DSValue{KeyTag: uint16(tag), Algorithm: uint8(alg), DigestType: uint8(typ), Digest: f[3]}, nil
+}
+
+func parseUint(s, name string, bitSize int) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s has to be a number between 0 and %d", name, uint64(1)<<uint(bitSize)-1)
+	}
+	return n, nil
+}
+
+// fqdn returns the host name with a trailing dot.
+func fqdn(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
+
+// isHostname checks if s is a valid host name (see ValidateHostname), underscores are allowed and an optional
+// trailing dot.
+func isHostname(s string) bool {
+	return ValidateHostname(s, true) == nil
+}
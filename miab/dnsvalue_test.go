@@ -0,0 +1,124 @@
+package miab
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseRecordValue(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rtype     ResourceType
+		value     string
+		want      string
+		wantError bool
+	}{
+		{"A", A, "127.0.0.1", "127.0.0.1", false},
+		{"A IPv6", A, "::1", "", true},
+		{"A mapped IPv6", A, "::ffff:127.0.0.1", "", true},
+		{"A invalid", A, "127.0.0.256", "", true},
+		{"AAAA", AAAA, "2001:0db8:0000::0001", "2001:db8::1", false},
+		{"AAAA mapped IPv4", AAAA, "::ffff:127.0.0.1", "", true},
+		{"AAAA IPv4", AAAA, "127.0.0.1", "", true},
+		{"MX", MX, "10 mail.example.org", "10 mail.example.org.", false},
+		{"MX null", MX, "0 .", "0 .", false},
+		{"MX no priority", MX, "mail.example.org.", "", true},
+		{"MX invalid priority", MX, "65536 mail.example.org.", "", true},
+		{"MX invalid host", MX, "10 mail_example.org!", "", true},
+		{"SRV", SRV, "10  5 5060 sip.example.org.", "10 5 5060 sip.example.org.", false},
+		{"SRV missing port", SRV, "10 5 sip.example.org.", "", true},
+		{"SRV invalid weight", SRV, "10 -5 5060 sip.example.org.", "", true},
+		{"CAA", CAA, `0 issue "letsencrypt.org"`, `0 issue "letsencrypt.org"`, false},
+		{"CAA unquoted", CAA, `128 ISSUEWILD ;`, `128 issuewild ";"`, false},
+		{"CAA iodef", CAA, `0 iodef "mailto:security@example.org"`, `0 iodef "mailto:security@example.org"`, false},
+		{"CAA white space", CAA, "0\tissue  \"ca.example.net; account=a b\" ", `0 issue "ca.example.net; account=a b"`, false},
+		{"CAA unquoted white space", CAA, "0  issue\tca.example.net;  x=1", `0 issue "ca.example.net;  x=1"`, false},
+		{"CAA invalid iodef", CAA, `0 iodef "security@example.org"`, "", true},
+		{"CAA invalid tag", CAA, `0 issues "letsencrypt.org"`, "", true},
+		{"CAA invalid flags", CAA, `256 issue "letsencrypt.org"`, "", true},
+		{"CAA invalid quotes", CAA, `0 issue "letsencrypt.org`, "", true},
+		{"SSHFP SHA-1", SSHFP, "2 1 123456789ABCDEF67890123456789ABCDEF67890", "2 1 123456789abcdef67890123456789abcdef67890", false},
+		{"SSHFP SHA-256", SSHFP, "4 2 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			"4 2 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", false},
+		{"SSHFP invalid algorithm", SSHFP, "5 1 123456789abcdef67890123456789abcdef67890", "", true},
+		{"SSHFP invalid type", SSHFP, "2 3 123456789abcdef67890123456789abcdef67890", "", true},
+		{"SSHFP invalid length", SSHFP, "2 2 123456789abcdef67890123456789abcdef67890", "", true},
+		{"SSHFP not hex", SSHFP, "2 1 123456789abcdef67890123456789abcdef6789x", "", true},
+		{"TXT", TXT, `v=DKIM1; k=rsa; p=MIIB"`, `v=DKIM1; k=rsa; p=MIIB"`, false},
+		{"TXT empty", TXT, " ", "", true},
+		{"TXT white space", TXT, " v=spf1 -all\t", " v=spf1 -all\t", false},
+		{"A white space", A, " 127.0.0.1\n", "127.0.0.1", false},
+		{"CNAME", CNAME, "foo.example.com", "foo.example.com.", false},
+		{"CNAME invalid", CNAME, "foo..example.com", "", true},
+		{"CNAME two hosts", CNAME, "foo.example.com bar.example.com", "", true},
+		{"NS", NS, "ns1.example.org.", "ns1.example.org.", false},
+		{"NS invalid", NS, "-ns1.example.org.", "", true},
+		{"invalid rtype", NONE, "127.0.0.1", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRecordValue(tc.rtype, tc.value)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("want error, got: %v", got)
+				}
+				if _, ok := err.(*ValueError); !ok && tc.rtype != NONE {
+					t.Errorf("want ValueError, got: %T", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got.String())
+			}
+			if got.RType() != tc.rtype {
+				t.Errorf("want: %s, got: %s", tc.rtype, got.RType())
+			}
+
+			again, err := ParseRecordValue(tc.rtype, got.String())
+			if err != nil || again.String() != got.String() {
+				t.Errorf("round-trip failed, want: %v, got: %v (%v)", got, again, err)
+			}
+		})
+	}
+}
+
+func TestRecordValue_Validate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		value     RecordValue
+		wantError bool
+	}{
+		{"A", AValue{IP: net.ParseIP("127.0.0.1")}, false},
+		{"A nil", AValue{}, true},
+		{"AAAA IPv4", AAAAValue{IP: net.ParseIP("127.0.0.1")}, true},
+		{"MX", MXValue{Priority: 10, Host: "mail.example.org"}, false},
+		{"SRV", SRVValue{Target: ""}, true},
+		{"CAA", CAAValue{Tag: "issue"}, false},
+		{"SSHFP", SSHFPValue{Algorithm: 4, Type: 2}, true},
+		{"TXT", TXTValue{}, true},
+		{"CNAME", CNAMEValue{Target: "www.example.org"}, false},
+		{"NS", NSValue{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.value.Validate(); (err != nil) != tc.wantError {
+				t.Errorf("want error: %v, got: %v", tc.wantError, err)
+			}
+		})
+	}
+}
+
+func TestRecord_ParseValue(t *testing.T) {
+	v, err := Record{QName: "example.org", RType: MX, Value: "10 mail.example.org."}.ParseValue()
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if mx, ok := v.(MXValue); !ok || mx.Priority != 10 || mx.Host != "mail.example.org." {
+		t.Errorf("unexpected value: %#v", v)
+	}
+}
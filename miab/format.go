@@ -1,58 +1,193 @@
 package miab
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/pelletier/go-toml"
 	"gopkg.in/yaml.v3"
+	"io"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 )
 
 // Format defines an output format (e.g. `json`, `yaml`, ...)
 type Format string
 
-const (
-	csvDnsHead   = `"domain name", "record type", "value"`
-	csvUserHead  = `"domain", "email", "privileges", "Status", "mailbox"`
-	csvAliasHead = `"domain", address", "displayAddress", "forwardsTo", "permittedSenders", "required"`
-)
-
 // JSON - output in json format
 const JSON = Format(`json`)
 
 // YAML - output in yaml format
 const YAML = Format(`yaml`)
 
-// CSV - output in csv format, comma separated
+// CSV - output in csv format (RFC 4180), comma separated with a header row
 const CSV = Format(`csv`)
 
+// NDJSON - output in newline delimited json format, one User, Alias, Record or StatusCheck per line
+const NDJSON = Format(`ndjson`)
+
+// TOML - output in toml format
+const TOML = Format(`toml`)
+
+// MARKDOWN - output as markdown table with a header row
+const MARKDOWN = Format(`markdown`)
+
+// HTML - output as html document with a table, the argument (e.g. `html=Weekly report`) sets the title
+const HTML = Format(`html`)
+
 // PLAIN - output as plain text
 const PLAIN = Format(`plain`)
 
+// TABLE - output as aligned table with headers, see TableFormat
+const TABLE = Format(`table`)
+
+// TEMPLATE - output every item (User, Alias, Record or StatusCheck) formatted by a Go template, see TemplateFormat
+const TEMPLATE = Format(`template`)
+
+// JSONPATH - output the result of a JSONPath template applied to the json output, see JSONPathFormat
+const JSONPATH = Format(`jsonpath`)
+
+// TemplateFormat returns a Format that outputs every item (User, Alias, Record or StatusCheck) formatted by
+// the Go template (see https://golang.org/pkg/text/template), one item per line, e.g. `{{.Email}}`. Users and
+// aliases provide the field Domain in addition to their own fields, the functions join, upper and lower are
+// available.
+func TemplateFormat(tmpl string) Format {
+	return Format(fmt.Sprintf("%s=%s", TEMPLATE, tmpl))
+}
+
+// JSONPathFormat returns a Format that outputs the result of the JSONPath template applied to the json output,
+// e.g. `{.[*].users[*].email}` (see https://kubernetes.io/docs/reference/kubectl/jsonpath).
+func JSONPathFormat(tmpl string) Format {
+	return Format(fmt.Sprintf("%s=%s", JSONPATH, tmpl))
+}
+
+// Name returns the name of the Format without its argument, e.g. `template` for `template={{.Email}}`.
+func (f Format) Name() Format {
+	return Format(strings.SplitN(string(f), "=", 2)[0])
+}
+
+// Arg returns the argument of the Format, e.g. `{{.Email}}` for `template={{.Email}}`.
+func (f Format) Arg() string {
+	if s := strings.SplitN(string(f), "=", 2); len(s) == 2 {
+		return s[1]
+	}
+	return ""
+}
+
+// Encoder writes v (e.g. Records, MailDomains, AliasDomains, StatusChecks or a single item of them) to w,
+// arg is the argument of the Format (see Format.Arg).
+type Encoder func(w io.Writer, v interface{}, arg string) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[Format]Encoder{
+		JSON:     stringEncoder(marshallJson),
+		YAML:     stringEncoder(marshallYaml),
+		CSV:      stringEncoder(marshallCsv),
+		NDJSON:   stringEncoder(marshallNdjson),
+		TOML:     stringEncoder(marshallToml),
+		MARKDOWN: stringEncoder(marshallMarkdown),
+		HTML:     argEncoder(marshallHtml),
+		PLAIN:    stringEncoder(marshallPlain),
+		TABLE:    argEncoder(marshallTable),
+		TEMPLATE: argEncoder(marshallTemplate),
+		JSONPATH: argEncoder(marshallJsonPath),
+	}
+)
+
+// RegisterFormat registers the Encoder for the Format name (without argument), an already registered
+// Encoder of the name is replaced. The Format can then be used with ToString and Encode of all types.
+func RegisterFormat(name Format, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	if encoder == nil {
+		delete(encoders, name)
+		return
+	}
+	encoders[name] = encoder
+}
+
+// Formats returns the names of the registered Formats, sorted.
+func Formats() []Format {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	var res []Format
+	for f := range encoders {
+		res = append(res, f)
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a] < res[b] })
+	return res
+}
+
+func stringEncoder(f func(interface{}) (string, error)) Encoder {
+	return func(w io.Writer, v interface{}, arg string) error {
+		s, err := f(v)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, s)
+		return err
+	}
+}
+
+func argEncoder(f func(interface{}, string) (string, error)) Encoder {
+	return func(w io.Writer, v interface{}, arg string) error {
+		s, err := f(v, arg)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, s)
+		return err
+	}
+}
+
+// encode writes i in the provided Format to w, an empty Format is PLAIN.
+func encode(w io.Writer, i interface{}, format Format) error {
+	name := format.Name()
+	if name == "" {
+		name = PLAIN
+	}
+	encodersMu.RLock()
+	e, ok := encoders[name]
+	encodersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w '%s'", errUnsupportedFormat, name)
+	}
+	return e(w, i, format.Arg())
+}
+
+var errUnsupportedFormat = errors.New("unsupported format")
+
+// stringOf is used by the ToString methods, an unsupported format falls back to PLAIN and the text of any other
+// error is returned instead of the output.
+func stringOf(i interface{}, format Format) string {
+	s, err := toString(i, format)
+	if errors.Is(err, errUnsupportedFormat) {
+		s, err = toString(i, PLAIN)
+	}
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return s
+}
+
 func toString(i interface{}, format Format) (string, error) {
-	switch format {
-	case JSON:
-		return marshallJson(i)
-	case YAML:
-		return marshallYaml(i)
-	case CSV:
-		return marshallCsv(i)
+	r := strings.Builder{}
+	if err := encode(&r, i, format); err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+func marshallPlain(i interface{}) (string, error) {
+	switch x := i.(type) {
+	case fmt.Stringer:
+		return x.String(), nil
 	default:
-		switch i.(type) {
-		case AliasDomains:
-			return i.(AliasDomains).String(), nil
-		case AliasDomain:
-			return i.(AliasDomain).String(), nil
-		case Records:
-			return i.(Records).String(), nil
-		case Record:
-			return i.(Record).String(), nil
-		case MailDomains:
-			return i.(MailDomains).String(), nil
-		case MailDomain:
-			return i.(MailDomain).String(), nil
-		default:
-			return fmt.Sprint(i), nil
-		}
+		return "", fmt.Errorf("unsupported type")
 	}
 }
 
@@ -72,74 +207,176 @@ func marshallYaml(i interface{}) (string, error) {
 	return string(r), nil
 }
 
-func marshallCsv(i interface{}) (string, error) {
+func marshallNdjson(i interface{}) (string, error) {
+	list, err := items(i)
+	if err != nil {
+		return "", err
+	}
 
 	r := strings.Builder{}
+	e := json.NewEncoder(&r)
+	for _, item := range list {
+		if err := e.Encode(item); err != nil {
+			return "", err
+		}
+	}
+	return r.String(), nil
+}
+
+// tomlKey returns the key of the list in the toml output, a toml document can't be an array.
+func tomlKey(i interface{}) string {
 	switch i.(type) {
-	case AliasDomains, AliasDomain:
-		r.WriteString(csvAliasHead)
-	case MailDomains, MailDomain:
-		r.WriteString(csvUserHead)
-	case Records, Record:
-		r.WriteString(csvDnsHead)
-	default:
+	case AliasDomains, MailDomains:
+		return "domains"
+	case Records:
+		return "records"
+	case StatusChecks:
+		return "checks"
+	}
+	return ""
+}
+
+func marshallToml(i interface{}) (string, error) {
+	if _, err := items(i); err != nil {
+		return "", err
+	}
+
+	// the json representation is used, so the keys are the same as in the json and yaml output
+	b, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", err
+	}
+	if key := tomlKey(i); key != "" {
+		data = map[string]interface{}{key: data}
+	}
+
+	m, ok := withoutNull(data).(map[string]interface{})
+	if !ok {
 		return "", fmt.Errorf("unsupported type")
 	}
+	t, err := toml.TreeFromMap(m)
+	if err != nil {
+		return "", err
+	}
+	return t.ToTomlString()
+}
 
-	r.WriteByte('\n')
+// withoutNull removes null values from the decoded json, toml has no representation for them.
+func withoutNull(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			if e == nil {
+				delete(x, k)
+			} else {
+				x[k] = withoutNull(e)
+			}
+		}
+	case []interface{}:
+		for n := range x {
+			x[n] = withoutNull(x[n])
+		}
+	}
+	return v
+}
 
-	switch i.(type) {
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// items returns the single items (User, Alias, Record or StatusCheck) of i.
+func items(i interface{}) ([]interface{}, error) {
+	var res []interface{}
+	switch x := i.(type) {
 	case AliasDomains:
-		for _, a := range i.(AliasDomains) {
-			csvAliasDomain(a, &r)
+		for _, a := range x {
+			r, _ := items(a)
+			res = append(res, r...)
 		}
 	case AliasDomain:
-		csvAliasDomain(i.(AliasDomain), &r)
+		for _, a := range x.Aliases {
+			res = append(res, struct {
+				Domain string `json:"domain" yaml:"domain"`
+				Alias
+			}{x.Domain, a})
+		}
 	case MailDomains:
-		for _, m := range i.(MailDomains) {
-			csvMailDomain(m, &r)
+		for _, m := range x {
+			r, _ := items(m)
+			res = append(res, r...)
 		}
 	case MailDomain:
-		csvMailDomain(i.(MailDomain), &r)
+		for _, u := range x.Users {
+			res = append(res, struct {
+				Domain string `json:"domain" yaml:"domain"`
+				User
+			}{x.Domain, u})
+		}
 	case Records:
-		for _, x := range i.(Records) {
-			csvRecord(x, &r)
+		for _, r := range x {
+			res = append(res, r)
 		}
 	case Record:
-		csvRecord(i.(Record), &r)
+		res = append(res, x)
+	case StatusChecks:
+		for _, c := range x {
+			res = append(res, c)
+		}
+	case StatusCheck:
+		res = append(res, x)
+	default:
+		return nil, fmt.Errorf("unsupported type")
 	}
-	return r.String(), nil
+	return res, nil
 }
 
-func csvAliasDomain(a AliasDomain, r *strings.Builder) {
-	for _, x := range a.Aliases {
-		r.WriteString(fmt.Sprintf(`"%s", "%s", "%s", "%s", "%s", %v`, a.Domain, x.Address, x.DisplayAddress, strings.Join(x.ForwardsTo, ";"), strings.Join(x.PermittedSenders, ";"), x.Required))
-		r.WriteByte('\n')
+func marshallTemplate(i interface{}, tmpl string) (string, error) {
+	t, err := template.New("format").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
 	}
-}
 
-func csvMailDomain(m MailDomain, r *strings.Builder) {
-	for _, u := range m.Users {
-		p := ""
-		switch u.Privileges.(type) {
-		case []interface{}:
-			for i := 0; i < len(u.Privileges.([]interface{})); i++ {
-				switch (u.Privileges.([]interface{}))[i].(type) {
-				case string:
-					p = (u.Privileges.([]interface{}))[i].(string)
-				case []string:
-					p = strings.Join((u.Privileges.([]interface{}))[i].([]string), "; ")
-				}
-			}
-		}
+	list, err := items(i)
+	if err != nil {
+		return "", err
+	}
 
-		r.WriteString(fmt.Sprintf(`"%s", "%s", "%s", "%s", "%s"`, m.Domain, u.Email, p, u.Status, u.Mailbox))
-		r.WriteByte('\n')
+	r := strings.Builder{}
+	for n, item := range list {
+		if n > 0 {
+			r.WriteByte('\n')
+		}
+		if err := t.Execute(&r, item); err != nil {
+			return "", err
+		}
 	}
+	return r.String(), nil
 }
 
-func csvRecord(x Record, r *strings.Builder) {
+func marshallJsonPath(i interface{}, tmpl string) (string, error) {
+	p, err := parseJsonPath(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", err
+	}
 
-	r.WriteString(fmt.Sprintf(`"%s", "%s", "%s"`, x.QName, x.RType, x.Value))
-	r.WriteByte('\n')
+	var buf bytes.Buffer
+	if err := p.execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
@@ -0,0 +1,261 @@
+package miab
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormat_NameArg(t *testing.T) {
+	testCases := []struct {
+		format   Format
+		wantName Format
+		wantArg  string
+	}{
+		{JSON, JSON, ""},
+		{TemplateFormat("{{.Email}}"), TEMPLATE, "{{.Email}}"},
+		{JSONPathFormat("{.[*].domain}"), JSONPATH, "{.[*].domain}"},
+		{Format("template={{if eq .Status \"active\"}}{{.Email}}{{end}}"), TEMPLATE, "{{if eq .Status \"active\"}}{{.Email}}{{end}}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			if tc.format.Name() != tc.wantName {
+				t.Errorf("want: %s, got: %s", tc.wantName, tc.format.Name())
+			}
+			if tc.format.Arg() != tc.wantArg {
+				t.Errorf("want: %s, got: %s", tc.wantArg, tc.format.Arg())
+			}
+		})
+	}
+}
+
+func TestToString_Template(t *testing.T) {
+	testCases := []struct {
+		name string
+		i    interface{}
+		tmpl string
+		want string
+	}{
+		{"users", testMailDomains, `{{.Email}}`, "admin@example.org\nuser1@example.org\nuser2@example.org\nadmin@example.com"},
+		{"user domain", testMailDomain2, `{{.Domain}} {{.Email}} {{.Status}}`, "example.com admin@example.com active"},
+		{"aliases", testAliasDomain1, `{{.Address}}: {{join .ForwardsTo ","}}`, "test@example.org: mail@example.org,info@example.org\nabuse@example.org: mail@example.org"},
+		{"records", testRecs, `{{.QName}} {{.RType}} {{.Value}}`, "example.org A 127.0.0.1\nexample.org AAAA ::1"},
+		{"record", testRec1, `{{upper .QName}}`, "EXAMPLE.ORG"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := toString(tc.i, TemplateFormat(tc.tmpl))
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := toString(testRecs, TemplateFormat(`{{.Unknown}}`)); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := toString(testRecs, TemplateFormat(`{{.QName`)); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestToString_JsonPath(t *testing.T) {
+	testCases := []struct {
+		name string
+		i    interface{}
+		tmpl string
+		want string
+	}{
+		{"users", testMailDomains, `{.[*].users[*].email}`, "admin@example.org user1@example.org user2@example.org admin@example.com"},
+		{"records", testRecs, `{.[?(@.rtype=="AAAA")].value}`, "::1"},
+		{"record", testRec1, `{.qname}`, "example.org"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := toString(tc.i, JSONPathFormat(tc.tmpl))
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRecs.Encode(&buf, JSON); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if buf.String() != testRecs.ToString(JSON) {
+		t.Errorf("want: %s, got: %s", testRecs.ToString(JSON), buf.String())
+	}
+
+	buf.Reset()
+	if err := testMailDomains.Encode(&buf, ""); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if buf.String() != testMailDomains.String() {
+		t.Errorf("empty format should be plain, got: %s", buf.String())
+	}
+
+	testCases := []struct {
+		name   string
+		encode func(w io.Writer, format Format) error
+	}{
+		{"records", testRecs.Encode},
+		{"record", testRecs[0].Encode},
+		{"users", testMailDomains.Encode},
+		{"user domain", testMailDomain1.Encode},
+		{"aliases", testAliasDomains.Encode},
+		{"alias domain", testAliasDomain1.Encode},
+		{"status", StatusChecks{}.Encode},
+		{"status check", StatusCheck{}.Encode},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.encode(&bytes.Buffer{}, Format("foo")); err == nil {
+				t.Error("error expected, format is not supported")
+			}
+			if err := tc.encode(&bytes.Buffer{}, TemplateFormat("{{.Foo")); err == nil {
+				t.Error("error expected, template is invalid")
+			}
+		})
+	}
+
+	if s := testRecs.ToString(Format("foo")); s != testRecs.String() {
+		t.Errorf("want plain output, got: %s", s)
+	}
+	if s := testRecs.ToString(TemplateFormat("{{.Foo")); !strings.HasPrefix(s, "error: ") {
+		t.Errorf("want error text, got: %s", s)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	upper := Format("upper")
+	RegisterFormat(upper, func(w io.Writer, v interface{}, arg string) error {
+		s, ok := v.(fmt.Stringer)
+		if !ok {
+			return fmt.Errorf("unsupported type")
+		}
+		_, err := io.WriteString(w, arg+strings.ToUpper(s.String()))
+		return err
+	})
+	defer RegisterFormat(upper, nil)
+
+	found := false
+	for _, f := range Formats() {
+		found = found || f == upper
+	}
+	if !found {
+		t.Errorf("format %s is not registered", upper)
+	}
+
+	if got, want := testRecs[0].ToString(Format("upper=> ")), "> EXAMPLE.ORG	A	127.0.0.1"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	RegisterFormat(upper, nil)
+	if err := testRecs.Encode(&bytes.Buffer{}, upper); err == nil {
+		t.Error("error expected, format is removed")
+	}
+}
+
+func TestToString_Ndjson(t *testing.T) {
+	testCases := []struct {
+		name string
+		i    interface{ ToString(Format) string }
+		want string
+	}{
+		{"records", testRecs, `{"qname":"example.org","rtype":"A","value":"127.0.0.1"}` + "\n" +
+			`{"qname":"example.org","rtype":"AAAA","value":"::1"}` + "\n"},
+		{"users", testMailDomain2, `{"domain":"example.com","email":"admin@example.com","privileges":["admin"],"Status":"active","mailbox":""}` + "\n"},
+		{"status", StatusChecks{testStatusChecks[1]}, `{"type":"ok","text":"All system services are running.","extra":null}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.i.ToString(NDJSON); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+
+	if got := strings.Count(testAliasDomains.ToString(NDJSON), "\n"); got != 3 {
+		t.Errorf("want: 3 lines, got: %d", got)
+	}
+}
+
+func TestToString_Toml(t *testing.T) {
+	testCases := []struct {
+		name string
+		i    interface{ ToString(Format) string }
+		path string
+		want interface{}
+	}{
+		{"records", testRecs, "records", 2},
+		{"record", testRecs[1], "value", "::1"},
+		{"users", testMailDomains, "domains", 2},
+		{"user domain", testMailDomain1, "domain", "example.org"},
+		{"aliases", testAliasDomains, "domains", 2},
+		{"status", testStatusChecks, "checks", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.i.ToString(TOML)
+			tree, err := toml.Load(s)
+			if err != nil {
+				t.Fatalf("invalid toml: %v\n%s", err, s)
+			}
+			got := tree.Get(tc.path)
+			if n, ok := tc.want.(int); ok {
+				if l, ok := got.([]*toml.Tree); !ok || len(l) != n {
+					t.Errorf("want: %d tables, got: %v", n, got)
+				}
+			} else if got != tc.want {
+				t.Errorf("want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestToString_Markdown(t *testing.T) {
+	records := append(Records{{QName: "example.org", RType: TXT, Value: "a|b"}}, testRecs...)
+	want := "| qname | rtype | value |\n| --- | --- | --- |\n| example.org | TXT | a\\|b |\n" +
+		"| example.org | A | 127.0.0.1 |\n| example.org | AAAA | ::1 |\n"
+	if got := records.ToString(MARKDOWN); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := testMailDomains.ToString(MARKDOWN); !strings.HasPrefix(got, "| domain | email | privileges | status | mailbox |\n") {
+		t.Errorf("wrong header: %s", got)
+	}
+}
+
+func TestToString_Html(t *testing.T) {
+	records := Records{{QName: "example.org", RType: TXT, Value: "<script>"}}
+	got := records.ToString(HTML)
+	for _, want := range []string{"<title>DNS records</title>", "<th>qname</th>", "<td>&lt;script&gt;</td>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s not found in:\n%s", want, got)
+		}
+	}
+
+	got = testStatusChecks.ToString(Format("html=Weekly report"))
+	for _, want := range []string{"<h1>Weekly report</h1>", `<tr class="warning">`, "<td>openssl (1.1.1); curl (7.58.0)</td>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s not found in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package miab
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxNameLength  = 253
+	maxLabelLength = 63
+)
+
+// underscoreTypes are the resource types whose records are commonly attached to underscore names (RFC 8552),
+// e.g. _dmarc.example.org (TXT), _sip._tcp.example.org (SRV), _acme-challenge.example.org (CNAME) or
+// _25._tcp.mail.example.org (TLSA). NONE is used for queries, which may match any record.
+var underscoreTypes = map[ResourceType]bool{NONE: true, TXT: true, SRV: true, CNAME: true, TLSA: true}
+
+// ValidateHostname checks if name is a valid host name (RFC 1035, RFC 1123): the labels consist of letters,
+// digits and hyphens (not at the start or the end) and are 1 to 63 characters long, the name is at most 253
+// characters long (without the optional trailing dot). If underscore is set, labels may contain underscores.
+func ValidateHostname(name string, underscore bool) error {
+	n := strings.TrimSuffix(name, ".")
+	if n == "" {
+		return fmt.Errorf("'%s' is not a valid host name: name is empty", name)
+	}
+	if len(n) > maxNameLength {
+		return fmt.Errorf("'%s' is not a valid host name: name is longer than %d characters", name, maxNameLength)
+	}
+	for _, l := range strings.Split(n, ".") {
+		if err := validateLabel(l, underscore); err != nil {
+			return fmt.Errorf("'%s' is not a valid host name: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateQName checks if qname is a valid name for a custom dns record of the ResourceType: a host name (see
+// ValidateHostname) with at least two labels and a top-level domain that isn't numeric. Underscores are allowed
+// for the resource types used with underscore names (TXT, SRV, CNAME, TLSA and NONE), a wildcard (`*`) is
+// allowed as the leftmost label.
+func ValidateQName(qname string, rtype ResourceType) error {
+	n := strings.TrimSuffix(qname, ".")
+	if n == "" {
+		return fmt.Errorf("'qname' is empty")
+	}
+	if len(n) > maxNameLength {
+		return fmt.Errorf("'%s' is not a valid qname: name is longer than %d characters", qname, maxNameLength)
+	}
+
+	labels := strings.Split(n, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("'%s' is not a valid qname: at least a domain and a top-level domain are required", qname)
+	}
+	for i, l := range labels {
+		if l == "*" && i == 0 {
+			continue
+		}
+		if err := validateLabel(l, underscoreTypes[rtype]); err != nil {
+			return fmt.Errorf("'%s' is not a valid qname: %v", qname, err)
+		}
+	}
+
+	tld := labels[len(labels)-1]
+	if strings.Trim(tld, "0123456789") == "" {
+		return fmt.Errorf("'%s' is not a valid qname: top-level domain is numeric", qname)
+	}
+	if strings.Contains(tld, "_") {
+		return fmt.Errorf("'%s' is not a valid qname: top-level domain contains an underscore", qname)
+	}
+	return nil
+}
+
+func validateLabel(l string, underscore bool) error {
+	if l == "" {
+		return fmt.Errorf("empty label")
+	}
+	if len(l) > maxLabelLength {
+		return fmt.Errorf("label '%s' is longer than %d characters", l, maxLabelLength)
+	}
+	if l[0] == '-' || l[len(l)-1] == '-' {
+		return fmt.Errorf("label '%s' starts or ends with a hyphen", l)
+	}
+	for _, c := range l {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		case c == '_' && underscore:
+		case c == '*':
+			return fmt.Errorf("wildcard is only allowed as the leftmost label")
+		default:
+			return fmt.Errorf("label '%s' contains the invalid character '%c'", l, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package miab
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateQName(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	name253 := strings.Repeat(label63+".", 3) + strings.Repeat("a", 57) + ".org"
+
+	testCases := []struct {
+		name      string
+		qname     string
+		rtype     ResourceType
+		wantError bool
+	}{
+		{"domain", "example.org", A, false},
+		{"subdomain", "test.example.org", A, false},
+		{"trailing dot", "test.example.org.", A, false},
+		{"single character label", "a.example.org", A, false},
+		{"single character domain", "a.b.org", A, false},
+		{"digits", "1.2.example.org", A, false},
+		{"hyphen", "my-host.example.org", A, false},
+		{"upper case", "Test.Example.ORG", A, false},
+		{"long tld", "example.photography", A, false},
+		{"tld with digits", "example.xn--p1ai", A, false},
+		{"wildcard", "*.example.org", A, false},
+		{"underscore TXT", "_dmarc.example.org", TXT, false},
+		{"underscore TXT dkim", "mail._domainkey.example.org", TXT, false},
+		{"underscore CNAME", "_acme-challenge.example.org", CNAME, false},
+		{"underscore SRV", "_sip._tcp.example.org", SRV, false},
+		{"underscore TLSA", "_25._tcp.mail.example.org", TLSA, false},
+		{"underscore query", "_dmarc.example.org", NONE, false},
+		{"label 63", label63 + ".example.org", A, false},
+		{"name 253", name253, A, false},
+		{"name 253 trailing dot", name253 + ".", A, false},
+
+		{"empty", "", A, true},
+		{"single label", "localhost", A, true},
+		{"empty label", "test..example.org", A, true},
+		{"leading dot", ".example.org", A, true},
+		{"label 64", label63 + "a.example.org", A, true},
+		{"name 254", "a" + name253, A, true},
+		{"leading hyphen", "-test.example.org", A, true},
+		{"trailing hyphen", "test-.example.org", A, true},
+		{"numeric tld", "example.123", A, true},
+		{"underscore A", "_dmarc.example.org", A, true},
+		{"underscore MX", "_mail.example.org", MX, true},
+		{"underscore tld", "example._org", TXT, true},
+		{"wildcard not leftmost", "test.*.example.org", A, true},
+		{"wildcard in label", "*test.example.org", A, true},
+		{"wildcard only", "*", A, true},
+		{"invalid character", "test%example.org", A, true},
+		{"space", "test example.org", A, true},
+		{"unicode", "müller.de", A, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateQName(tc.qname, tc.rtype)
+			if (err != nil) != tc.wantError {
+				t.Errorf("want error: %v, got: %v", tc.wantError, err)
+			}
+		})
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	testCases := []struct {
+		name       string
+		host       string
+		underscore bool
+		wantError  bool
+	}{
+		{"host", "mail.example.org.", false, false},
+		{"single label", "localhost", false, false},
+		{"underscore", "_sip._tcp.example.org", true, false},
+		{"underscore not allowed", "_sip._tcp.example.org", false, true},
+		{"empty", ".", false, true},
+		{"wildcard", "*.example.org", false, true},
+		{"double dot", "mail..example.org", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateHostname(tc.host, tc.underscore)
+			if (err != nil) != tc.wantError {
+				t.Errorf("want error: %v, got: %v", tc.wantError, err)
+			}
+		})
+	}
+}
+
+func TestConfig_WithoutValidation(t *testing.T) {
+	ts := getDnsTestServer(t, http.MethodPost, 200, "updated DNS:", NONE, false, "mail.example.org")
+	defer ts.Close()
+
+	c, _ := NewConfig("test", "secret", ts.URL)
+	if _, err := AddDns(c, "localhost", MX, "mail.example.org"); err == nil {
+		t.Error("error expected, qname is invalid")
+	}
+	if _, err := AddDns(c, "test.example.org", MX, "mail.example.org"); err == nil {
+		t.Error("error expected, value is invalid")
+	}
+
+	c, _ = NewConfig("test", "secret", ts.URL, WithoutValidation())
+	if ok, err := AddDns(c, "localhost", MX, "mail.example.org"); !ok || err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+	if _, err := AddDns(c, "localhost", ResourceType("B"), "mail.example.org"); err == nil {
+		t.Error("error expected, rtype is still validated")
+	}
+}
@@ -0,0 +1,214 @@
+package miab
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	"strings"
+	"unicode"
+)
+
+// idnaProfile converts domain names for the lookup (UTS #46 mapping, e.g. lower case), underscores and wildcards
+// are allowed since they are used in custom dns records (e.g. _dmarc.example.org or *.example.org).
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule(), idna.Transitional(false))
+
+// cjkScripts may be mixed within a label (e.g. Japanese uses Han, Hiragana and Katakana).
+var cjkScripts = map[string]bool{"Han": true, "Hiragana": true, "Katakana": true, "Hangul": true, "Bopomofo": true}
+
+// ToASCII converts an internationalized domain name to its ASCII (punycode) form, e.g. müller.de to
+// xn--mller-kva.de. Labels mixing several scripts (e.g. Latin and Cyrillic) are rejected to prevent homographs.
+func ToASCII(domain string) (string, error) {
+	if isASCII(domain) && !strings.Contains(domain, "xn--") {
+		return domain, nil
+	}
+
+	a, err := idnaProfile.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid domain name: %v", domain, err)
+	}
+	// punycode labels have to be given in their canonical form (e.g. not as punycode of plain ASCII like xn--abc-)
+	labels := strings.Split(a, ".")
+	for i, label := range strings.Split(domain, ".") {
+		if strings.HasPrefix(strings.ToLower(label), "xn--") && (i >= len(labels) || !strings.EqualFold(label, labels[i])) {
+			return "", fmt.Errorf("'%s' is not a valid domain name: invalid punycode label '%s'", domain, label)
+		}
+	}
+	u, err := idnaProfile.ToUnicode(a)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid domain name: %v", domain, err)
+	}
+	for _, label := range strings.Split(u, ".") {
+		if err := checkScripts(label); err != nil {
+			return "", fmt.Errorf("'%s' is not a valid domain name: %v", domain, err)
+		}
+	}
+	return a, nil
+}
+
+// ToUnicode converts the ASCII (punycode) form of a domain name to Unicode, e.g. xn--mller-kva.de to müller.de.
+// The domain is returned unchanged if it can't be converted.
+func ToUnicode(domain string) string {
+	if !strings.Contains(domain, "xn--") {
+		return domain
+	}
+	u, err := toUnicode(domain)
+	if err != nil {
+		return domain
+	}
+	return u
+}
+
+// toUnicode converts the ASCII form of a domain name to Unicode, labels that don't convert back to the same ASCII
+// form (e.g. punycode of plain ASCII, like xn--abc-) are rejected.
+func toUnicode(domain string) (string, error) {
+	u, err := idnaProfile.ToUnicode(domain)
+	if err != nil {
+		return "", err
+	}
+	if a, err := idnaProfile.ToASCII(u); err != nil || !strings.EqualFold(a, domain) {
+		return "", errors.New("invalid punycode")
+	}
+	return u, nil
+}
+
+// EmailToASCII converts the domain of an e-mail address to its ASCII (punycode) form, see ToASCII.
+func EmailToASCII(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, nil
+	}
+	d, err := ToASCII(email[at+1:])
+	if err != nil {
+		return "", err
+	}
+	return email[:at+1] + d, nil
+}
+
+// EmailToUnicode converts the domain of an e-mail address to Unicode, see ToUnicode.
+func EmailToUnicode(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + ToUnicode(email[at+1:])
+}
+
+// emailsToASCII converts the domains of a comma separated list of e-mail addresses, see EmailToASCII.
+func emailsToASCII(emails string) (string, error) {
+	list := strings.Split(emails, ",")
+	for i, e := range list {
+		a, err := EmailToASCII(strings.TrimSpace(e))
+		if err != nil {
+			return "", err
+		}
+		list[i] = a
+	}
+	return strings.Join(list, ","), nil
+}
+
+func emailsToUnicode(emails []string) []string {
+	if emails == nil {
+		return nil
+	}
+	res := make([]string, len(emails))
+	for i, e := range emails {
+		res[i] = EmailToUnicode(e)
+	}
+	return res
+}
+
+// checkScripts checks that the letters of a label belong to a single script, CJK scripts may be mixed with each
+// other and with Latin.
+func checkScripts(label string) error {
+	var other []string
+	cjk := false
+	for _, r := range label {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for name, table := range unicode.Scripts {
+			if name == "Common" || name == "Inherited" || !unicode.Is(table, r) {
+				continue
+			}
+			if cjkScripts[name] {
+				cjk = true
+			} else if len(other) == 0 || other[0] != name {
+				other = append(other, name)
+			}
+			break
+		}
+	}
+	if len(other) > 1 || len(other) == 1 && cjk && other[0] != "Latin" {
+		return fmt.Errorf("label '%s' mixes scripts", label)
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// ToUnicode returns a copy of the Records with the qnames converted to Unicode, see ToUnicode.
+func (r Records) ToUnicode() Records {
+	if r == nil {
+		return nil
+	}
+	res := make(Records, len(r))
+	for i, x := range r {
+		x.QName = ToUnicode(x.QName)
+		res[i] = x
+	}
+	return res
+}
+
+// ToUnicode returns a copy of the MailDomains with the domains and e-mail addresses converted to Unicode.
+func (m MailDomains) ToUnicode() MailDomains {
+	if m == nil {
+		return nil
+	}
+	res := make(MailDomains, len(m))
+	for i, x := range m {
+		res[i] = x.ToUnicode()
+	}
+	return res
+}
+
+// ToUnicode returns a copy of the MailDomain with the domain and e-mail addresses converted to Unicode.
+func (m MailDomain) ToUnicode() MailDomain {
+	res := MailDomain{Domain: ToUnicode(m.Domain)}
+	for _, u := range m.Users {
+		u.Email = EmailToUnicode(u.Email)
+		res.Users = append(res.Users, u)
+	}
+	return res
+}
+
+// ToUnicode returns a copy of the AliasDomains with the domains and e-mail addresses converted to Unicode.
+func (a AliasDomains) ToUnicode() AliasDomains {
+	if a == nil {
+		return nil
+	}
+	res := make(AliasDomains, len(a))
+	for i, x := range a {
+		res[i] = x.ToUnicode()
+	}
+	return res
+}
+
+// ToUnicode returns a copy of the AliasDomain with the domain and e-mail addresses converted to Unicode.
+func (a AliasDomain) ToUnicode() AliasDomain {
+	res := AliasDomain{Domain: ToUnicode(a.Domain)}
+	for _, x := range a.Aliases {
+		x.Address = EmailToUnicode(x.Address)
+		x.DisplayAddress = EmailToUnicode(x.DisplayAddress)
+		x.ForwardsTo = emailsToUnicode(x.ForwardsTo)
+		x.PermittedSenders = emailsToUnicode(x.PermittedSenders)
+		res.Aliases = append(res.Aliases, x)
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package miab
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	testCases := []struct {
+		name      string
+		domain    string
+		want      string
+		wantError bool
+	}{
+		{"ascii", "test.example.org", "test.example.org", false},
+		{"ascii underscore", "_dmarc.example.org", "_dmarc.example.org", false},
+		{"ascii wildcard", "*.example.org", "*.example.org", false},
+		{"umlaut", "müller.de", "xn--mller-kva.de", false},
+		{"umlaut upper case", "MÜLLER.de", "xn--mller-kva.de", false},
+		{"umlaut subdomain", "www.müller.de.", "www.xn--mller-kva.de.", false},
+		{"umlaut wildcard", "*.müller.de", "*.xn--mller-kva.de", false},
+		{"punycode", "xn--mller-kva.de", "xn--mller-kva.de", false},
+		{"japanese", "例え.テスト", "xn--r8jz45g.xn--zckzah", false},
+		{"japanese and latin", "abc例え.jp", "xn--abc-b73b408n.jp", false},
+		{"cyrillic", "пример.рф", "xn--e1afmkfd.xn--p1ai", false},
+		{"mixed latin and cyrillic", "pаypal.com", "", true},
+		{"mixed greek and latin", "αbc.example.org", "", true},
+		{"mixed cyrillic and han", "пример例え.jp", "", true},
+		{"invalid punycode", "xn--invalid-.example.org", "", true},
+		{"invalid bidi", "aא.example.org", "", true},
+		{"invalid hyphen", "-müller.de", "", true},
+		{"invalid joiner", "a‍b.müller.de", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToASCII(tc.domain)
+			if (err != nil) != tc.wantError {
+				t.Fatalf("want error: %v, got: %v", tc.wantError, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	testCases := []struct {
+		domain string
+		want   string
+	}{
+		{"test.example.org", "test.example.org"},
+		{"xn--mller-kva.de", "müller.de"},
+		{"www.xn--mller-kva.de.", "www.müller.de."},
+		{"xn--e1afmkfd.xn--p1ai", "пример.рф"},
+		{"xn--invalid-.example.org", "xn--invalid-.example.org"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			if got := ToUnicode(tc.domain); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+
+	if got, want := EmailToUnicode("info@xn--mller-kva.de"), "info@müller.de"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	if got, err := EmailToASCII("info@müller.de"); err != nil || got != "info@xn--mller-kva.de" {
+		t.Errorf("want: info@xn--mller-kva.de, got: %s (%v)", got, err)
+	}
+	if got, err := emailsToASCII("info@müller.de, test@example.org"); err != nil || got != "info@xn--mller-kva.de,test@example.org" {
+		t.Errorf("want: info@xn--mller-kva.de,test@example.org, got: %s (%v)", got, err)
+	}
+}
+
+func TestToUnicode_Types(t *testing.T) {
+	records := Records{{QName: "xn--mller-kva.de", RType: CNAME, Value: "xn--mller-kva.de."}}
+	if got := records.ToUnicode(); got[0].QName != "müller.de" || records[0].QName != "xn--mller-kva.de" {
+		t.Errorf("unexpected result: %v (original: %v)", got, records)
+	}
+
+	users := MailDomains{{Domain: "xn--mller-kva.de", Users: Users{{Email: "info@xn--mller-kva.de", Status: Active}}}}
+	if got := users.ToUnicode(); got[0].Domain != "müller.de" || got[0].Users[0].Email != "info@müller.de" ||
+		users[0].Users[0].Email != "info@xn--mller-kva.de" {
+		t.Errorf("unexpected result: %v (original: %v)", got, users)
+	}
+
+	aliases := AliasDomains{{Domain: "xn--mller-kva.de", Aliases: Aliases{{
+		Address: "info@xn--mller-kva.de", DisplayAddress: "info@xn--mller-kva.de",
+		ForwardsTo: []string{"a@xn--mller-kva.de", "b@example.org"}}}}}
+	got := aliases.ToUnicode()
+	a := got[0].Aliases[0]
+	if got[0].Domain != "müller.de" || a.Address != "info@müller.de" || a.DisplayAddress != "info@müller.de" ||
+		a.ForwardsTo[0] != "a@müller.de" || a.ForwardsTo[1] != "b@example.org" || a.PermittedSenders != nil ||
+		aliases[0].Aliases[0].ForwardsTo[0] != "a@xn--mller-kva.de" {
+		t.Errorf("unexpected result: %v (original: %v)", got, aliases)
+	}
+}
+
+func TestIdna_Requests(t *testing.T) {
+	ts := getDnsTestServer(t, http.MethodPut, 200, "updated DNS:", A, false, "127.0.0.1")
+	defer ts.Close()
+	c, _ := NewConfig("test", "secret", ts.URL)
+	if _, err := SetDns(c, "pаypal.com", A, "127.0.0.1"); err == nil {
+		t.Error("error expected, qname mixes scripts")
+	}
+	if ok, err := SetDns(c, "müller.de", A, "127.0.0.1"); !ok || err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+
+	ts = getDnsTestServer(t, http.MethodPost, 200, "", NONE, false,
+		url.Values{"email": {"info@xn--mller-kva.de"}, "password": {"secret"}}.Encode())
+	defer ts.Close()
+	c, _ = NewConfig("test", "secret", ts.URL)
+	if err := AddUser(c, "info@müller.de", "secret"); err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+
+	ts = getDnsTestServer(t, http.MethodPost, 200, "", NONE, false,
+		url.Values{"address": {"@xn--mller-kva.de"}, "forwards_to": {"info@xn--mller-kva.de,test@example.org"}}.Encode())
+	defer ts.Close()
+	c, _ = NewConfig("test", "secret", ts.URL)
+	if err := AddAlias(c, "@müller.de", "info@müller.de,test@example.org"); err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+}
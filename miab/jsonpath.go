@@ -0,0 +1,519 @@
+package miab
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath template, similar to the one used by kubectl
+// (https://kubernetes.io/docs/reference/kubectl/jsonpath/). A template consists of literal text and expressions
+// in curly braces, e.g. `{.[*].users[*].email}`, `{range .[*]}{.domain}{"\n"}{end}` or
+// `{.[*].aliases[?(@.required==true)].address}`. Multiple results of an expression are separated by a space.
+type jsonPath struct {
+	nodes []jpNode
+}
+
+type jpNodeType int
+
+const (
+	jpText jpNodeType = iota
+	jpExpr
+	jpRange
+	jpEnd
+)
+
+type jpNode struct {
+	typ   jpNodeType
+	text  string
+	steps []jpStep
+	body  []jpNode
+}
+
+type jpStepType int
+
+const (
+	jpField jpStepType = iota
+	jpRecursive
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+type jpStep struct {
+	typ        jpStepType
+	name       string
+	index      int
+	start, end *int
+	filter     *jpFilterExpr
+}
+
+type jpFilterExpr struct {
+	path  []jpStep
+	op    string
+	value interface{}
+}
+
+var errJsonPathSyntax = errors.New("invalid jsonpath")
+
+// parseJsonPath parses a JSONPath template.
+func parseJsonPath(tmpl string) (*jsonPath, error) {
+	var nodes []jpNode
+	for len(tmpl) > 0 {
+		i := strings.IndexByte(tmpl, '{')
+		if i < 0 {
+			nodes = append(nodes, jpNode{typ: jpText, text: tmpl})
+			break
+		}
+		if i > 0 {
+			nodes = append(nodes, jpNode{typ: jpText, text: tmpl[:i]})
+		}
+		j := matchingBrace(tmpl, i)
+		if j < 0 {
+			return nil, fmt.Errorf("%v: unclosed '{'", errJsonPathSyntax)
+		}
+		n, err := parseJsonPathExpr(strings.TrimSpace(tmpl[i+1 : j]))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		tmpl = tmpl[j+1:]
+	}
+
+	tree, rest, err := nestRanges(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%v: unexpected {end}", errJsonPathSyntax)
+	}
+	return &jsonPath{nodes: tree}, nil
+}
+
+// matchingBrace returns the index of the curly brace closing the one at position i, quoted strings are skipped.
+func matchingBrace(s string, i int) int {
+	var quote byte
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case quote != 0:
+			if s[j] == '\\' {
+				j++
+			} else if s[j] == quote {
+				quote = 0
+			}
+		case s[j] == '"' || s[j] == '\'':
+			quote = s[j]
+		case s[j] == '}':
+			return j
+		}
+	}
+	return -1
+}
+
+// nestRanges moves the nodes between {range} and {end} into the body of the range node.
+func nestRanges(nodes []jpNode) ([]jpNode, []jpNode, error) {
+	var tree []jpNode
+	for len(nodes) > 0 {
+		n := nodes[0]
+		nodes = nodes[1:]
+		switch n.typ {
+		case jpEnd:
+			return tree, append([]jpNode{n}, nodes...), nil
+		case jpRange:
+			body, rest, err := nestRanges(nodes)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, fmt.Errorf("%v: missing {end}", errJsonPathSyntax)
+			}
+			n.body = body
+			nodes = rest[1:]
+		}
+		tree = append(tree, n)
+	}
+	return tree, nil, nil
+}
+
+func parseJsonPathExpr(expr string) (jpNode, error) {
+	switch {
+	case expr == "end":
+		return jpNode{typ: jpEnd}, nil
+	case strings.HasPrefix(expr, "range "):
+		steps, err := parseJsonPathSteps(strings.TrimSpace(expr[len("range "):]))
+		return jpNode{typ: jpRange, steps: steps}, err
+	case strings.HasPrefix(expr, `"`):
+		s, err := strconv.Unquote(expr)
+		if err != nil {
+			return jpNode{}, fmt.Errorf("%v: %s", errJsonPathSyntax, expr)
+		}
+		return jpNode{typ: jpText, text: s}, nil
+	case strings.HasPrefix(expr, `'`) && strings.HasSuffix(expr, `'`) && len(expr) > 1:
+		return jpNode{typ: jpText, text: expr[1 : len(expr)-1]}, nil
+	}
+	steps, err := parseJsonPathSteps(expr)
+	return jpNode{typ: jpExpr, steps: steps}, err
+}
+
+func parseJsonPathSteps(expr string) ([]jpStep, error) {
+	if strings.HasPrefix(expr, "$") || strings.HasPrefix(expr, "@") {
+		expr = expr[1:]
+	}
+
+	var steps []jpStep
+	for len(expr) > 0 {
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			name, rest := jsonPathName(expr[2:])
+			if name == "" {
+				return nil, fmt.Errorf("%v: missing field after '..'", errJsonPathSyntax)
+			}
+			steps = append(steps, jpStep{typ: jpRecursive, name: name})
+			expr = rest
+		case strings.HasPrefix(expr, ".*"):
+			steps = append(steps, jpStep{typ: jpWildcard})
+			expr = expr[2:]
+		case expr[0] == '.':
+			name, rest := jsonPathName(expr[1:])
+			if name != "" {
+				steps = append(steps, jpStep{typ: jpField, name: name})
+			}
+			expr = rest
+		case expr[0] == '[':
+			j, _ := indexUnquoted(expr, "]")
+			if strings.HasPrefix(expr, "[?(") {
+				j, _ = indexUnquoted(expr, ")]")
+				if j >= 0 {
+					j++
+				}
+			}
+			if j < 0 {
+				return nil, fmt.Errorf("%v: unclosed '['", errJsonPathSyntax)
+			}
+			step, err := parseJsonPathSubscript(expr[1:j])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			expr = expr[j+1:]
+		default:
+			name, rest := jsonPathName(expr)
+			if name == "" {
+				return nil, fmt.Errorf("%v: unexpected '%c'", errJsonPathSyntax, expr[0])
+			}
+			steps = append(steps, jpStep{typ: jpField, name: name})
+			expr = rest
+		}
+	}
+	return steps, nil
+}
+
+func jsonPathName(expr string) (string, string) {
+	i := strings.IndexAny(expr, ".[")
+	if i < 0 {
+		return expr, ""
+	}
+	return expr[:i], expr[i:]
+}
+
+func parseJsonPathSubscript(s string) (jpStep, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "*":
+		return jpStep{typ: jpWildcard}, nil
+	case strings.HasPrefix(s, "?(") && strings.HasSuffix(s, ")"):
+		f, err := parseJsonPathFilter(s[2 : len(s)-1])
+		return jpStep{typ: jpFilter, filter: f}, err
+	case strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`):
+		return jpStep{typ: jpField, name: strings.Trim(s, `'"`)}, nil
+	case strings.Contains(s, ":"):
+		parts := strings.SplitN(s, ":", 2)
+		step := jpStep{typ: jpSlice}
+		for i, p := range parts {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return jpStep{}, fmt.Errorf("%v: invalid slice '%s'", errJsonPathSyntax, s)
+			}
+			if i == 0 {
+				step.start = &n
+			} else {
+				step.end = &n
+			}
+		}
+		return step, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return jpStep{}, fmt.Errorf("%v: invalid index '%s'", errJsonPathSyntax, s)
+	}
+	return jpStep{typ: jpIndex, index: n}, nil
+}
+
+// indexUnquoted returns the index of the first occurrence of one of the substrings in s outside of quoted strings
+// and the substring found, -1 if there is none. At the same index, the first substring given wins.
+func indexUnquoted(s string, substrings ...string) (int, string) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		default:
+			for _, sub := range substrings {
+				if strings.HasPrefix(s[i:], sub) {
+					return i, sub
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func parseJsonPathFilter(s string) (*jpFilterExpr, error) {
+	if i, op := indexUnquoted(s, "==", "!=", "<=", ">=", "<", ">"); i >= 0 {
+		path, err := parseJsonPathSteps(strings.TrimSpace(s[:i]))
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		v := strings.TrimSpace(s[i+len(op):])
+		if strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") && len(v) > 1 {
+			value = v[1 : len(v)-1]
+		} else if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return nil, fmt.Errorf("%v: invalid filter value '%s'", errJsonPathSyntax, v)
+		}
+		return &jpFilterExpr{path: path, op: op, value: value}, nil
+	}
+
+	path, err := parseJsonPathSteps(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return &jpFilterExpr{path: path}, nil
+}
+
+// execute evaluates the template against the value (as decoded by encoding/json) and writes the result to buf.
+func (p *jsonPath) execute(buf *bytes.Buffer, data interface{}) error {
+	return executeJsonPathNodes(buf, p.nodes, data)
+}
+
+func executeJsonPathNodes(buf *bytes.Buffer, nodes []jpNode, data interface{}) error {
+	for _, n := range nodes {
+		switch n.typ {
+		case jpText:
+			buf.WriteString(n.text)
+		case jpExpr:
+			for i, r := range evalJsonPath(n.steps, []interface{}{data}) {
+				if i > 0 {
+					buf.WriteByte(' ')
+				}
+				if err := writeJsonPathValue(buf, r); err != nil {
+					return err
+				}
+			}
+		case jpRange:
+			results := evalJsonPath(n.steps, []interface{}{data})
+			if len(results) == 1 {
+				// ranging over a single array (e.g. `{range .[*].users}`) iterates its items
+				if a, ok := results[0].([]interface{}); ok {
+					results = a
+				}
+			}
+			for _, r := range results {
+				if err := executeJsonPathNodes(buf, n.body, r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeJsonPathValue(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		buf.WriteString(x)
+	case nil:
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	return nil
+}
+
+func evalJsonPath(steps []jpStep, values []interface{}) []interface{} {
+	for _, s := range steps {
+		var next []interface{}
+		for _, v := range values {
+			next = append(next, evalJsonPathStep(s, v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func evalJsonPathStep(s jpStep, v interface{}) []interface{} {
+	switch s.typ {
+	case jpField:
+		if m, ok := v.(map[string]interface{}); ok {
+			if x, ok := m[s.name]; ok {
+				return []interface{}{x}
+			}
+		}
+	case jpRecursive:
+		var res []interface{}
+		walkJson(v, func(x interface{}) {
+			if m, ok := x.(map[string]interface{}); ok {
+				if f, ok := m[s.name]; ok {
+					res = append(res, f)
+				}
+			}
+		})
+		return res
+	case jpWildcard:
+		switch x := v.(type) {
+		case []interface{}:
+			return x
+		case map[string]interface{}:
+			var res []interface{}
+			for _, k := range sortedKeys(x) {
+				res = append(res, x[k])
+			}
+			return res
+		}
+	case jpIndex:
+		if a, ok := v.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				return []interface{}{a[i]}
+			}
+		}
+	case jpSlice:
+		if a, ok := v.([]interface{}); ok {
+			start, end := 0, len(a)
+			if s.start != nil {
+				start = clampIndex(*s.start, len(a))
+			}
+			if s.end != nil {
+				end = clampIndex(*s.end, len(a))
+			}
+			if start < end {
+				return a[start:end]
+			}
+		}
+	case jpFilter:
+		var res []interface{}
+		var items []interface{}
+		switch x := v.(type) {
+		case []interface{}:
+			items = x
+		case map[string]interface{}:
+			for _, k := range sortedKeys(x) {
+				items = append(items, x[k])
+			}
+		}
+		for _, item := range items {
+			if s.filter.match(item) {
+				res = append(res, item)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func (f *jpFilterExpr) match(v interface{}) bool {
+	results := evalJsonPath(f.path, []interface{}{v})
+	if f.op == "" {
+		return len(results) > 0 && results[0] != nil && results[0] != false
+	}
+	if len(results) == 0 {
+		return f.op == "!="
+	}
+
+	r := results[0]
+	switch f.op {
+	case "==":
+		return jsonEqual(r, f.value)
+	case "!=":
+		return !jsonEqual(r, f.value)
+	}
+
+	a, ok1 := r.(float64)
+	b, ok2 := f.value.(float64)
+	if !ok1 || !ok2 {
+		return false
+	}
+	switch f.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+func walkJson(v interface{}, fn func(interface{})) {
+	fn(v)
+	switch x := v.(type) {
+	case []interface{}:
+		for _, i := range x {
+			walkJson(i, fn)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(x) {
+			walkJson(x[k], fn)
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
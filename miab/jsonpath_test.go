@@ -0,0 +1,154 @@
+package miab
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const testJsonPathData = `[
+	{"domain": "example.org", "users": [
+		{"email": "admin@example.org", "privileges": ["admin"], "status": "active"},
+		{"email": "user1@example.org", "privileges": [], "status": "active"},
+		{"email": "user2@example.org", "privileges": [], "status": "inactive"}
+	]},
+	{"domain": "example.com", "users": [
+		{"email": "admin@example.com", "privileges": ["admin"], "status": "active"}
+	]}
+]`
+
+func TestJsonPath(t *testing.T) {
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(testJsonPathData), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		tmpl string
+		want string
+	}{
+		{`{.[*].domain}`, `example.org example.com`},
+		{`{$[0].domain}`, `example.org`},
+		{`{.[-1].domain}`, `example.com`},
+		{`{.[*].users[*].email}`, `admin@example.org user1@example.org user2@example.org admin@example.com`},
+		{`{.[0].users[1:].email}`, `user1@example.org user2@example.org`},
+		{`{.[0].users[:1].email}`, `admin@example.org`},
+		{`{..email}`, `admin@example.org user1@example.org user2@example.org admin@example.com`},
+		{`{.[*].users[?(@.status=="inactive")].email}`, `user2@example.org`},
+		{`{.[*].users[?(@.status!='active')].email}`, `user2@example.org`},
+		{`{.[0].users[0].privileges}`, `["admin"]`},
+		{`{.[0].users[0]['email']}`, `admin@example.org`},
+		{`{range .[*]}{.domain}:{range .users[*]} {.email}{end}{"\n"}{end}`,
+			"example.org: admin@example.org user1@example.org user2@example.org\nexample.com: admin@example.com\n"},
+		{`domains: {.[*].domain}`, `domains: example.org example.com`},
+		{`{.[5].domain}`, ``},
+		{`{.[0].unknown}`, ``},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tmpl, func(t *testing.T) {
+			p, err := parseJsonPath(tc.tmpl)
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := p.execute(&buf, data); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if buf.String() != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestJsonPath_Invalid(t *testing.T) {
+
+	testCases := []string{
+		`{.[*].domain`,
+		`{range .[*]}{.domain}`,
+		`{.domain}{end}`,
+		`{.[abc]}`,
+		`{.[*}`,
+		`{"unterminated}`,
+		`{.users[?(@.status==inactive)]}`,
+		`{..}`,
+		`{.[1:x]}`,
+		`{.[?(@.a=='x')}`,
+		`{.[?(@.a=='x)]'}`,
+		`{.[?(@.a==)]}`,
+		`{range .[*]}{range .users}{end}`,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := parseJsonPath(tc); err == nil {
+				t.Error("error expected")
+			}
+		})
+	}
+}
+
+const testJsonPathRecords = `[
+	{"qname": "example.org", "rtype": "TXT", "value": "x==y", "ttl": 300},
+	{"qname": "example.org", "rtype": "TXT", "value": "a)]b", "ttl": 3600},
+	{"qname": "www.example.org", "rtype": "A", "value": "192.0.2.1", "ttl": 60},
+	{"qname": "mail.example.org", "rtype": "MX", "value": "10 mail.example.org.", "extra": {"ttl": 1}}
+]`
+
+func TestJsonPath_Filters(t *testing.T) {
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(testJsonPathRecords), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"operator in single quoted literal", `{.[?(@.value=='x==y')].ttl}`, `300`},
+		{"operator in double quoted literal", `{.[?(@.value=="x==y")].ttl}`, `300`},
+		{"not equal to quoted operator", `{.[?(@.value!='x==y')].qname}`, `example.org www.example.org mail.example.org`},
+		{"closing bracket in literal", `{.[?(@.value=='a)]b')].ttl}`, `3600`},
+		{"less than", `{.[?(@.ttl<300)].qname}`, `www.example.org`},
+		{"less or equal", `{.[?(@.ttl<=300)].value}`, `x==y 192.0.2.1`},
+		{"greater than", `{.[?(@.ttl>300)].value}`, `a)]b`},
+		{"greater or equal", `{.[?(@.ttl>=300)].ttl}`, `300 3600`},
+		{"string comparison is false", `{.[?(@.value>1)].qname}`, ``},
+		{"existence", `{.[?(@.extra)].rtype}`, `MX`},
+		{"missing field not equal", `{.[?(@.extra!=1)].rtype}`, `TXT TXT A MX`},
+		{"bracket field with operator", `{.[2]['value']}`, `192.0.2.1`},
+		{"slice", `{.[1:3].rtype}`, `TXT A`},
+		{"slice negative start", `{.[-2:].rtype}`, `A MX`},
+		{"slice negative end", `{.[:-3].value}`, `x==y`},
+		{"slice out of range", `{.[5:9].value}`, ``},
+		{"slice empty", `{.[3:1].value}`, ``},
+		{"recursive descent", `{..ttl}`, `300 3600 60 1`},
+		{"recursive descent after field", `{.[3]..ttl}`, `1`},
+		{"wildcard on object", `{.[3].extra.*}`, `1`},
+		{"root", `{$[0].rtype}`, `TXT`},
+		{"quoted text", `{.[0].rtype}{'-'}{.[2].rtype}`, `TXT-A`},
+		{"range with filter", `{range .[?(@.rtype=="TXT")]}{.value};{end}`, `x==y;a)]b;`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parseJsonPath(tc.tmpl)
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := p.execute(&buf, data); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if buf.String() != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, buf.String())
+			}
+		})
+	}
+}
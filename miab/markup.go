@@ -0,0 +1,126 @@
+package miab
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// markdownEscaper escapes the characters that would break a cell of a markdown table.
+var markdownEscaper = strings.NewReplacer(`|`, `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func marshallMarkdown(i interface{}) (string, error) {
+	columns, rows, err := tableData(i)
+	if err != nil {
+		return "", err
+	}
+
+	r := strings.Builder{}
+	writeRow := func(cells []string) {
+		r.WriteString("|")
+		for _, c := range cells {
+			r.WriteString(" ")
+			r.WriteString(markdownEscaper.Replace(c))
+			r.WriteString(" |")
+		}
+		r.WriteString("\n")
+	}
+	writeRow(columns)
+	r.WriteString(strings.Repeat("| --- ", len(columns)))
+	r.WriteString("|\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return r.String(), nil
+}
+
+// reportTitle returns the default title of the html report of i.
+func reportTitle(i interface{}) string {
+	switch x := i.(type) {
+	case AliasDomains, AliasDomain:
+		return "E-mail aliases"
+	case MailDomains, MailDomain:
+		return "E-mail users"
+	case Records, Record:
+		return "DNS records"
+	case StatusChecks, StatusCheck:
+		return "System status checks"
+	case Table:
+		return x.Title
+	}
+	return ""
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+tr.ok td:first-child { color: #080; }
+tr.warning td:first-child { color: #b60; }
+tr.error td:first-child { color: #c00; }
+tr.heading td { font-weight: bold; background: #f6f6f6; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<thead>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr{{with .Class}} class="{{.}}"{{end}}>{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+type reportRow struct {
+	Class string
+	Cells []string
+}
+
+// marshallHtml renders i as a html document with a table, title is the heading of the document (a title
+// depending on the type of i if empty). The rows of status checks are styled by their CheckType (column type).
+func marshallHtml(i interface{}, title string) (string, error) {
+	columns, rows, err := tableData(i)
+	if err != nil {
+		return "", err
+	}
+	if title == "" {
+		title = reportTitle(i)
+	}
+
+	data := struct {
+		Title   string
+		Columns []string
+		Rows    []reportRow
+	}{Title: title, Columns: columns}
+	class := -1
+	for n, c := range columns {
+		if c == "type" {
+			class = n
+		}
+	}
+	for _, row := range rows {
+		r := reportRow{Cells: row}
+		if class >= 0 && class < len(row) {
+			r.Class = row[class]
+		}
+		data.Rows = append(data.Rows, r)
+	}
+
+	r := strings.Builder{}
+	if err := reportTemplate.Execute(&r, data); err != nil {
+		return "", fmt.Errorf("unable to render the report: %v", err)
+	}
+	return r.String(), nil
+}
@@ -2,8 +2,9 @@
 // Most of the endpoints, that the Mail-in-a-Box API provides should be covered (Mail-in-a-Box v0.42b).
 //
 //* Query custom DNS records
-//* Add or set (overwrite) custom DNS records
+//* Add or set (overwrite) custom DNS records, replace record sets
 //* Delete custom DNS records
+//* Wait until DNS changes are served by the nameserver of the box
 //* Query e-mail users
 //* Create e-mail users and mail-domains
 //* Delete e-mail users
@@ -11,4 +12,6 @@
 //* Query e-mail aliases
 //* Create e-mail aliases
 //* Delete e-mail aliases
+//* Run the system status checks
+//* Query the version of the box
 package miab
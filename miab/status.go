@@ -0,0 +1,129 @@
+package miab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// CheckType describes the result type of a status check.
+type CheckType string
+
+// Heading describes a heading, grouping the following status checks.
+const Heading = CheckType("heading")
+
+// Ok describes a passed status check.
+const Ok = CheckType("ok")
+
+// Warning describes a status check with a warning.
+const Warning = CheckType("warning")
+
+// Error describes a failed status check.
+const Error = CheckType("error")
+
+const (
+	statusPath = `admin/system/status`
+)
+
+// StatusChecks defines an array of StatusCheck.
+type StatusChecks []StatusCheck
+
+// String returns a string representation of the StatusChecks.
+func (s StatusChecks) String() string {
+	r := strings.Builder{}
+	for i, x := range s {
+		r.WriteString(x.String())
+		if i < len(s)-1 {
+			r.WriteByte('\n')
+		}
+	}
+	return r.String()
+}
+
+// ToString returns a string representation of the StatusChecks in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
+func (s StatusChecks) ToString(format Format) string {
+	str, _ := toString(s, format)
+	return str
+}
+
+// Encode writes the StatusChecks in the provided Format to w.
+func (s StatusChecks) Encode(w io.Writer, format Format) error {
+	return encode(w, s, format)
+}
+
+// StatusCheck defines the result of a system status check of the Mail-in-a-Box.
+type StatusCheck struct {
+	Type  CheckType     `json:"type"`  // Type is the result type of the check (Heading, Ok, Warning or Error).
+	Text  string        `json:"text"`  // Text is the description of the result.
+	Extra []StatusExtra `json:"extra"` // Extra holds additional details of the result.
+}
+
+// StatusExtra defines additional details of a StatusCheck.
+type StatusExtra struct {
+	Text      string `json:"text"`      // Text is the detail text.
+	Monospace bool   `json:"monospace"` // Monospace describes if the text should be displayed in a monospace font.
+}
+
+// String returns a string representation of the StatusCheck.
+func (s StatusCheck) String() string {
+	if s.Type == Heading {
+		return fmt.Sprintf("%s:", s.Text)
+	}
+
+	r := strings.Builder{}
+	r.WriteString(fmt.Sprintf("\t[%s] %s", strings.ToUpper(string(s.Type)), s.Text))
+	for _, e := range s.Extra {
+		r.WriteString(fmt.Sprintf("\n\t\t%s", e.Text))
+	}
+	return r.String()
+}
+
+// ToString returns a string representation of the StatusCheck in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
+func (s StatusCheck) ToString(format Format) string {
+	str, _ := toString(s, format)
+	return str
+}
+
+// Encode writes the StatusCheck in the provided Format to w.
+func (s StatusCheck) Encode(w io.Writer, format Format) error {
+	return encode(w, s, format)
+}
+
+// GetStatus runs the system status checks of the Mail-in-a-Box and returns the results.
+func GetStatus(c *Config) (StatusChecks, error) {
+
+	client := c.client()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s", c.url(), statusPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.user, c.password)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		bodyBytes, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodyString := string(bodyBytes)
+		if len(bodyString) > 0 {
+			return nil, fmt.Errorf("response error (%d): %s", res.StatusCode, bodyString)
+		}
+		return nil, fmt.Errorf("response error (%d)", res.StatusCode)
+	}
+
+	var result StatusChecks
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package miab
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+var testStatusChecks = StatusChecks{
+	StatusCheck{Type: Heading, Text: "System"},
+	StatusCheck{Type: Ok, Text: "All system services are running."},
+	StatusCheck{Type: Warning, Text: "There are 2 software packages that can be updated.", Extra: []StatusExtra{
+		{Text: "openssl (1.1.1)", Monospace: true},
+		{Text: "curl (7.58.0)", Monospace: true},
+	}},
+}
+
+func TestStatusChecks_String(t *testing.T) {
+	want := "System:\n\t[OK] All system services are running.\n" +
+		"\t[WARNING] There are 2 software packages that can be updated.\n\t\topenssl (1.1.1)\n\t\tcurl (7.58.0)"
+	got := testStatusChecks.String()
+
+	if got != want {
+		t.Errorf("wrong format,\nwant:\n***%s***\n\ngot:\n***%s***", want, got)
+	}
+}
+
+func TestStatusChecks_ToString(t *testing.T) {
+	var s StatusChecks
+	err := json.Unmarshal([]byte(testStatusChecks.ToString(JSON)), &s)
+	if err != nil || len(s) != 3 || s[2].Type != Warning || len(s[2].Extra) != 2 || !s[2].Extra[1].Monospace {
+		t.Error("Unable to unmarshal generated json", err)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	testCases := []struct {
+		name         string
+		serverStatus int
+		want         StatusChecks
+		wantError    bool
+	}{
+		{"OK", 200, testStatusChecks, false},
+		{"server error", 503, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, testStatusChecks.ToString(JSON), NONE, false, "")
+			defer ts.Close()
+			c, _ := NewConfig("test", "secret", ts.URL)
+			got, err := GetStatus(c)
+
+			if tc.wantError && err == nil {
+				t.Errorf("failed, want error, got: nil")
+			} else if !tc.wantError && err != nil {
+				t.Errorf("failed, got error: %v", err)
+			} else if got.ToString(JSON) != tc.want.ToString(JSON) {
+				t.Errorf("failed, want: %v - got: %v", tc.want, got)
+			}
+		})
+	}
+}
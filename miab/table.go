@@ -0,0 +1,308 @@
+package miab
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TableOptions defines the options of the table Format, see TableFormat.
+type TableOptions struct {
+	Columns   []string // Columns holds the columns to output (in order), all columns if empty.
+	SortBy    string   // SortBy is the column to sort the rows by, the rows are not sorted if empty.
+	NoHeaders bool     // NoHeaders omits the header row.
+	Width     int      // Width is the maximal width of a row, longer values are truncated with an ellipsis (0 = unlimited).
+}
+
+const ellipsis = `…`
+
+// minColumnWidth is the minimal width of a truncated column.
+const minColumnWidth = 12
+
+// TableFormat returns a Format that outputs an aligned table with a header, the available columns are:
+//
+// * users: domain, email, privileges, status, mailbox
+// * aliases: domain, address, display-address, forwards-to, permitted-senders, required
+// * dns records: qname, rtype, value
+// * status checks: type, text, extra
+func TableFormat(options TableOptions) Format {
+	var o []string
+	if len(options.Columns) > 0 {
+		o = append(o, "columns:"+strings.Join(options.Columns, ","))
+	}
+	if options.SortBy != "" {
+		o = append(o, "sort-by:"+options.SortBy)
+	}
+	if options.NoHeaders {
+		o = append(o, "no-headers")
+	}
+	if options.Width > 0 {
+		o = append(o, fmt.Sprintf("width:%d", options.Width))
+	}
+	if len(o) == 0 {
+		return TABLE
+	}
+	return Format(fmt.Sprintf("%s=%s", TABLE, strings.Join(o, ";")))
+}
+
+// parseTableOptions parses the argument of the table Format, see TableFormat.
+func parseTableOptions(arg string) (TableOptions, error) {
+	var options TableOptions
+	for _, o := range strings.Split(arg, ";") {
+		kv := strings.SplitN(strings.TrimSpace(o), ":", 2)
+		switch kv[0] {
+		case "":
+		case "columns":
+			if len(kv) == 2 {
+				for _, c := range strings.Split(kv[1], ",") {
+					if c = strings.TrimSpace(c); c != "" {
+						options.Columns = append(options.Columns, strings.ToLower(c))
+					}
+				}
+			}
+		case "sort-by":
+			if len(kv) == 2 {
+				options.SortBy = strings.ToLower(strings.TrimSpace(kv[1]))
+			}
+		case "no-headers":
+			options.NoHeaders = true
+		case "width":
+			if len(kv) != 2 {
+				return options, fmt.Errorf("table option 'width' needs a value")
+			}
+			w, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil || w < 0 {
+				return options, fmt.Errorf("table option 'width' has to be a positive number")
+			}
+			options.Width = w
+		default:
+			return options, fmt.Errorf("unknown table option '%s'", kv[0])
+		}
+	}
+	return options, nil
+}
+
+// Table holds the columns and rows of the tabular output of users, aliases, records or status checks, e.g. to merge
+// the output of several boxes. It can be written in the formats csv, table, markdown and html.
+type Table struct {
+	Title   string     // Title is the title of the html output.
+	Columns []string   // Columns holds the names of the columns.
+	Rows    [][]string // Rows holds the values of the rows, in the order of the columns.
+}
+
+// NewTable returns the Table of v (MailDomains, AliasDomains, Records, StatusChecks or a single item of them).
+func NewTable(v interface{}) (Table, error) {
+	columns, rows, err := tableData(v)
+	if err != nil {
+		return Table{}, err
+	}
+	return Table{Title: reportTitle(v), Columns: columns, Rows: rows}, nil
+}
+
+// Encode writes the Table in the provided Format to w.
+func (t Table) Encode(w io.Writer, format Format) error {
+	return encode(w, t, format)
+}
+
+// tableData returns the column names and the rows of i.
+func tableData(i interface{}) ([]string, [][]string, error) {
+	var rows [][]string
+	switch x := i.(type) {
+	case Table:
+		return x.Columns, x.Rows, nil
+	case AliasDomains:
+		for _, a := range x {
+			_, r, _ := tableData(a)
+			rows = append(rows, r...)
+		}
+		return []string{"domain", "address", "display-address", "forwards-to", "permitted-senders", "required"}, rows, nil
+	case AliasDomain:
+		for _, a := range x.Aliases {
+			rows = append(rows, []string{x.Domain, a.Address, a.DisplayAddress, strings.Join(a.ForwardsTo, ","),
+				strings.Join(a.PermittedSenders, ","), strconv.FormatBool(a.Required)})
+		}
+		return []string{"domain", "address", "display-address", "forwards-to", "permitted-senders", "required"}, rows, nil
+	case MailDomains:
+		for _, m := range x {
+			_, r, _ := tableData(m)
+			rows = append(rows, r...)
+		}
+		return []string{"domain", "email", "privileges", "status", "mailbox"}, rows, nil
+	case MailDomain:
+		for _, u := range x.Users {
+			rows = append(rows, []string{x.Domain, u.Email, strings.Join(privileges(u.Privileges), ","), string(u.Status), u.Mailbox})
+		}
+		return []string{"domain", "email", "privileges", "status", "mailbox"}, rows, nil
+	case Records:
+		for _, r := range x {
+			rows = append(rows, []string{r.QName, string(r.RType), r.Value})
+		}
+		return []string{"qname", "rtype", "value"}, rows, nil
+	case Record:
+		return []string{"qname", "rtype", "value"}, [][]string{{x.QName, string(x.RType), x.Value}}, nil
+	case StatusChecks:
+		for _, c := range x {
+			_, r, _ := tableData(c)
+			rows = append(rows, r...)
+		}
+		return []string{"type", "text", "extra"}, rows, nil
+	case StatusCheck:
+		var extra []string
+		for _, e := range x.Extra {
+			extra = append(extra, e.Text)
+		}
+		return []string{"type", "text", "extra"}, [][]string{{string(x.Type), x.Text, strings.Join(extra, "; ")}}, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported type")
+}
+
+// privileges returns the privileges of a user, see User.Privileges.
+func privileges(p interface{}) []string {
+	var res []string
+	switch x := p.(type) {
+	case string:
+		if x != "" {
+			res = append(res, x)
+		}
+	case []string:
+		res = append(res, x...)
+	case []interface{}:
+		for _, i := range x {
+			res = append(res, privileges(i)...)
+		}
+	}
+	return res
+}
+
+func marshallTable(i interface{}, arg string) (string, error) {
+	options, err := parseTableOptions(arg)
+	if err != nil {
+		return "", err
+	}
+
+	columns, rows, err := tableData(i)
+	if err != nil {
+		return "", err
+	}
+
+	index := map[string]int{}
+	for n, c := range columns {
+		index[c] = n
+	}
+	lookup := func(c string) (int, error) {
+		n, ok := index[c]
+		if !ok {
+			return 0, fmt.Errorf("unknown column '%s' (available: %s)", c, strings.Join(columns, ", "))
+		}
+		return n, nil
+	}
+
+	if options.SortBy != "" {
+		n, err := lookup(options.SortBy)
+		if err != nil {
+			return "", err
+		}
+		sort.SliceStable(rows, func(a, b int) bool {
+			return rows[a][n] < rows[b][n]
+		})
+	}
+
+	selected := make([]int, len(columns))
+	for n := range columns {
+		selected[n] = n
+	}
+	if len(options.Columns) > 0 {
+		selected = selected[:0]
+		for _, c := range options.Columns {
+			n, err := lookup(c)
+			if err != nil {
+				return "", err
+			}
+			selected = append(selected, n)
+		}
+	}
+
+	var table [][]string
+	if !options.NoHeaders {
+		var header []string
+		for _, n := range selected {
+			header = append(header, strings.ToUpper(columns[n]))
+		}
+		table = append(table, header)
+	}
+	for _, row := range rows {
+		var r []string
+		for _, n := range selected {
+			r = append(r, strings.Replace(row[n], "\n", " ", -1))
+		}
+		table = append(table, r)
+	}
+
+	return renderTable(table, options.Width), nil
+}
+
+// renderTable aligns the cells of the table, the columns are separated by two spaces. If the rows exceed the
+// width, the widest columns are truncated (but not below minColumnWidth).
+func renderTable(table [][]string, width int) string {
+	if len(table) == 0 {
+		return ""
+	}
+
+	widths := make([]int, len(table[0]))
+	for _, row := range table {
+		for n, cell := range row {
+			if l := utf8.RuneCountInString(cell); l > widths[n] {
+				widths[n] = l
+			}
+		}
+	}
+
+	if width > 0 {
+		total := 2 * (len(widths) - 1)
+		for _, w := range widths {
+			total += w
+		}
+		for total > width {
+			widest := 0
+			for n, w := range widths {
+				if w > widths[widest] {
+					widest = n
+				}
+			}
+			if widths[widest] <= minColumnWidth {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	r := strings.Builder{}
+	for i, row := range table {
+		if i > 0 {
+			r.WriteByte('\n')
+		}
+		line := strings.Builder{}
+		for n, cell := range row {
+			cell = truncate(cell, widths[n])
+			line.WriteString(cell)
+			if n < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[n]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		r.WriteString(strings.TrimRight(line.String(), " "))
+	}
+	return r.String()
+}
+
+// truncate shortens s to width runes, the last rune is replaced by an ellipsis.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + ellipsis
+}
@@ -0,0 +1,148 @@
+package miab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableFormat(t *testing.T) {
+	testCases := []struct {
+		options TableOptions
+		want    Format
+	}{
+		{TableOptions{}, TABLE},
+		{TableOptions{Columns: []string{"qname", "value"}}, Format("table=columns:qname,value")},
+		{TableOptions{SortBy: "value", NoHeaders: true, Width: 80}, Format("table=sort-by:value;no-headers;width:80")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.want), func(t *testing.T) {
+			got := TableFormat(tc.options)
+			if got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+
+			o, err := parseTableOptions(got.Arg())
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if TableFormat(o) != got {
+				t.Errorf("round-trip failed, want: %s, got: %s", got, TableFormat(o))
+			}
+		})
+	}
+
+	for _, arg := range []string{"width", "width:-1", "width:x", "foo"} {
+		if _, err := parseTableOptions(arg); err == nil {
+			t.Errorf("%s: error expected", arg)
+		}
+	}
+}
+
+func TestToString_Table(t *testing.T) {
+	dkim := Record{QName: "mail._domainkey.example.org", RType: TXT, Value: "v=DKIM1; k=rsa; s=email; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA"}
+
+	testCases := []struct {
+		name    string
+		i       interface{}
+		options TableOptions
+		want    string
+	}{
+		{"records", testRecs, TableOptions{},
+			"QNAME        RTYPE  VALUE\n" +
+				"example.org  A      127.0.0.1\n" +
+				"example.org  AAAA   ::1"},
+		{"records sorted", Records{testRec2, testRec1}, TableOptions{SortBy: "rtype", NoHeaders: true},
+			"example.org  A     127.0.0.1\n" +
+				"example.org  AAAA  ::1"},
+		{"record columns", testRec1, TableOptions{Columns: []string{"value", "QNAME"}},
+			"VALUE      QNAME\n" +
+				"127.0.0.1  example.org"},
+		{"users", testMailDomain1, TableOptions{Columns: []string{"email", "privileges", "status"}},
+			"EMAIL              PRIVILEGES  STATUS\n" +
+				"admin@example.org  admin       active\n" +
+				"user1@example.org              active\n" +
+				"user2@example.org              inactive"},
+		{"aliases", testAliasDomain1, TableOptions{Columns: []string{"address", "forwards-to", "required"}, SortBy: "address"},
+			"ADDRESS            FORWARDS-TO                        REQUIRED\n" +
+				"abuse@example.org  mail@example.org                   true\n" +
+				"test@example.org   mail@example.org,info@example.org  false"},
+		{"truncated", Records{dkim}, TableOptions{NoHeaders: true, Width: 64},
+			"mail._domainkey.example.org  TXT  v=DKIM1; k=rsa; s=email; p=MI…"},
+		{"wide", Records{dkim}, TableOptions{NoHeaders: true},
+			"mail._domainkey.example.org  TXT  " + dkim.Value},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := toString(tc.i, TableFormat(tc.options))
+			if err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("wrong format, want:\n%s\ngot:\n%s", tc.want, got)
+			}
+			if tc.options.Width > 0 {
+				for _, line := range strings.Split(got, "\n") {
+					if l := len([]rune(line)); l > tc.options.Width {
+						t.Errorf("line exceeds width %d: %d", tc.options.Width, l)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := toString(testRecs, TableFormat(TableOptions{Columns: []string{"foo"}})); err == nil {
+		t.Error("error expected")
+	}
+	if _, err := toString(testRecs, TableFormat(TableOptions{SortBy: "foo"})); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	table, err := NewTable(testRecs)
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if table.Title != "DNS records" || len(table.Columns) != 3 || len(table.Rows) != 2 {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+	if _, err := NewTable("foo"); err == nil {
+		t.Error("error expected")
+	}
+
+	table.Columns = append([]string{"profile"}, table.Columns...)
+	for n := range table.Rows {
+		table.Rows[n] = append([]string{"prod"}, table.Rows[n]...)
+	}
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{CSV, "profile,qname,rtype,value\nprod,example.org,A,127.0.0.1\nprod,example.org,AAAA,::1\n"},
+		{MARKDOWN, "| profile | qname | rtype | value |\n| --- | --- | --- | --- |\n" +
+			"| prod | example.org | A | 127.0.0.1 |\n| prod | example.org | AAAA | ::1 |\n"},
+		{TableFormat(TableOptions{Columns: []string{"profile", "value"}}), "PROFILE  VALUE\nprod     127.0.0.1\nprod     ::1"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			var b strings.Builder
+			if err := table.Encode(&b, tc.format); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+			if b.String() != tc.want {
+				t.Errorf("wrong format, want:\n%s\ngot:\n%s", tc.want, b.String())
+			}
+		})
+	}
+
+	var b strings.Builder
+	if err := table.Encode(&b, HTML); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if !strings.Contains(b.String(), "<title>DNS records</title>") || !strings.Contains(b.String(), "<th>profile</th>") {
+		t.Errorf("unexpected html:\n%s", b.String())
+	}
+}
@@ -3,11 +3,11 @@ package miab
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
-	"time"
 )
 
 // Status describes the status of an e-mail account.
@@ -38,14 +38,15 @@ func (m MailDomains) String() string {
 	return r.String()
 }
 
-// ToString returns a string representation of the MailDomains in the provided Format.
+// ToString returns a string representation of the MailDomains in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (m MailDomains) ToString(format Format) string {
-	s, err := toString(m, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(m, format)
+}
+
+// Encode writes the MailDomains in the provided Format to w.
+func (m MailDomains) Encode(w io.Writer, format Format) error {
+	return encode(w, m, format)
 }
 
 // Users defines an array of User
@@ -63,6 +64,10 @@ type User struct {
 	Privileges interface{} `json:"privileges"` // Privileges is a list of privileges, given to the user. Note: due to a bug in Mail-in-a-Box < v0.42, we have to use an generic interface, because the datatype differs in Archived users (string instead of array).
 	Status     Status      `json:"Status"`     // Status is the status of the account (Active or Archived).
 	Mailbox    string      `json:"mailbox"`    // Mailbox is the path to the mailbox on the server (only for archived accounts).
+
+	// Password is only read by ParseMailDomainsCsv to create users, it is never returned by the API and never
+	// written by any Format.
+	Password string `json:"-" yaml:"-"`
 }
 
 // String returns a string representation of the MailDomain.
@@ -83,19 +88,20 @@ func (m MailDomain) String() string {
 	return r.String()
 }
 
-// ToString returns a string representation of the MailDomain in the provided Format.
+// ToString returns a string representation of the MailDomain in the provided Format. An unsupported Format
+// falls back to PLAIN, other errors are returned as text (use Encode to handle them).
 func (m MailDomain) ToString(format Format) string {
-	s, err := toString(m, format)
-	if err != nil {
-		fmt.Println("unexpected error", err)
-		os.Exit(1)
-	}
-	return s
+	return stringOf(m, format)
+}
+
+// Encode writes the MailDomain in the provided Format to w.
+func (m MailDomain) Encode(w io.Writer, format Format) error {
+	return encode(w, m, format)
 }
 
 func execUser(c *Config, path, body string) error {
 
-	client := &http.Client{Timeout: time.Second * 30}
+	client := c.client()
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/%s", c.url(), usersPath, path), strings.NewReader(body))
 	if err != nil {
 		return err
@@ -125,7 +131,7 @@ func execUser(c *Config, path, body string) error {
 // GetUsers returns a list of existing e-mail users.
 func GetUsers(c *Config) (MailDomains, error) {
 
-	client := &http.Client{Timeout: time.Second * 30}
+	client := c.client()
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s?format=json", c.url(), usersPath), nil)
 	if err != nil {
 		return nil, err
@@ -158,24 +164,40 @@ func GetUsers(c *Config) (MailDomains, error) {
 
 // AddUser adds a new e-mail user. Note: Adding an e-mail user with an unknown domain adds this domain also to the server.
 func AddUser(c *Config, email, password string) error {
-	body := fmt.Sprintf("email=%s&password=%s", email, password)
+	email, err := EmailToASCII(email)
+	if err != nil {
+		return err
+	}
+	body := url.Values{"email": {email}, "password": {password}}.Encode()
 	return execUser(c, "add", body)
 }
 
 // DeleteUser removes an existing e-mail user.
 func DeleteUser(c *Config, email string) error {
-	body := fmt.Sprintf("email=%s", email)
+	email, err := EmailToASCII(email)
+	if err != nil {
+		return err
+	}
+	body := url.Values{"email": {email}}.Encode()
 	return execUser(c, "remove", body)
 }
 
 // AddPrivileges adds admin privileges to this user.
 func AddPrivileges(c *Config, email string) error {
-	body := fmt.Sprintf("email=%s&privilege=admin", email)
+	email, err := EmailToASCII(email)
+	if err != nil {
+		return err
+	}
+	body := url.Values{"email": {email}, "privilege": {"admin"}}.Encode()
 	return execUser(c, "privileges/add", body)
 }
 
 // RemovePrivileges removes the admin privileges from this user.
 func RemovePrivileges(c *Config, email string) error {
-	body := fmt.Sprintf("email=%s&privilege=admin", email)
+	email, err := EmailToASCII(email)
+	if err != nil {
+		return err
+	}
+	body := url.Values{"email": {email}, "privilege": {"admin"}}.Encode()
 	return execUser(c, "privileges/remove", body)
 }
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -81,13 +84,13 @@ func TestMailDomain_ToString(t *testing.T) {
 	}
 
 	want := strings.Builder{}
-	want.WriteString(csvUserHead)
+	want.WriteString(`domain,email,privileges,status,mailbox`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "admin@example.org", "admin", "active", ""`)
+	want.WriteString(`example.org,admin@example.org,admin,active,`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "user1@example.org", "", "active", ""`)
+	want.WriteString(`example.org,user1@example.org,,active,`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "user2@example.org", "", "inactive", "/home/miab/mail/example.org/user2"`)
+	want.WriteString(`example.org,user2@example.org,,inactive,/home/miab/mail/example.org/user2`)
 	want.WriteByte('\n')
 
 	got := testMailDomain1.ToString(CSV)
@@ -133,15 +136,15 @@ func TestMailDomains_ToString(t *testing.T) {
 	}
 
 	want := strings.Builder{}
-	want.WriteString(csvUserHead)
+	want.WriteString(`domain,email,privileges,status,mailbox`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "admin@example.org", "admin", "active", ""`)
+	want.WriteString(`example.org,admin@example.org,admin,active,`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "user1@example.org", "", "active", ""`)
+	want.WriteString(`example.org,user1@example.org,,active,`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.org", "user2@example.org", "", "inactive", "/home/miab/mail/example.org/user2"`)
+	want.WriteString(`example.org,user2@example.org,,inactive,/home/miab/mail/example.org/user2`)
 	want.WriteByte('\n')
-	want.WriteString(`"example.com", "admin@example.com", "admin", "active", ""`)
+	want.WriteString(`example.com,admin@example.com,admin,active,`)
 	want.WriteByte('\n')
 
 	got := testMailDomains.ToString(CSV)
@@ -196,11 +199,12 @@ func TestAddUser(t *testing.T) {
 	}{
 		{"user@example.org", "supersecret", 200, false},
 		{"user@example.org", "supersecret", 503, true},
+		{"user@example.org", "p+ss&w%rd =1", 200, false},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("email=%s&password=%s", tc.email, tc.pass))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"email": {tc.email}, "password": {tc.pass}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := AddUser(c, tc.email, tc.pass)
@@ -227,7 +231,7 @@ func TestRemoveUser(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("email=%s", tc.email))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"email": {tc.email}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := DeleteUser(c, tc.email)
@@ -254,7 +258,7 @@ func TestAddPrivileges(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("email=%s&privilege=admin", tc.email))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"email": {tc.email}, "privilege": {"admin"}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := AddPrivileges(c, tc.email)
@@ -281,7 +285,7 @@ func TestRemovePrivileges(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.email, func(t *testing.T) {
-			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, fmt.Sprintf("email=%s&privilege=admin", tc.email))
+			ts := getDnsTestServer(t, http.MethodPost, tc.serverStatus, "", NONE, false, url.Values{"email": {tc.email}, "privilege": {"admin"}}.Encode())
 			defer ts.Close()
 			c, _ := NewConfig("test", "secret", ts.URL)
 			err := RemovePrivileges(c, tc.email)
@@ -295,3 +299,38 @@ func TestRemovePrivileges(t *testing.T) {
 		})
 	}
 }
+
+func TestAddUser_Import(t *testing.T) {
+
+	in := "email,password,privileges\n" +
+		"user@example.org,\"p+ss&w%rd =1\",\n" +
+		"user2@example.org,a%2Bb,\n"
+	mailDomains, err := ParseMailDomainsCsv(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+
+	got := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got[r.PostForm.Get("email")] = r.PostForm.Get("password")
+	}))
+	defer ts.Close()
+	c, _ := NewConfig("test", "secret", ts.URL)
+
+	for _, m := range mailDomains {
+		for _, u := range m.Users {
+			if err := AddUser(c, u.Email, u.Password); err != nil {
+				t.Fatalf("err != nil: %v", err)
+			}
+		}
+	}
+
+	want := map[string]string{"user@example.org": "p+ss&w%rd =1", "user2@example.org": "a%2Bb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
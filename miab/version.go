@@ -0,0 +1,115 @@
+package miab
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const versionPath = `admin/system/version`
+
+// Version defines the version of a Mail-in-a-Box, e.g. v0.54 or v60.1.
+type Version struct {
+	Major int    // Major is the major version, e.g. 0 for v0.54 or 60 for v60.1.
+	Minor int    // Minor is the minor version, e.g. 54 for v0.54 or 1 for v60.1.
+	Raw   string // Raw is the version as reported by the box.
+}
+
+// ParseVersion parses a Mail-in-a-Box version, a leading `v` and suffixes (e.g. `b` of v0.42b) are ignored.
+func ParseVersion(s string) (Version, error) {
+	v := Version{Raw: strings.TrimSpace(s)}
+	parts := strings.SplitN(strings.TrimPrefix(strings.ToLower(v.Raw), "v"), ".", 3)
+	for i, p := range parts[:min(len(parts), 2)] {
+		n := strings.IndexFunc(p, func(r rune) bool { return r < '0' || r > '9' })
+		if n >= 0 {
+			p = p[:n]
+		}
+		d, err := strconv.Atoi(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("'%s' is not a valid version", s)
+		}
+		if i == 0 {
+			v.Major = d
+		} else {
+			v.Minor = d
+		}
+		if n >= 0 {
+			break
+		}
+	}
+	return v, nil
+}
+
+// Less reports if the Version is lower than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+// String returns the version as reported by the box, or in the format v<major>.<minor>.
+func (v Version) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+// GetVersion returns the version of the Mail-in-a-Box.
+func GetVersion(c *Config) (Version, error) {
+	return GetVersionContext(context.Background(), c)
+}
+
+// GetVersionContext is like GetVersion, the request is canceled with the context.
+func GetVersionContext(ctx context.Context, c *Config) (Version, error) {
+
+	client := c.client()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", c.url(), versionPath), nil)
+	if err != nil {
+		return Version{}, err
+	}
+	req.SetBasicAuth(c.user, c.password)
+	res, err := client.Do(req)
+	if err != nil {
+		return Version{}, err
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Version{}, err
+	}
+	bodyString := string(bodyBytes)
+
+	if res.StatusCode != 200 {
+		if len(bodyString) > 0 {
+			return Version{}, fmt.Errorf("response error (%d): %s", res.StatusCode, bodyString)
+		}
+		return Version{}, fmt.Errorf("response error (%d)", res.StatusCode)
+	}
+	return ParseVersion(bodyString)
+}
+
+// versionCache holds the version of a box, a failed request is retried by the next call.
+type versionCache struct {
+	mu      sync.Mutex
+	version *Version
+}
+
+func (v *versionCache) get(ctx context.Context, c *Config) (Version, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.version == nil {
+		version, err := GetVersionContext(ctx, c)
+		if err != nil {
+			return Version{}, err
+		}
+		v.version = &version
+	}
+	return *v.version, nil
+}
@@ -0,0 +1,145 @@
+package miab
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		version   string
+		want      Version
+		wantError bool
+	}{
+		{"v0.54", Version{Major: 0, Minor: 54, Raw: "v0.54"}, false},
+		{"v0.42b\n", Version{Major: 0, Minor: 42, Raw: "v0.42b"}, false},
+		{"v60.1", Version{Major: 60, Minor: 1, Raw: "v60.1"}, false},
+		{"v57a", Version{Major: 57, Raw: "v57a"}, false},
+		{"57", Version{Major: 57, Raw: "57"}, false},
+		{"latest", Version{}, true},
+		{"", Version{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := ParseVersion(tc.version)
+			if (err != nil) != tc.wantError {
+				t.Fatalf("want error: %v, got: %v", tc.wantError, err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %#v, got: %#v", tc.want, got)
+			}
+		})
+	}
+
+	v54, _ := ParseVersion("v0.54")
+	v57, _ := ParseVersion("v57a")
+	v60, _ := ParseVersion("v60.1")
+	if !v54.Less(v57) || !v57.Less(v60) || v60.Less(v57) || v57.Less(v57) {
+		t.Error("wrong order of versions")
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	ts := getDnsTestServer(t, http.MethodGet, 200, "v0.54\n", NONE, false, "")
+	defer ts.Close()
+	c, _ := NewConfig("test", "secret", ts.URL)
+
+	got, err := GetVersion(c)
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if got.Major != 0 || got.Minor != 54 {
+		t.Errorf("want: v0.54, got: %s", got)
+	}
+
+	ts = getDnsTestServer(t, http.MethodGet, 503, "", NONE, false, "")
+	defer ts.Close()
+	c, _ = NewConfig("test", "secret", ts.URL)
+	if _, err := GetVersion(c); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestRegisterResourceType(t *testing.T) {
+	rtypes := append([]ResourceType{}, allResourceTypes...)
+	defer func() {
+		allResourceTypes = rtypes
+		delete(valueParsers, TLSA)
+		delete(valueParsers, DS)
+		delete(minVersions, TLSA)
+		delete(minVersions, DS)
+	}()
+
+	version, requests := "v0.54", 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/"+versionPath {
+			requests++
+			_, _ = w.Write([]byte(version))
+			return
+		}
+		_, _ = w.Write([]byte("updated DNS: example.org"))
+	}))
+	defer ts.Close()
+	c, _ := NewConfig("test", "secret", ts.URL)
+
+	tlsa := "3 1 1 0123456789ABCDEF0123456789abcdef0123456789abcdef0123456789abcdef"
+	if _, err := AddDns(c, "mail.example.org", TLSA, tlsa); err == nil {
+		t.Error("error expected, TLSA is not registered")
+	}
+	if _, err := ParseDnsResource("tlsa"); err == nil {
+		t.Error("error expected, TLSA is not registered")
+	}
+
+	if err := RegisterResourceType("FOO", "", nil); err == nil {
+		t.Error("error expected, no parser")
+	}
+	if err := RegisterResourceType(TLSA, "latest", nil); err == nil {
+		t.Error("error expected, invalid version")
+	}
+	if err := RegisterResourceType(TLSA, "v60", nil); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if err := RegisterResourceType(DS, "", nil); err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+
+	if _, err := ParseDnsResource("tlsa"); err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+	if _, err := ParseRecordValue(TLSA, "3 1 1 0123"); err == nil {
+		t.Error("error expected, invalid SHA-256 data")
+	}
+	if v, err := ParseRecordValue(TLSA, tlsa); err != nil || v.String() != "3 1 1 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" {
+		t.Errorf("unexpected value: %v (%v)", v, err)
+	}
+
+	if _, err := AddDns(c, "mail.example.org", TLSA, tlsa); err == nil {
+		t.Error("error expected, version is too old")
+	}
+	version = "v60.1"
+	if _, err := AddDns(c, "mail.example.org", TLSA, tlsa); err == nil {
+		t.Error("error expected, the version is cached")
+	}
+	if requests != 1 {
+		t.Errorf("want: 1 version request, got: %d", requests)
+	}
+	c, _ = NewConfig("test", "secret", ts.URL)
+	if ok, err := AddDns(c, "mail.example.org", TLSA, tlsa); !ok || err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+
+	ds := "12345 13 2 0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	if _, err := ParseRecordValue(DS, "12345 13 3 0123"); err == nil {
+		t.Error("error expected, invalid digest type")
+	}
+	if ok, err := AddDns(c, "sub.example.org", DS, ds); !ok || err != nil {
+		t.Errorf("err != nil: %v", err)
+	}
+	version = "v0.30"
+	c, _ = NewConfig("test", "secret", ts.URL)
+	if _, err := AddDns(c, "sub.example.org", DS, ds); err == nil {
+		t.Error("error expected, DS defaults to the known minimum version")
+	}
+}
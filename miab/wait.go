@@ -0,0 +1,202 @@
+package miab
+
+import (
+	"context"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWaitTimeout  = 2 * time.Minute
+	defaultWaitInterval = 2 * time.Second
+)
+
+// WaitOptions defines the options of WaitForDns.
+type WaitOptions struct {
+	Nameserver string        // Nameserver is the address of the nameserver (host or host:port), defaults to the host of the endpoint.
+	Timeout    time.Duration // Timeout is the maximal duration to wait, defaults to 2 minutes.
+	Interval   time.Duration // Interval is the duration between two queries, defaults to 2 seconds.
+	Contains   bool          // Contains waits until the values are served, other records (e.g. the automatic records of the box) are ignored.
+	Absent     []string      // Absent are values that must not be served any more, e.g. of deleted records.
+}
+
+// WaitForDns queries the nameserver of the box directly (non-recursive) until the records of the qname and rtype
+// match the values (in any order, an empty list waits for the records to disappear) or the timeout is reached or
+// the context is canceled. With the Contains option the served records only have to contain the values, none of
+// the Absent values may be served. The values are compared in the canonical form of ParseRecordValue. Returns the
+// duration until the records were served.
+func WaitForDns(ctx context.Context, c *Config, qname string, rtype ResourceType, values []string, options WaitOptions) (time.Duration, error) {
+
+	qtype, ok := dns.StringToType[string(rtype)]
+	if !ok || rtype == NONE {
+		return 0, errRtypeNotSet
+	}
+	qname, err := c.checkQName(qname, rtype)
+	if err != nil {
+		return 0, err
+	}
+
+	server := options.Nameserver
+	if server == "" {
+		u, err := url.Parse(c.url())
+		if err != nil {
+			return 0, err
+		}
+		server = u.Hostname()
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultWaitTimeout
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaultWaitInterval
+	}
+
+	want := make([]string, 0, len(values))
+	for _, v := range values {
+		want = append(want, canonicalValue(rtype, v))
+	}
+	sort.Strings(want)
+	absent := make([]string, 0, len(options.Absent))
+	for _, v := range options.Absent {
+		absent = append(absent, canonicalValue(rtype, v))
+	}
+
+	start := time.Now()
+	timeout, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+	var last error
+	for {
+		got, err := queryRecords(timeout, server, qname, qtype, rtype)
+		if err == nil && matchValues(want, absent, got, options.Contains) {
+			return time.Since(start), nil
+		}
+		if err != nil {
+			last = err
+		} else {
+			last = fmt.Errorf("nameserver %s serves [%s], want [%s]", server, strings.Join(got, ", "), strings.Join(want, ", "))
+		}
+
+		select {
+		case <-timeout.Done():
+			if err := ctx.Err(); err != nil {
+				return time.Since(start), err
+			}
+			return time.Since(start), fmt.Errorf("timeout after %s: %v", options.Timeout, last)
+		case <-time.After(options.Interval):
+		}
+	}
+}
+
+// matchValues reports if the served values match the wanted ones (or contain them) and none of the absent values
+// is served.
+func matchValues(want, absent, got []string, contains bool) bool {
+	served := map[string]bool{}
+	for _, v := range got {
+		served[v] = true
+	}
+	for _, v := range absent {
+		if served[v] {
+			return false
+		}
+	}
+	if !contains {
+		return equalValues(want, got)
+	}
+	for _, v := range want {
+		if !served[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// queryRecords returns the sorted values of the records of the qname and type served by the nameserver.
+func queryRecords(ctx context.Context, server, qname string, qtype uint16, rtype ResourceType) ([]string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(qname), qtype)
+	m.RecursionDesired = false
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+	r, _, err := client.ExchangeContext(ctx, m, server)
+	if err == nil && r.Truncated {
+		client.Net = "tcp"
+		r, _, err = client.ExchangeContext(ctx, m, server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query nameserver %s: %v", server, err)
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("nameserver %s responded with %s", server, dns.RcodeToString[r.Rcode])
+	}
+
+	values := []string{}
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype != qtype || !strings.EqualFold(rr.Header().Name, dns.Fqdn(qname)) {
+			continue
+		}
+		values = append(values, canonicalValue(rtype, rrValue(rr)))
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// rrValue returns the value of a resource record in the format of the Mail-in-a-Box API.
+func rrValue(rr dns.RR) string {
+	switch x := rr.(type) {
+	case *dns.A:
+		return x.A.String()
+	case *dns.AAAA:
+		return x.AAAA.String()
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", x.Preference, x.Mx)
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", x.Priority, x.Weight, x.Port, x.Target)
+	case *dns.CAA:
+		return fmt.Sprintf(`%d %s "%s"`, x.Flag, x.Tag, x.Value)
+	case *dns.SSHFP:
+		return fmt.Sprintf("%d %d %s", x.Algorithm, x.Type, x.FingerPrint)
+	case *dns.TXT:
+		return strings.Join(x.Txt, "")
+	case *dns.CNAME:
+		return x.Target
+	case *dns.NS:
+		return x.Ns
+	case *dns.TLSA:
+		return fmt.Sprintf("%d %d %d %s", x.Usage, x.Selector, x.MatchingType, x.Certificate)
+	case *dns.DS:
+		return fmt.Sprintf("%d %d %d %s", x.KeyTag, x.Algorithm, x.DigestType, x.Digest)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// canonicalValue returns the value in the canonical form of ParseRecordValue, host names in lower case.
+func canonicalValue(rtype ResourceType, value string) string {
+	if v, err := ParseRecordValue(rtype, value); err == nil {
+		value = v.String()
+	}
+	switch rtype {
+	case MX, SRV, CNAME, NS:
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
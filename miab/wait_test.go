@@ -0,0 +1,185 @@
+package miab
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNameserver is an in-process authoritative nameserver, its records can be changed while it is running.
+type testNameserver struct {
+	mu      sync.Mutex
+	records map[uint16][]dns.RR
+	server  *dns.Server
+	addr    string
+}
+
+func newTestNameserver(t *testing.T) *testNameserver {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	ns := &testNameserver{records: map[uint16][]dns.RR{}, addr: pc.LocalAddr().String()}
+	started := make(chan struct{})
+	ns.server = &dns.Server{PacketConn: pc, NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative = true
+			ns.mu.Lock()
+			for _, rr := range ns.records[r.Question[0].Qtype] {
+				if strings.EqualFold(rr.Header().Name, r.Question[0].Name) {
+					m.Answer = append(m.Answer, rr)
+				}
+			}
+			ns.mu.Unlock()
+			_ = w.WriteMsg(m)
+		})}
+	go func() { _ = ns.server.ActivateAndServe() }()
+	<-started
+	return ns
+}
+
+func (ns *testNameserver) set(t *testing.T, records ...string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.records = map[uint16][]dns.RR{}
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("invalid record %s: %v", s, err)
+		}
+		ns.records[rr.Header().Rrtype] = append(ns.records[rr.Header().Rrtype], rr)
+	}
+}
+
+func TestWaitForDns(t *testing.T) {
+	ns := newTestNameserver(t)
+	defer ns.server.Shutdown()
+	c, _ := NewConfig("test", "secret", "https://box.example.org")
+	options := WaitOptions{Nameserver: ns.addr, Timeout: 2 * time.Second, Interval: 50 * time.Millisecond}
+
+	ns.set(t,
+		"test.example.org. 300 IN A 127.0.0.1",
+		"test.example.org. 300 IN A 127.0.0.2",
+		"test.example.org. 300 IN MX 10 Mail.Example.org.",
+		`test.example.org. 300 IN TXT "v=DKIM1; k=rsa; " "p=MIIB"`,
+		`test.example.org. 300 IN CAA 0 issue "letsencrypt.org"`,
+		"other.example.org. 300 IN A 127.0.0.3",
+	)
+
+	testCases := []struct {
+		name   string
+		rtype  ResourceType
+		values []string
+	}{
+		{"A", A, []string{"127.0.0.2", "127.0.0.1"}},
+		{"MX", MX, []string{"10 mail.example.org"}},
+		{"TXT", TXT, []string{"v=DKIM1; k=rsa; p=MIIB"}},
+		{"CAA", CAA, []string{`0 issue "letsencrypt.org"`}},
+		{"AAAA deleted", AAAA, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := WaitForDns(context.Background(), c, "test.example.org", tc.rtype, tc.values, options); err != nil {
+				t.Errorf("err != nil: %v", err)
+			}
+		})
+	}
+
+	options.Timeout = 200 * time.Millisecond
+	if _, err := WaitForDns(context.Background(), c, "test.example.org", A, []string{"127.0.0.1"}, options); err == nil {
+		t.Error("error expected, the record set differs")
+	}
+	if _, err := WaitForDns(context.Background(), c, "test.example.org", NONE, nil, options); err == nil {
+		t.Error("error expected, rtype is missing")
+	}
+}
+
+func TestWaitForDns_Contains(t *testing.T) {
+	ns := newTestNameserver(t)
+	defer ns.server.Shutdown()
+	c, _ := NewConfig("test", "secret", "https://box.example.org")
+	options := WaitOptions{Nameserver: ns.addr, Timeout: 200 * time.Millisecond, Interval: 50 * time.Millisecond, Contains: true}
+
+	ns.set(t,
+		"test.example.org. 300 IN TXT \"v=spf1 mx -all\"",
+		"test.example.org. 300 IN TXT \"custom\"",
+	)
+
+	testCases := []struct {
+		name    string
+		values  []string
+		absent  []string
+		wantErr bool
+	}{
+		{"contains", []string{"custom"}, nil, false},
+		{"contains all", []string{"v=spf1 mx -all", "custom"}, nil, false},
+		{"missing", []string{"custom", "other"}, nil, true},
+		{"absent", []string{"custom"}, []string{"deleted"}, false},
+		{"still served", nil, []string{"custom"}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options.Absent = tc.absent
+			if _, err := WaitForDns(context.Background(), c, "test.example.org", TXT, tc.values, options); (err != nil) != tc.wantErr {
+				t.Errorf("want error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	options.Contains = false
+	options.Absent = nil
+	if _, err := WaitForDns(context.Background(), c, "test.example.org", TXT, []string{"custom"}, options); err == nil {
+		t.Error("error expected, the record set differs")
+	}
+}
+
+func TestWaitForDns_Canceled(t *testing.T) {
+	ns := newTestNameserver(t)
+	defer ns.server.Shutdown()
+	c, _ := NewConfig("test", "secret", "https://box.example.org")
+	options := WaitOptions{Nameserver: ns.addr, Timeout: 5 * time.Second, Interval: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d, err := WaitForDns(ctx, c, "test.example.org", A, []string{"127.0.0.1"}, options)
+	if err != context.DeadlineExceeded {
+		t.Errorf("canceled context expected, got: %v", err)
+	}
+	if d >= time.Second {
+		t.Errorf("canceled too late: %s", d)
+	}
+}
+
+func TestWaitForDns_Propagation(t *testing.T) {
+	ns := newTestNameserver(t)
+	defer ns.server.Shutdown()
+	c, _ := NewConfig("test", "secret", "https://box.example.org")
+
+	ns.set(t, "test.example.org. 300 IN A 127.0.0.1")
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		ns.set(t, "test.example.org. 300 IN A 127.0.0.2")
+	}()
+
+	options := WaitOptions{Nameserver: ns.addr, Timeout: 5 * time.Second, Interval: 50 * time.Millisecond}
+	d, err := WaitForDns(context.Background(), c, "test.example.org", A, []string{"127.0.0.2"}, options)
+	if err != nil {
+		t.Fatalf("err != nil: %v", err)
+	}
+	if d < 200*time.Millisecond {
+		t.Errorf("propagation time too short: %s", d)
+	}
+
+	options.Timeout = 300 * time.Millisecond
+	if _, err := WaitForDns(context.Background(), c, "test.example.org", A, []string{"127.0.0.3"}, options); err == nil ||
+		!strings.Contains(err.Error(), "timeout") {
+		t.Errorf("timeout expected, got: %v", err)
+	}
+}